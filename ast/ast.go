@@ -1,6 +1,10 @@
 package ast
 
-import "github.com/SHyx0rmZ/cgen/token"
+import (
+	"strings"
+
+	"github.com/SHyx0rmZ/cgen/token"
+)
 
 type Node interface {
 	Pos() token.Pos
@@ -25,17 +29,77 @@ type Decl interface {
 // ----------------------------------------------------------------------------
 // Comments
 
+// A Comment node represents a single /*-style or //-style comment.
 type Comment struct {
-	Slash token.Pos
-	Text  string
+	Slash token.Pos // position of "/" starting the comment
+	Text  string    // comment text, including the comment markers
 }
 
 func (c *Comment) Pos() token.Pos { return c.Slash }
 func (c *Comment) End() token.Pos { return token.Pos(int(c.Slash) + len(c.Text)) }
 
+// A CommentGroup represents a sequence of comments with no other tokens
+// and no empty lines between.
+type CommentGroup struct {
+	List []*Comment // len(List) > 0
+}
+
+func (g *CommentGroup) Pos() token.Pos { return g.List[0].Pos() }
+func (g *CommentGroup) End() token.Pos { return g.List[len(g.List)-1].End() }
+
+// Text returns the text of the comment group. Comment markers, the
+// leading stars of the lines of a block comment, leading and trailing
+// empty lines and trailing space are removed. Multiple empty lines are
+// reduced to one. The result ends in a newline, unless it is empty.
+func (g *CommentGroup) Text() string {
+	if g == nil {
+		return ""
+	}
+	var lines []string
+	for _, c := range g.List {
+		text := c.Text
+		if strings.HasPrefix(text, "//") {
+			lines = append(lines, strings.TrimPrefix(text[2:], "/"))
+			continue
+		}
+		text = strings.TrimRight(strings.TrimSuffix(text[2:], "*/"), "*")
+		text = strings.TrimLeft(text, "*!")
+		for i, line := range strings.Split(text, "\n") {
+			if i > 0 {
+				// strip the decoration of doxygen style comments
+				line = strings.TrimLeft(line, " \t")
+				if strings.HasPrefix(line, "*") && !strings.HasPrefix(line, "*/") {
+					line = line[1:]
+				}
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	// remove a common leading space, as in "// text" or " * text"
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(strings.TrimPrefix(line, " "), " \t\r")
+	}
+
+	var n int
+	for _, line := range lines {
+		if line != "" || n > 0 && lines[n-1] != "" {
+			lines[n] = line
+			n++
+		}
+	}
+	lines = lines[:n]
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
 // An expression is represented by a tree consisting of one
 // or more of the following concrete expression nodes.
-//
 type (
 	// A BadExpr node is a placeholder for expressions containing
 	// syntax errors for which no correct expression nodes can be
@@ -49,14 +113,15 @@ type (
 	Ident struct {
 		NamePos token.Pos // identifier position
 		Name    string    // identifier name
-		// Obj *Object
+		Obj     *Object   // denoted object; or nil
 	}
 
 	// A BasicLit node represents a literal of basic type.
 	BasicLit struct {
 		ValuePos token.Pos   // literal position
-		Kind     token.Token // token.INT
-		Value    string      // literal string
+		Kind     token.Token // token.INT, token.FLOAT, token.CHAR, or token.STRING
+		Value    string      // literal string; e.g. 42, 0x7f, 1.5f, 'a', L"foo"
+		Suffix   string      // integer or floating suffix of Value, e.g. UL or f
 	}
 
 	UnaryExpr struct {
@@ -77,31 +142,130 @@ type (
 		Expr    Expr
 		Closing token.Pos
 	}
-)
 
-func (x *BadExpr) Pos() token.Pos    { return x.From }
-func (x *Ident) Pos() token.Pos      { return x.NamePos }
-func (x *BasicLit) Pos() token.Pos   { return x.ValuePos }
-func (x *UnaryExpr) Pos() token.Pos  { return x.OpPos }
-func (x *BinaryExpr) Pos() token.Pos { return x.X.Pos() }
-func (x *ParenExpr) Pos() token.Pos  { return x.Opening }
+	// A CondExpr node represents a conditional expression.
+	CondExpr struct {
+		Cond     Expr
+		Question token.Pos // position of "?"
+		X        Expr
+		Colon    token.Pos // position of ":"
+		Y        Expr
+	}
 
-func (x *BadExpr) End() token.Pos    { return x.To }
-func (x *Ident) End() token.Pos      { return token.Pos(int(x.NamePos) + len(x.Name)) }
-func (x *BasicLit) End() token.Pos   { return token.Pos(int(x.ValuePos) + len(x.Value)) }
-func (x *UnaryExpr) End() token.Pos  { return x.X.End() }
-func (x *BinaryExpr) End() token.Pos { return x.Y.End() }
-func (x *ParenExpr) End() token.Pos  { return x.Closing }
+	// A CastExpr node represents a conversion of X to Type.
+	CastExpr struct {
+		Opening token.Pos // position of "("
+		Type    Expr
+		Closing token.Pos // position of ")"
+		X       Expr
+	}
+
+	// An IndexExpr node represents an expression followed by a
+	// subscript.
+	IndexExpr struct {
+		X       Expr
+		Opening token.Pos // position of "["
+		Index   Expr
+		Closing token.Pos // position of "]"
+	}
+
+	// A CallExpr node represents a function call.
+	CallExpr struct {
+		Fun     Expr
+		Opening token.Pos // position of "("
+		Args    []Expr
+		Closing token.Pos // position of ")"
+	}
+
+	// A SelectorExpr node represents a member access with "." or "->".
+	SelectorExpr struct {
+		X     Expr
+		OpPos token.Pos   // position of Op
+		Op    token.Token // token.PERIOD or token.ARROW
+		Sel   *Ident
+	}
+
+	// A PostfixExpr node represents a postfix increment or decrement.
+	PostfixExpr struct {
+		X     Expr
+		OpPos token.Pos   // position of Op
+		Op    token.Token // token.INC or token.DEC
+	}
+
+	// A SizeofExpr node represents the sizeof operator. If X is a
+	// type, the positions of the parentheses around it are set.
+	SizeofExpr struct {
+		Sizeof  token.Pos // position of "sizeof"
+		Opening token.Pos // position of "(", if X is a type
+		X       Expr      // expression or type
+		Closing token.Pos // position of ")", if X is a type
+	}
+
+	// A DefinedExpr node represents the defined operator in the
+	// condition of an #if or #elif directive.
+	DefinedExpr struct {
+		Defined token.Pos // position of "defined"
+		Name    *Ident
+		Closing token.Pos // position of ")", if any
+	}
+)
+
+func (x *BadExpr) Pos() token.Pos      { return x.From }
+func (x *Ident) Pos() token.Pos        { return x.NamePos }
+func (x *BasicLit) Pos() token.Pos     { return x.ValuePos }
+func (x *UnaryExpr) Pos() token.Pos    { return x.OpPos }
+func (x *BinaryExpr) Pos() token.Pos   { return x.X.Pos() }
+func (x *ParenExpr) Pos() token.Pos    { return x.Opening }
+func (x *CondExpr) Pos() token.Pos     { return x.Cond.Pos() }
+func (x *CastExpr) Pos() token.Pos     { return x.Opening }
+func (x *IndexExpr) Pos() token.Pos    { return x.X.Pos() }
+func (x *CallExpr) Pos() token.Pos     { return x.Fun.Pos() }
+func (x *SelectorExpr) Pos() token.Pos { return x.X.Pos() }
+func (x *PostfixExpr) Pos() token.Pos  { return x.X.Pos() }
+func (x *SizeofExpr) Pos() token.Pos   { return x.Sizeof }
+func (x *DefinedExpr) Pos() token.Pos  { return x.Defined }
+
+func (x *BadExpr) End() token.Pos      { return x.To }
+func (x *Ident) End() token.Pos        { return token.Pos(int(x.NamePos) + len(x.Name)) }
+func (x *BasicLit) End() token.Pos     { return token.Pos(int(x.ValuePos) + len(x.Value)) }
+func (x *UnaryExpr) End() token.Pos    { return x.X.End() }
+func (x *BinaryExpr) End() token.Pos   { return x.Y.End() }
+func (x *ParenExpr) End() token.Pos    { return x.Closing }
+func (x *CondExpr) End() token.Pos     { return x.Y.End() }
+func (x *CastExpr) End() token.Pos     { return x.X.End() }
+func (x *IndexExpr) End() token.Pos    { return x.Closing + 1 }
+func (x *CallExpr) End() token.Pos     { return x.Closing + 1 }
+func (x *SelectorExpr) End() token.Pos { return x.Sel.End() }
+func (x *PostfixExpr) End() token.Pos  { return x.OpPos + 2 }
+func (x *SizeofExpr) End() token.Pos {
+	if x.Closing != 0 {
+		return x.Closing + 1
+	}
+	return x.X.End()
+}
+func (x *DefinedExpr) End() token.Pos {
+	if x.Closing != 0 {
+		return x.Closing + 1
+	}
+	return x.Name.End()
+}
 
 // exprNode() ensures that only expression/type nodes can be
 // assigned to an Expr.
-//
-func (*BadExpr) exprNode()    {}
-func (*Ident) exprNode()      {}
-func (*BasicLit) exprNode()   {}
-func (*UnaryExpr) exprNode()  {}
-func (*BinaryExpr) exprNode() {}
-func (*ParenExpr) exprNode()  {}
+func (*BadExpr) exprNode()      {}
+func (*Ident) exprNode()        {}
+func (*BasicLit) exprNode()     {}
+func (*UnaryExpr) exprNode()    {}
+func (*BinaryExpr) exprNode()   {}
+func (*ParenExpr) exprNode()    {}
+func (*CondExpr) exprNode()     {}
+func (*CastExpr) exprNode()     {}
+func (*IndexExpr) exprNode()    {}
+func (*CallExpr) exprNode()     {}
+func (*SelectorExpr) exprNode() {}
+func (*PostfixExpr) exprNode()  {}
+func (*SizeofExpr) exprNode()   {}
+func (*DefinedExpr) exprNode()  {}
 
 func (id *Ident) String() string {
 	if id != nil {
@@ -121,11 +285,15 @@ const (
 )
 
 type ArgList struct {
-	Opening token.Pos
-	List    []*Ident
-	Closing token.Pos
+	Opening  token.Pos
+	List     []*Ident
+	Ellipsis *Ellipsis // or nil, if the macro is not variadic
+	Closing  token.Pos
 }
 
+func (l *ArgList) Pos() token.Pos { return l.Opening }
+func (l *ArgList) End() token.Pos { return l.Closing + 1 }
+
 type Dir interface {
 	Node
 	dirNode()
@@ -137,10 +305,17 @@ type (
 	}
 
 	MacroDir struct {
+		Doc     *CommentGroup // associated documentation; or nil
+		DirPos  token.Pos
+		Name    *Ident
+		Args    *ArgList
+		Value   Expr          //todo
+		Comment *CommentGroup // line comment; or nil
+	}
+
+	UndefDir struct {
 		DirPos token.Pos
 		Name   *Ident
-		Args   *ArgList
-		Value  Expr //todo
 	}
 
 	IncludeDir struct {
@@ -149,12 +324,28 @@ type (
 		Path    string // computed #includes are not supported
 	}
 
+	PragmaDir struct {
+		DirPos  token.Pos
+		TextPos token.Pos
+		Text    string // remainder of the line, without surrounding space
+	}
+
 	IfDefDir struct {
 		DirPos token.Pos
 		Cond   IfDefCond
 		Name   *Ident
 	}
 
+	IfDir struct {
+		DirPos token.Pos
+		Cond   Expr
+	}
+
+	ElifDir struct {
+		DirPos token.Pos
+		Cond   Expr
+	}
+
 	ElseDir struct {
 		DirPos token.Pos
 	}
@@ -166,8 +357,12 @@ type (
 
 func (d *BadDir) Pos() token.Pos     { return d.From }
 func (d *MacroDir) Pos() token.Pos   { return d.Name.Pos() }
+func (d *UndefDir) Pos() token.Pos   { return d.DirPos }
 func (d *IncludeDir) Pos() token.Pos { return d.DirPos }
+func (d *PragmaDir) Pos() token.Pos  { return d.DirPos }
 func (d *IfDefDir) Pos() token.Pos   { return d.DirPos }
+func (d *IfDir) Pos() token.Pos      { return d.DirPos }
+func (d *ElifDir) Pos() token.Pos    { return d.DirPos }
 func (d *ElseDir) Pos() token.Pos    { return d.DirPos }
 func (d *EndIfDir) Pos() token.Pos   { return d.DirPos }
 
@@ -178,15 +373,28 @@ func (d *MacroDir) End() token.Pos {
 	}
 	return d.Name.End()
 }
+func (d *UndefDir) End() token.Pos   { return d.Name.End() }
 func (d *IncludeDir) End() token.Pos { return token.Pos(int(d.PathPos) + len(d.Path)) }
-func (d *IfDefDir) End() token.Pos   { return d.Name.End() }
-func (d *ElseDir) End() token.Pos    { return d.DirPos + 5 }
-func (d *EndIfDir) End() token.Pos   { return d.DirPos + 6 }
+func (d *PragmaDir) End() token.Pos {
+	if d.Text == "" {
+		return d.DirPos + 7
+	}
+	return token.Pos(int(d.TextPos) + len(d.Text))
+}
+func (d *IfDefDir) End() token.Pos { return d.Name.End() }
+func (d *IfDir) End() token.Pos    { return d.Cond.End() }
+func (d *ElifDir) End() token.Pos  { return d.Cond.End() }
+func (d *ElseDir) End() token.Pos  { return d.DirPos + 5 }
+func (d *EndIfDir) End() token.Pos { return d.DirPos + 6 }
 
 func (*BadDir) dirNode()     {}
 func (*MacroDir) dirNode()   {}
+func (*UndefDir) dirNode()   {}
 func (*IncludeDir) dirNode() {}
+func (*PragmaDir) dirNode()  {}
 func (*IfDefDir) dirNode()   {}
+func (*IfDir) dirNode()      {}
+func (*ElifDir) dirNode()    {}
 func (*ElseDir) dirNode()    {}
 func (*EndIfDir) dirNode()   {}
 
@@ -195,7 +403,6 @@ func (*EndIfDir) dirNode()   {}
 
 // A statement is represented by a tree consisting of one
 // or more of the following concrete statement nodes.
-//
 type (
 	// A BadStmt node is a placeholder for statements containing
 	// syntax errors for which no correct statement nodes can be
@@ -204,24 +411,57 @@ type (
 	BadStmt struct {
 		From, To token.Pos
 	}
+
+	// A BlockStmt node represents the body of a function definition.
+	// Its contents are not parsed.
+	BlockStmt struct {
+		Opening token.Pos
+		Closing token.Pos
+	}
 )
 
-func (s *BadStmt) Pos() token.Pos { return s.From }
+func (s *BadStmt) Pos() token.Pos   { return s.From }
+func (s *BlockStmt) Pos() token.Pos { return s.Opening }
 
-func (s *BadStmt) End() token.Pos { return s.To }
+func (s *BadStmt) End() token.Pos   { return s.To }
+func (s *BlockStmt) End() token.Pos { return s.Closing + 1 }
 
-func (*BadStmt) stmtNode() {}
+func (*BadStmt) stmtNode()   {}
+func (*BlockStmt) stmtNode() {}
 
 // ----------------------------------------------------------------------------
 // Declarations
 
 type (
+	// A TypeDecl node represents a typedef declaration.
 	TypeDecl struct {
-		KeyPos token.Pos
-		Decl   Decl
+		KeyPos token.Pos // position of "typedef" keyword
+		Type   Expr
 		Name   *Ident
 	}
 
+	// A StructDecl node represents a declaration of a struct, union
+	// or enum tag without any declarators, as in "struct foo { ... };".
+	StructDecl struct {
+		Type      Expr // *StructType or *EnumDecl
+		Semicolon token.Pos
+	}
+
+	// A FuncDecl node represents a function prototype, or a function
+	// definition if Body is not nil.
+	FuncDecl struct {
+		Doc  *CommentGroup // associated documentation; or nil
+		Name *Ident
+		Type *FuncType
+		Body *BlockStmt // or nil
+	}
+
+	// A VarDecl node represents a variable declaration.
+	VarDecl struct {
+		Type Expr
+		Name *Ident
+	}
+
 	ExternDecl struct {
 		KeyPos token.Pos
 		Decl   Decl
@@ -231,58 +471,309 @@ type (
 		Value   *BasicLit
 		BodyPos token.Pos
 	}
+
+	// An EndCDecl node represents the "}" closing the block of an
+	// extern "C" declaration.
+	EndCDecl struct {
+		Closing token.Pos
+	}
 )
 
 func (d *TypeDecl) Pos() token.Pos   { return d.KeyPos }
+func (d *StructDecl) Pos() token.Pos { return d.Type.Pos() }
+func (d *FuncDecl) Pos() token.Pos   { return d.Type.Pos() }
+func (d *VarDecl) Pos() token.Pos    { return d.Type.Pos() }
 func (d *ExternDecl) Pos() token.Pos { return d.KeyPos }
 func (d *CDecl) Pos() token.Pos      { return d.Value.Pos() }
+func (d *EndCDecl) Pos() token.Pos   { return d.Closing }
 
 func (d *TypeDecl) End() token.Pos   { return d.Name.End() }
-func (d *ExternDecl) End() token.Pos { return d.Decl.End() }
-func (d *CDecl) End() token.Pos      { return d.Value.End() }
+func (d *StructDecl) End() token.Pos { return d.Semicolon + 1 }
+func (d *FuncDecl) End() token.Pos {
+	if d.Body != nil {
+		return d.Body.End()
+	}
+	return d.Type.End()
+}
+func (d *VarDecl) End() token.Pos { return d.Name.End() }
+func (d *ExternDecl) End() token.Pos {
+	if d.Decl != nil {
+		return d.Decl.End()
+	}
+	return d.KeyPos + 6
+}
+func (d *CDecl) End() token.Pos    { return d.Value.End() }
+func (d *EndCDecl) End() token.Pos { return d.Closing + 1 }
 
 func (*TypeDecl) declNode()   {}
+func (*StructDecl) declNode() {}
+func (*FuncDecl) declNode()   {}
+func (*VarDecl) declNode()    {}
 func (*ExternDecl) declNode() {}
 func (*CDecl) declNode()      {}
+func (*EndCDecl) declNode()   {}
 
 // ----------------------------------------------------------------------------
-func (StructDecl) exprNode() {}
+// Types
+
+// A Field represents a struct or union member, or a function parameter.
+type Field struct {
+	Doc     *CommentGroup // associated documentation; or nil
+	Type    Expr
+	Name    *Ident        // or nil
+	Bits    Expr          // bit-field width; or nil
+	Attrs   []*Attribute  // attributes of the member; or nil
+	Comment *CommentGroup // line comment; or nil
+}
+
+func (f *Field) Pos() token.Pos { return f.Type.Pos() }
+func (f *Field) End() token.Pos {
+	end := f.Type.End()
+	if f.Name != nil && f.Name.End() > end {
+		end = f.Name.End()
+	}
+	if f.Bits != nil && f.Bits.End() > end {
+		end = f.Bits.End()
+	}
+	if n := len(f.Attrs); n > 0 && f.Attrs[n-1].End() > end {
+		end = f.Attrs[n-1].End()
+	}
+	return end
+}
+
+// A FieldList represents a list of Fields, enclosed by braces or
+// parentheses.
+type FieldList struct {
+	Opening token.Pos
+	List    []*Field
+	Closing token.Pos
+}
+
+func (f *FieldList) Pos() token.Pos { return f.Opening }
+func (f *FieldList) End() token.Pos { return f.Closing + 1 }
 
-type StructDecl struct {
-	Nodes []Expr
+// An Attribute represents a GNU attribute specifier, e.g.
+// __attribute__((packed, aligned(8))). Each attribute in the list is an
+// Ident, or a CallExpr for an attribute with arguments.
+type Attribute struct {
+	Attr    token.Pos // position of "__attribute__"
+	List    []Expr    // attributes
+	Closing token.Pos // position of the final ")"
 }
 
-type StructType struct {
-	Name Ident
+func (a *Attribute) Pos() token.Pos { return a.Attr }
+func (a *Attribute) End() token.Pos { return a.Closing + 1 }
+
+// A Qualifier is a set of type qualifiers.
+type Qualifier int
+
+const (
+	CONST Qualifier = 1 << iota
+	VOLATILE
+	RESTRICT
+)
+
+func (q Qualifier) String() string {
+	var s []string
+	if q&CONST != 0 {
+		s = append(s, "const")
+	}
+	if q&VOLATILE != 0 {
+		s = append(s, "volatile")
+	}
+	if q&RESTRICT != 0 {
+		s = append(s, "restrict")
+	}
+	return strings.Join(s, " ")
 }
 
-func (StructType) exprNode() {}
+// A type is represented by a tree consisting of one or more of the
+// following concrete type nodes, or an Ident for typedef names.
+//
+// Declarators are applied from the inside out, so "int (*cb)(void *)"
+// is a PointerType whose Elem is a FuncType returning a BasicType.
+type (
+	// A BasicType node represents a builtin scalar type. Name holds
+	// the canonical spelling, e.g. "unsigned long long" for
+	// "long long unsigned int".
+	BasicType struct {
+		From, To token.Pos // position range of the type specifiers
+		Name     string
+	}
+
+	// A PointerType node represents a pointer to Elem.
+	PointerType struct {
+		Star token.Pos // position of "*"
+		Elem Expr
+	}
+
+	// An ArrayType node represents an array of Elem. Len is nil for
+	// arrays of unknown size, such as flexible array members.
+	ArrayType struct {
+		Elem    Expr
+		Opening token.Pos // position of "["
+		Len     Expr      // or nil
+		Closing token.Pos // position of "]"
+	}
+
+	// A QualType node represents a qualified type.
+	QualType struct {
+		QualPos token.Pos // position of the first qualifier
+		Qual    Qualifier
+		Type    Expr
+	}
+
+	// A StructType node represents a struct or union type specifier.
+	StructType struct {
+		Struct token.Pos    // position of "struct" or "union" keyword
+		Kind   token.Token  // token.STRUCT or token.UNION
+		Name   *Ident       // struct tag; or nil
+		Fields *FieldList   // or nil, if the struct is only referenced
+		Attrs  []*Attribute // attributes of the type; or nil
+	}
+
+	// A FuncType node represents a function type.
+	FuncType struct {
+		Result Expr
+		Params *FieldList
+	}
+
+	// An Ellipsis node represents the "..." of a variadic parameter
+	// list.
+	Ellipsis struct {
+		Ellipsis token.Pos
+	}
+)
 
-type EnumDecl struct {
-	Specs []EnumSpec
+func (x *BasicType) Pos() token.Pos   { return x.From }
+func (x *PointerType) Pos() token.Pos { return minPos(x.Star, x.Elem.Pos()) }
+func (x *ArrayType) Pos() token.Pos   { return x.Elem.Pos() }
+func (x *QualType) Pos() token.Pos    { return minPos(x.QualPos, x.Type.Pos()) }
+func (x *StructType) Pos() token.Pos  { return x.Struct }
+func (x *FuncType) Pos() token.Pos    { return x.Result.Pos() }
+func (x *Ellipsis) Pos() token.Pos    { return x.Ellipsis }
+
+func (x *BasicType) End() token.Pos   { return x.To }
+func (x *PointerType) End() token.Pos { return maxPos(x.Star+1, x.Elem.End()) }
+func (x *ArrayType) End() token.Pos   { return maxPos(x.Closing+1, x.Elem.End()) }
+func (x *QualType) End() token.Pos    { return maxPos(x.QualPos, x.Type.End()) }
+
+func (x *StructType) End() token.Pos {
+	end := token.Pos(int(x.Struct) + len(x.Kind.String()))
+	switch {
+	case x.Fields != nil:
+		end = x.Fields.End()
+	case x.Name != nil:
+		end = x.Name.End()
+	}
+	if n := len(x.Attrs); n > 0 {
+		end = maxPos(end, x.Attrs[n-1].End())
+	}
+	return end
+}
+func (x *FuncType) End() token.Pos { return maxPos(x.Params.End(), x.Result.End()) }
+func (x *Ellipsis) End() token.Pos { return x.Ellipsis + 3 }
+
+func (*BasicType) exprNode()   {}
+func (*PointerType) exprNode() {}
+func (*ArrayType) exprNode()   {}
+func (*QualType) exprNode()    {}
+func (*StructType) exprNode()  {}
+func (*FuncType) exprNode()    {}
+func (*Ellipsis) exprNode()    {}
+
+// Declarators wrap their base type, so the positions of a type node do
+// not necessarily enclose its children in source order.
+
+func minPos(a, b token.Pos) token.Pos {
+	if a < b {
+		return a
+	}
+	return b
 }
 
-func (EnumDecl) exprNode() {}
+func maxPos(a, b token.Pos) token.Pos {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// ----------------------------------------------------------------------------
+// Enumerations
 
 type EnumSpec interface {
 	Node
 	enumSpecNode()
 }
 
-type EnumValue struct {
-	Name  Ident
-	Value *BasicLit
-}
+type (
+	// An EnumDecl node represents an enum type specifier.
+	EnumDecl struct {
+		Enum    token.Pos // position of "enum" keyword
+		Name    *Ident    // enum tag; or nil
+		Opening token.Pos // position of "{"
+		Specs   []EnumSpec
+		Closing token.Pos // position of "}"
+	}
+
+	// An EnumValue node represents an enumerator with an optional
+	// literal value.
+	EnumValue struct {
+		Doc     *CommentGroup // associated documentation; or nil
+		Name    *Ident
+		Value   *BasicLit     // or nil
+		Comment *CommentGroup // line comment; or nil
+	}
+
+	// An EnumConstExpr node represents an enumerator whose value is
+	// given by a constant expression.
+	EnumConstExpr struct {
+		Doc     *CommentGroup // associated documentation; or nil
+		Name    *Ident
+		Expr    Expr
+		Comment *CommentGroup // line comment; or nil
+	}
+)
 
-func (EnumValue) exprNode()     {}
-func (EnumValue) enumSpecNode() {}
+func (x *EnumDecl) Pos() token.Pos      { return x.Enum }
+func (x *EnumValue) Pos() token.Pos     { return x.Name.Pos() }
+func (x *EnumConstExpr) Pos() token.Pos { return x.Name.Pos() }
 
-type EnumConstExpr struct {
-	Name Ident
-	Expr Expr
+func (x *EnumDecl) End() token.Pos { return x.Closing + 1 }
+func (x *EnumValue) End() token.Pos {
+	if x.Value != nil {
+		return x.Value.End()
+	}
+	return x.Name.End()
 }
+func (x *EnumConstExpr) End() token.Pos { return x.Expr.End() }
+
+func (*EnumDecl) exprNode() {}
+
+func (*EnumValue) enumSpecNode()     {}
+func (*EnumConstExpr) enumSpecNode() {}
+
+// ----------------------------------------------------------------------------
+// Files
 
-func (EnumConstExpr) exprNode()     {}
-func (EnumConstExpr) enumSpecNode() {}
+// A File node represents a C source file.
+//
+// The Comments list contains all comments in the source file in order of
+// appearance, including the comments that are pointed to from other
+// nodes via Doc and Comment fields.
+//
+// The Scope and Unresolved fields are set in the ResolveObjects mode of
+// the parser only.
+type File struct {
+	Name       string          // file name
+	Nodes      []Node          // top-level directives, declarations and expressions
+	Comments   []*CommentGroup // list of all comments in the source file
+	FileStart  token.Pos       // start of entire file
+	FileEnd    token.Pos       // end of entire file
+	Scope      *Scope          // declarations in the file; or nil
+	Unresolved []*Ident        // unresolved identifiers in the file
+}
 
-func (BinaryExpr) enumSpecValue() {} // TODO: revert hack
+func (f *File) Pos() token.Pos { return f.FileStart }
+func (f *File) End() token.Pos { return f.FileEnd }
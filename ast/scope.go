@@ -0,0 +1,153 @@
+package ast
+
+import "github.com/SHyx0rmZ/cgen/token"
+
+// A Scope maintains the set of named objects declared in a file. C has
+// separate name spaces for ordinary identifiers, for the tags of
+// structs, unions and enums, and for macros.
+type Scope struct {
+	Objects map[string]*Object // typedef names, enumerators, functions and variables
+	Tags    map[string]*Object // struct, union and enum tags
+	Macros  map[string]*Object // macros that are defined
+}
+
+// NewScope creates a new scope.
+func NewScope() *Scope {
+	return &Scope{
+		Objects: make(map[string]*Object),
+		Tags:    make(map[string]*Object),
+		Macros:  make(map[string]*Object),
+	}
+}
+
+// names returns the name space of objects of kind.
+func (s *Scope) names(kind ObjKind) map[string]*Object {
+	switch kind {
+	case Tag:
+		return s.Tags
+	case Mac:
+		return s.Macros
+	}
+	return s.Objects
+}
+
+// Lookup returns the ordinary identifier with the given name if it is
+// found in scope s, otherwise it returns nil.
+func (s *Scope) Lookup(name string) *Object {
+	return s.Objects[name]
+}
+
+// LookupTag returns the struct, union or enum tag with the given name if
+// it is found in scope s, otherwise it returns nil.
+func (s *Scope) LookupTag(name string) *Object {
+	return s.Tags[name]
+}
+
+// LookupMacro returns the macro with the given name if it is defined in
+// scope s, otherwise it returns nil.
+func (s *Scope) LookupMacro(name string) *Object {
+	return s.Macros[name]
+}
+
+// Insert attempts to insert a named object obj into the name space of
+// its kind in scope s. If the name space already contains an object alt
+// with the same name, Insert leaves the scope unchanged and returns alt.
+// Otherwise it inserts obj and returns nil.
+func (s *Scope) Insert(obj *Object) (alt *Object) {
+	names := s.names(obj.Kind)
+	if alt = names[obj.Name]; alt == nil {
+		names[obj.Name] = obj
+	}
+	return
+}
+
+// Replace inserts obj into the name space of its kind in scope s,
+// replacing an object with the same name, and returns the replaced
+// object, if any.
+func (s *Scope) Replace(obj *Object) (alt *Object) {
+	names := s.names(obj.Kind)
+	alt = names[obj.Name]
+	names[obj.Name] = obj
+	return
+}
+
+// ----------------------------------------------------------------------------
+// Objects
+
+// An Object describes a named typedef, tag, enumerator, macro, function
+// or variable.
+//
+// The Decl field specifies the declaration of the object:
+//
+//	Kind    Decl
+//	Typ     *TypeDecl
+//	Tag     *StructType or *EnumDecl, the definition if there is one
+//	Con     *EnumValue or *EnumConstExpr
+//	Mac     *MacroDir
+//	Fun     *FuncDecl
+//	Var     *VarDecl
+type Object struct {
+	Kind ObjKind
+	Name string      // declared name
+	Decl interface{} // corresponding declaration; or nil
+}
+
+// NewObj creates a new object of a given kind and name.
+func NewObj(kind ObjKind, name string) *Object {
+	return &Object{Kind: kind, Name: name}
+}
+
+// Pos computes the source position of the declaration of an object name.
+// The result may be an invalid position if it cannot be computed
+// (obj.Decl may be nil or not correct).
+func (obj *Object) Pos() token.Pos {
+	var name *Ident
+	switch d := obj.Decl.(type) {
+	case *TypeDecl:
+		name = d.Name
+	case *StructType:
+		name = d.Name
+	case *EnumDecl:
+		name = d.Name
+	case *EnumValue:
+		name = d.Name
+	case *EnumConstExpr:
+		name = d.Name
+	case *MacroDir:
+		name = d.Name
+	case *FuncDecl:
+		name = d.Name
+	case *VarDecl:
+		name = d.Name
+	}
+	if name == nil {
+		return token.NoPos
+	}
+	return name.Pos()
+}
+
+// ObjKind describes what an object represents.
+type ObjKind int
+
+// The list of possible Object kinds.
+const (
+	Bad ObjKind = iota // for error handling
+	Typ                // typedef name
+	Tag                // struct, union or enum tag
+	Con                // enumerator
+	Mac                // macro
+	Fun                // function
+	Var                // variable
+)
+
+var objKindStrings = [...]string{
+	Bad: "bad",
+	Typ: "type",
+	Tag: "tag",
+	Con: "const",
+	Mac: "macro",
+	Fun: "func",
+	Var: "var",
+}
+
+func (kind ObjKind) String() string { return objKindStrings[kind] }
@@ -0,0 +1,288 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+func walkIdentList(v Visitor, list []*Ident) {
+	for _, x := range list {
+		Walk(v, x)
+	}
+}
+
+func walkExprList(v Visitor, list []Expr) {
+	for _, x := range list {
+		Walk(v, x)
+	}
+}
+
+// Walk traverses a syntax tree in depth-first order: It starts by
+// calling v.Visit(node); node must not be nil. If the visitor w returned
+// by v.Visit(node) is not nil, Walk is invoked recursively with visitor
+// w for each of the non-nil children of node, followed by a call of
+// w.Visit(nil).
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	// walk children
+	// (the order of the cases matches the order
+	// of the corresponding node types in ast.go)
+	switch n := node.(type) {
+	// Comments
+	case *Comment:
+		// nothing to do
+
+	case *CommentGroup:
+		for _, c := range n.List {
+			Walk(v, c)
+		}
+
+	// Expressions
+	case *BadExpr, *Ident, *BasicLit:
+		// nothing to do
+
+	case *UnaryExpr:
+		Walk(v, n.X)
+
+	case *BinaryExpr:
+		Walk(v, n.X)
+		Walk(v, n.Y)
+
+	case *ParenExpr:
+		if n.Expr != nil {
+			Walk(v, n.Expr)
+		}
+
+	case *CondExpr:
+		Walk(v, n.Cond)
+		Walk(v, n.X)
+		Walk(v, n.Y)
+
+	case *CastExpr:
+		Walk(v, n.Type)
+		Walk(v, n.X)
+
+	case *IndexExpr:
+		Walk(v, n.X)
+		Walk(v, n.Index)
+
+	case *CallExpr:
+		Walk(v, n.Fun)
+		walkExprList(v, n.Args)
+
+	case *SelectorExpr:
+		Walk(v, n.X)
+		Walk(v, n.Sel)
+
+	case *PostfixExpr:
+		Walk(v, n.X)
+
+	case *SizeofExpr:
+		Walk(v, n.X)
+
+	case *DefinedExpr:
+		Walk(v, n.Name)
+
+	// Directives
+	case *ArgList:
+		walkIdentList(v, n.List)
+		if n.Ellipsis != nil {
+			Walk(v, n.Ellipsis)
+		}
+
+	case *BadDir, *IncludeDir, *PragmaDir, *ElseDir, *EndIfDir:
+		// nothing to do
+
+	case *MacroDir:
+		if n.Doc != nil {
+			Walk(v, n.Doc)
+		}
+		Walk(v, n.Name)
+		if n.Args != nil {
+			Walk(v, n.Args)
+		}
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+		if n.Comment != nil {
+			Walk(v, n.Comment)
+		}
+
+	case *UndefDir:
+		Walk(v, n.Name)
+
+	case *IfDefDir:
+		Walk(v, n.Name)
+
+	case *IfDir:
+		Walk(v, n.Cond)
+
+	case *ElifDir:
+		Walk(v, n.Cond)
+
+	// Statements
+	case *BadStmt, *BlockStmt:
+		// nothing to do
+
+	// Declarations
+	case *TypeDecl:
+		Walk(v, n.Type)
+		Walk(v, n.Name)
+
+	case *StructDecl:
+		Walk(v, n.Type)
+
+	case *FuncDecl:
+		if n.Doc != nil {
+			Walk(v, n.Doc)
+		}
+		Walk(v, n.Type)
+		Walk(v, n.Name)
+		if n.Body != nil {
+			Walk(v, n.Body)
+		}
+
+	case *VarDecl:
+		Walk(v, n.Type)
+		Walk(v, n.Name)
+
+	case *ExternDecl:
+		if n.Decl != nil {
+			Walk(v, n.Decl)
+		}
+
+	case *CDecl:
+		Walk(v, n.Value)
+
+	case *EndCDecl:
+		// nothing to do
+
+	// Types
+	case *Field:
+		if n.Doc != nil {
+			Walk(v, n.Doc)
+		}
+		Walk(v, n.Type)
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		if n.Bits != nil {
+			Walk(v, n.Bits)
+		}
+		for _, a := range n.Attrs {
+			Walk(v, a)
+		}
+		if n.Comment != nil {
+			Walk(v, n.Comment)
+		}
+
+	case *FieldList:
+		for _, f := range n.List {
+			Walk(v, f)
+		}
+
+	case *Attribute:
+		walkExprList(v, n.List)
+
+	case *BasicType, *Ellipsis:
+		// nothing to do
+
+	case *PointerType:
+		Walk(v, n.Elem)
+
+	case *ArrayType:
+		Walk(v, n.Elem)
+		if n.Len != nil {
+			Walk(v, n.Len)
+		}
+
+	case *QualType:
+		Walk(v, n.Type)
+
+	case *StructType:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		if n.Fields != nil {
+			Walk(v, n.Fields)
+		}
+		for _, a := range n.Attrs {
+			Walk(v, a)
+		}
+
+	case *FuncType:
+		Walk(v, n.Result)
+		if n.Params != nil {
+			Walk(v, n.Params)
+		}
+
+	// Enumerations
+	case *EnumDecl:
+		if n.Name != nil {
+			Walk(v, n.Name)
+		}
+		for _, s := range n.Specs {
+			Walk(v, s)
+		}
+
+	case *EnumValue:
+		if n.Doc != nil {
+			Walk(v, n.Doc)
+		}
+		Walk(v, n.Name)
+		if n.Value != nil {
+			Walk(v, n.Value)
+		}
+		if n.Comment != nil {
+			Walk(v, n.Comment)
+		}
+
+	case *EnumConstExpr:
+		if n.Doc != nil {
+			Walk(v, n.Doc)
+		}
+		Walk(v, n.Name)
+		Walk(v, n.Expr)
+		if n.Comment != nil {
+			Walk(v, n.Comment)
+		}
+
+	// Files
+	case *File:
+		for _, x := range n.Nodes {
+			Walk(v, x)
+		}
+		// don't walk n.Comments - they have been
+		// visited already through the individual
+		// nodes
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a syntax tree in depth-first order: It starts by
+// calling f(node); node must not be nil. If f returns true, Inspect
+// invokes f recursively for each of the non-nil children of node,
+// followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
@@ -0,0 +1,118 @@
+package ast_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/parser"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+func TestInspect(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value string
+	}{
+		{
+			"#define MAX(a, b) ((a) > (b) ? (a) : (b))",
+			"MacroDir Ident ArgList Ident Ident ParenExpr CondExpr BinaryExpr ParenExpr Ident ParenExpr Ident ParenExpr Ident ParenExpr Ident",
+		},
+		{
+			"#if defined(FOO) && !BAR\n#endif",
+			"IfDir BinaryExpr DefinedExpr Ident UnaryExpr Ident EndIfDir",
+		},
+		{
+			"// Doc.\n#define N sizeof(int) /* bytes */",
+			"MacroDir CommentGroup Comment Ident SizeofExpr BasicType CommentGroup Comment",
+		},
+		{
+			"struct s { int x : 3; char *p[N]; };",
+			"StructDecl StructType Ident FieldList Field BasicType Ident BasicLit Field ArrayType PointerType BasicType Ident Ident",
+		},
+		{
+			"enum e { A, B = A + 1 };",
+			"StructDecl EnumDecl Ident EnumValue Ident EnumConstExpr Ident BinaryExpr Ident BasicLit",
+		},
+		{
+			"typedef const int (*f)(void *, ...);",
+			"TypeDecl PointerType FuncType QualType BasicType FieldList Field PointerType BasicType Field Ellipsis Ident",
+		},
+		{
+			"extern int n;",
+			"ExternDecl VarDecl BasicType Ident",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			p := parser.NewParser("test.h", test.Input)
+			nodes := p.Nodes()
+			if err := p.Err(); err != nil {
+				t.Fatal(err)
+			}
+			var kinds []string
+			for _, node := range nodes {
+				ast.Inspect(node, func(n ast.Node) bool {
+					if n != nil {
+						kinds = append(kinds, strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast."))
+					}
+					return true
+				})
+			}
+			if got := strings.Join(kinds, " "); got != test.Value {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.Value)
+			}
+		})
+	}
+}
+
+func TestInspect_Prune(t *testing.T) {
+	p := parser.NewParser("test.h", "#define A (B + C)\n#define D E")
+	var names []string
+	for _, node := range p.Nodes() {
+		ast.Inspect(node, func(n ast.Node) bool {
+			if id, ok := n.(*ast.Ident); ok {
+				names = append(names, id.Name)
+			}
+			_, paren := n.(*ast.ParenExpr)
+			return !paren
+		})
+	}
+	if got := strings.Join(names, " "); got != "A D E" {
+		t.Errorf("got %q, want %q", got, "A D E")
+	}
+}
+
+func TestFprint(t *testing.T) {
+	x := &ast.BinaryExpr{
+		X:     &ast.Ident{NamePos: 1, Name: "a"},
+		OpPos: 3,
+		Op:    token.ADD,
+		Y:     &ast.BasicLit{ValuePos: 5, Kind: token.INT, Value: "1"},
+	}
+	var buf bytes.Buffer
+	if err := ast.Fprint(&buf, nil, x, ast.NotNilFilter); err != nil {
+		t.Fatal(err)
+	}
+	want := `     0  *ast.BinaryExpr {
+     1  .  X: *ast.Ident {
+     2  .  .  NamePos: 1
+     3  .  .  Name: "a"
+     4  .  }
+     5  .  OpPos: 3
+     6  .  Op: +
+     7  .  Y: *ast.BasicLit {
+     8  .  .  ValuePos: 5
+     9  .  .  Kind: INT
+    10  .  .  Value: "1"
+    11  .  .  Suffix: ""
+    12  .  }
+    13  }
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%swant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,106 @@
+package astjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/parser"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+const src = `#include <stdint.h>
+#ifndef FOO_H
+// Maximum length.
+#define FOO_MAX (1 << 4) // in bytes
+#define FOO_CALL(f, ...) f(__VA_ARGS__)
+#endif
+
+typedef enum { RED, GREEN = RED | 0x10 } color;
+
+/* A header. */
+struct hdr {
+	const volatile char *name;
+	unsigned len : 12;
+	int data[];
+};
+
+extern int foo_open(const char *path, ...);
+static inline int foo_zero(void) { return 0; }
+`
+
+func TestRoundTrip(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "foo.h", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := []ast.Node{f}
+
+	buf := new(bytes.Buffer)
+	if err := Encode(buf, fset, nodes); err != nil {
+		t.Fatal(err)
+	}
+	fset2, got, err := Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, nodes) {
+		t.Errorf("decoded nodes differ from encoded nodes")
+	}
+
+	hdr := f.Nodes[len(f.Nodes)-3].(*ast.StructDecl).Type.(*ast.StructType)
+	for _, p := range []token.Pos{f.Nodes[0].Pos(), hdr.Fields.List[1].Name.Pos(), f.End()} {
+		if got, want := fset2.Position(p), fset.Position(p); got != want {
+			t.Errorf("Position(%d) = %s, want %s", p, got, want)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	nodes := parser.NewParser("test.h", "#define A -x").Nodes()
+	want := `{"files":[],"nodes":[{"kind":"MacroDir","Doc":null,"DirPos":null,"Name":{"kind":"Ident","NamePos":{"offset":8},"Name":"A"},"Args":null,` +
+		`"Value":{"kind":"UnaryExpr","OpPos":{"offset":10},"Op":"-","X":{"kind":"Ident","NamePos":{"offset":11},"Name":"x"}},"Comment":null}]}`
+
+	buf := new(bytes.Buffer)
+	if err := Encode(buf, nil, nodes); err != nil {
+		t.Fatal(err)
+	}
+	compact := new(bytes.Buffer)
+	if err := json.Compact(compact, buf.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if compact.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", compact, want)
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value string
+	}{
+		{`{"nodes": [{"kind": "Foo"}]}`, `astjson: nodes[0]: unknown kind "Foo"`},
+		{`{"nodes": [{"kind": "UnaryExpr", "Op": "+", "X": {"kind": "TypeDecl"}}]}`, `astjson: nodes[0].X: unexpected kind TypeDecl`},
+		{`{"nodes": [{"kind": "UnaryExpr", "Op": "plus"}]}`, `astjson: nodes[0].Op: invalid token "plus"`},
+		{`{"nodes": [{"kind": "Ident", "NamePos": {"file": "a.h", "offset": 1}}]}`, `astjson: nodes[0].NamePos: unknown file a.h`},
+		{`{"nodes": [{"kind": "Ident", "Name": 1}]}`, `astjson: nodes[0].Name: expected string, found 1`},
+		{`{"nodes": [{"kind": "CallExpr", "Args": [null, {"kind": "Ident", "NamePos": {}}]}]}`, `astjson: nodes[0].Args[1].NamePos.offset: expected integer, found null`},
+		{`{"nodes": [`, `astjson: unexpected EOF`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			_, _, err := Decode(strings.NewReader(test.Input))
+			if err == nil {
+				t.Fatalf("got no error, want %q", test.Value)
+			}
+			if err.Error() != test.Value {
+				t.Errorf("got %q, want %q", err, test.Value)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package astjson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+// kinds maps the kinds of the objects in a document to the struct types
+// of package ast.
+var kinds = make(map[string]reflect.Type)
+
+// tokens maps the spellings of tokens to tokens. RESTRICT is the last
+// token.
+var tokens = make(map[string]token.Token)
+
+func init() {
+	for _, x := range []interface{}{
+		ast.Comment{}, ast.CommentGroup{},
+		ast.BadExpr{}, ast.Ident{}, ast.BasicLit{}, ast.UnaryExpr{}, ast.BinaryExpr{},
+		ast.ParenExpr{}, ast.CondExpr{}, ast.CastExpr{}, ast.IndexExpr{}, ast.CallExpr{},
+		ast.SelectorExpr{}, ast.PostfixExpr{}, ast.SizeofExpr{}, ast.DefinedExpr{},
+		ast.ArgList{}, ast.BadDir{}, ast.MacroDir{}, ast.UndefDir{}, ast.IncludeDir{},
+		ast.PragmaDir{}, ast.IfDefDir{}, ast.IfDir{}, ast.ElifDir{}, ast.ElseDir{}, ast.EndIfDir{},
+		ast.BadStmt{}, ast.BlockStmt{},
+		ast.TypeDecl{}, ast.StructDecl{}, ast.FuncDecl{}, ast.VarDecl{}, ast.ExternDecl{}, ast.CDecl{},
+		ast.EndCDecl{},
+		ast.Field{}, ast.FieldList{}, ast.Attribute{}, ast.BasicType{}, ast.PointerType{}, ast.ArrayType{},
+		ast.QualType{}, ast.StructType{}, ast.FuncType{}, ast.Ellipsis{},
+		ast.EnumDecl{}, ast.EnumValue{}, ast.EnumConstExpr{},
+		ast.File{},
+	} {
+		t := reflect.TypeOf(x)
+		kinds[t.Name()] = t
+	}
+	for tok := token.ILLEGAL; tok <= token.RESTRICT; tok++ {
+		tokens[tok.String()] = tok
+	}
+}
+
+// Decode reads a JSON document written by Encode from r. It returns the
+// nodes and a file set holding the files their positions refer to.
+// Positions in a file that occurs in the files table more than once
+// refer to its first occurrence.
+func Decode(r io.Reader) (*token.FileSet, []ast.Node, error) {
+	var doc struct {
+		Files []struct {
+			Name  string
+			Base  int
+			Size  int
+			Lines []int
+		}
+		Nodes []interface{}
+	}
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("astjson: %s", err)
+	}
+
+	d := &decoder{fset: token.NewFileSet(), files: make(map[string]*token.File)}
+	for _, f := range doc.Files {
+		if f.Base < d.fset.Base() || f.Size < 0 {
+			return nil, nil, fmt.Errorf("astjson: invalid base or size of file %s", f.Name)
+		}
+		file := d.fset.AddFile(f.Name, f.Base, f.Size)
+		for _, line := range f.Lines {
+			file.AddLine(line)
+		}
+		if d.files[f.Name] == nil {
+			d.files[f.Name] = file
+		}
+	}
+
+	nodeType := reflect.TypeOf((*ast.Node)(nil)).Elem()
+	nodes := make([]ast.Node, 0, len(doc.Nodes))
+	for i, x := range doc.Nodes {
+		v, err := d.value(x, nodeType)
+		if err != nil {
+			return nil, nil, fmt.Errorf("astjson: nodes[%d]%s", i, err)
+		}
+		node, _ := v.Interface().(ast.Node)
+		nodes = append(nodes, node)
+	}
+	return d.fset, nodes, nil
+}
+
+type decoder struct {
+	fset  *token.FileSet
+	files map[string]*token.File
+}
+
+// A pathError is an error in the value at a path in a document.
+type pathError struct {
+	path string
+	msg  string
+}
+
+func (e *pathError) Error() string { return e.path + ": " + e.msg }
+
+// at prefixes the path of err with elem.
+func at(elem string, err error) error {
+	if e, ok := err.(*pathError); ok {
+		return &pathError{elem + e.path, e.msg}
+	}
+	return &pathError{elem, err.Error()}
+}
+
+func errorf(format string, args ...interface{}) error {
+	return &pathError{"", fmt.Sprintf(format, args...)}
+}
+
+func (d *decoder) pos(x interface{}) (token.Pos, error) {
+	if x == nil {
+		return token.NoPos, nil
+	}
+	m, ok := x.(map[string]interface{})
+	if !ok {
+		return token.NoPos, errorf("position must be an object")
+	}
+	offset, err := d.int(m["offset"])
+	if err != nil {
+		return token.NoPos, at(".offset", err)
+	}
+	name, ok := m["file"].(string)
+	if !ok {
+		return token.Pos(offset), nil
+	}
+	f := d.files[name]
+	if f == nil {
+		return token.NoPos, errorf("unknown file %s", name)
+	}
+	if offset < 0 || offset > int64(f.Size()) {
+		return token.NoPos, errorf("offset %d out of range", offset)
+	}
+	return f.Pos(int(offset)), nil
+}
+
+func (d *decoder) int(x interface{}) (int64, error) {
+	n, ok := x.(json.Number)
+	if !ok {
+		return 0, errorf("expected integer, found %s", describe(x))
+	}
+	i, err := n.Int64()
+	if err != nil {
+		return 0, errorf("expected integer, found %s", n)
+	}
+	return i, nil
+}
+
+// value returns the value of type t represented by x.
+func (d *decoder) value(x interface{}, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	switch t {
+	case posType:
+		p, err := d.pos(x)
+		v.SetInt(int64(p))
+		return v, err
+	case tokenType:
+		s, ok := x.(string)
+		tok, known := tokens[s]
+		if !ok || !known {
+			return v, errorf("invalid token %s", describe(x))
+		}
+		v.SetInt(int64(tok))
+		return v, nil
+	case qualifierType:
+		s, ok := x.(string)
+		if !ok {
+			return v, errorf("invalid qualifier %s", describe(x))
+		}
+		var q ast.Qualifier
+		for _, word := range strings.Fields(s) {
+			switch word {
+			case "const":
+				q |= ast.CONST
+			case "volatile":
+				q |= ast.VOLATILE
+			case "restrict":
+				q |= ast.RESTRICT
+			default:
+				return v, errorf("invalid qualifier %s", word)
+			}
+		}
+		v.SetInt(int64(q))
+		return v, nil
+	}
+
+	if x == nil {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Slice:
+			return v, nil
+		}
+		return v, errorf("unexpected null")
+	}
+	switch t.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		m, ok := x.(map[string]interface{})
+		if !ok {
+			return v, errorf("expected object, found %s", describe(x))
+		}
+		kind, _ := m["kind"].(string)
+		st := kinds[kind]
+		if st == nil {
+			return v, errorf("unknown kind %q", kind)
+		}
+		if t.Kind() == reflect.Ptr && t.Elem() != st || t.Kind() == reflect.Interface && !reflect.PtrTo(st).Implements(t) {
+			return v, errorf("unexpected kind %s", kind)
+		}
+		p := reflect.New(st)
+		for i := 0; i < st.NumField(); i++ {
+			f := st.Field(i)
+			if f.PkgPath != "" || resolved(f) {
+				continue
+			}
+			fv, err := d.value(m[f.Name], f.Type)
+			if err != nil {
+				return v, at("."+f.Name, err)
+			}
+			p.Elem().Field(i).Set(fv)
+		}
+		v.Set(p)
+	case reflect.Slice:
+		list, ok := x.([]interface{})
+		if !ok {
+			return v, errorf("expected array, found %s", describe(x))
+		}
+		v.Set(reflect.MakeSlice(t, len(list), len(list)))
+		for i, y := range list {
+			ev, err := d.value(y, t.Elem())
+			if err != nil {
+				return v, at(fmt.Sprintf("[%d]", i), err)
+			}
+			v.Index(i).Set(ev)
+		}
+	case reflect.String:
+		s, ok := x.(string)
+		if !ok {
+			return v, errorf("expected string, found %s", describe(x))
+		}
+		v.SetString(s)
+	case reflect.Int:
+		i, err := d.int(x)
+		if err != nil {
+			return v, err
+		}
+		v.SetInt(i)
+	default:
+		return v, errorf("cannot decode %s", t)
+	}
+	return v, nil
+}
+
+// describe returns a description of the JSON value x for error messages.
+func describe(x interface{}) string {
+	switch x := x.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return fmt.Sprintf("%q", x)
+	}
+	return fmt.Sprint(x)
+}
@@ -0,0 +1,184 @@
+// Package astjson encodes C syntax trees as JSON, for tools that are not
+// written in Go, and decodes them again, e.g. to cache parses.
+//
+// A document has the form
+//
+//	{
+//		"files": [{"name": "foo.h", "base": 1, "size": 120, "lines": [0, 18, 40]}],
+//		"nodes": [{"kind": "MacroDir", "Doc": null, "DirPos": {...}, ...}]
+//	}
+//
+// Every node, and every other struct of package ast, is an object with a
+// "kind" naming its Go type, followed by the fields of the struct under
+// their Go names. Nil pointers, interfaces and slices are null.
+//
+// Positions are objects like {"file": "foo.h", "line": 2, "column": 9,
+// "offset": 26}, where the offset is the byte offset within the file,
+// and NoPos is null. Positions in syntax trees parsed without a file set
+// only have an offset. Tokens are spelled as in C, e.g. "|" or "struct",
+// and qualifiers as in "const volatile".
+//
+// The files table holds the files the positions refer to, with the
+// offsets of their lines, so that Decode can rebuild the positions.
+//
+// The objects identifiers are resolved to, and the scope and unresolved
+// identifiers of a File, are not encoded, since objects refer back to
+// the nodes declaring them. Decoded identifiers are unresolved.
+package astjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+var (
+	posType       = reflect.TypeOf(token.NoPos)
+	tokenType     = reflect.TypeOf(token.ILLEGAL)
+	qualifierType = reflect.TypeOf(ast.CONST)
+	objectType    = reflect.TypeOf((*ast.Object)(nil))
+	scopeType     = reflect.TypeOf((*ast.Scope)(nil))
+)
+
+// resolved reports whether the field f holds the result of resolving
+// identifiers, which is not encoded.
+func resolved(f reflect.StructField) bool {
+	return f.Type == objectType || f.Type == scopeType || f.Name == "Unresolved"
+}
+
+// An object is a JSON object that keeps the order of its members.
+type object []member
+
+type member struct {
+	key   string
+	value interface{}
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(m.key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Encode writes the JSON document of nodes to w. The positions of the
+// nodes are resolved in fset, which may be nil for nodes parsed without
+// a file set.
+func Encode(w io.Writer, fset *token.FileSet, nodes []ast.Node) error {
+	e := &encoder{fset: fset, files: make(map[*token.File]bool)}
+	list := make([]interface{}, 0, len(nodes))
+	for i := range nodes {
+		list = append(list, e.value(reflect.ValueOf(&nodes[i]).Elem()))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(object{{"files", e.fileTable()}, {"nodes", list}})
+}
+
+type encoder struct {
+	fset  *token.FileSet
+	files map[*token.File]bool // files positions refer to
+}
+
+// fileTable returns the files positions refer to, ordered by base.
+func (e *encoder) fileTable() []interface{} {
+	var files []*token.File
+	for f := range e.files {
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Base() < files[j].Base() })
+	table := make([]interface{}, 0, len(files))
+	for _, f := range files {
+		table = append(table, object{
+			{"name", f.Name()},
+			{"base", f.Base()},
+			{"size", f.Size()},
+			{"lines", f.Lines()},
+		})
+	}
+	return table
+}
+
+func (e *encoder) pos(p token.Pos) interface{} {
+	if !p.IsValid() {
+		return nil
+	}
+	var f *token.File
+	if e.fset != nil {
+		f = e.fset.File(p)
+	}
+	if f == nil {
+		return object{{"offset", int(p)}}
+	}
+	e.files[f] = true
+	position := f.Position(p)
+	return object{
+		{"file", position.Filename},
+		{"line", position.Line},
+		{"column", position.Column},
+		{"offset", position.Offset},
+	}
+}
+
+// value returns the JSON representation of the value v of a field of a
+// node.
+func (e *encoder) value(v reflect.Value) interface{} {
+	switch v.Type() {
+	case posType:
+		return e.pos(token.Pos(v.Int()))
+	case tokenType:
+		return token.Token(v.Int()).String()
+	case qualifierType:
+		return ast.Qualifier(v.Int()).String()
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return e.value(v.Elem())
+	case reflect.Struct:
+		o := object{{"kind", v.Type().Name()}}
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.PkgPath == "" && !resolved(f) {
+				o = append(o, member{f.Name, e.value(v.Field(i))})
+			}
+		}
+		return o
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		list := make([]interface{}, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			list = append(list, e.value(v.Index(i)))
+		}
+		return list
+	case reflect.Int:
+		return v.Int()
+	}
+	return v.Interface()
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/config"
+	"github.com/SHyx0rmZ/cgen/gen"
+	"github.com/SHyx0rmZ/cgen/parser"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+func genMain(args []string) {
+	var pf ppFlags
+	flags := flag.NewFlagSet("gen", flag.ExitOnError)
+	pf.register(flags)
+	pkg := flags.String("package", "main", "name of the generated package")
+	out := flags.String("o", "", "write output to `file` instead of stdout")
+	test := flags.String("test", "", "write a test of the struct layouts to `file`")
+	cgo := flags.String("cgo", "", "write cgo wrappers of the functions to `file`")
+	header := flags.String("header", "", "`header` included by the cgo wrappers (default file.h)")
+	trimPrefix := flags.String("trimprefix", "", "remove `prefix` from the names of wrapped functions")
+	var boolPrefixes listFlag
+	flags.Var(&boolPrefixes, "boolprefix", "wrap functions starting with `prefix` as returning bool")
+	errors := flags.String("errors", "none", "error convention of integer results: none, nonzero, negative or errno")
+	enums := flags.Bool("enums", false, "group macros and enumerators sharing a prefix into named types")
+	rulesFile := flags.String("rules", "", "rename, filter and retype declarations following the rules in `file`")
+	targets := flags.String("targets", "", "generate for the comma-separated `targets`, splitting the declarations that differ into file_goos_goarch.go files; requires -o")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: cgen gen [flags] file.h\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	if *targets != "" && (*out == "" || pf.target != "") {
+		fmt.Fprintf(os.Stderr, "cgen: -targets requires -o and excludes -target\n")
+		os.Exit(2)
+	}
+
+	conventions := map[string]gen.ErrorConvention{
+		"none":     gen.NoErrors,
+		"nonzero":  gen.NonZeroErrors,
+		"negative": gen.NegativeErrors,
+		"errno":    gen.ErrnoErrors,
+	}
+	errorConvention, ok := conventions[*errors]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "cgen: unknown error convention %q\n", *errors)
+		os.Exit(2)
+	}
+
+	var rules *config.Rules
+	if *rulesFile != "" {
+		var err error
+		rules, err = config.ReadFile(*rulesFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cgen: %s\n", err)
+			os.Exit(2)
+		}
+	}
+
+	cfg := &gen.Config{
+		Package: *pkg,
+		Source:  filepath.Base(flags.Arg(0)),
+		Header:  *header,
+		Cgo: gen.Conventions{
+			TrimPrefix:   *trimPrefix,
+			BoolPrefixes: boolPrefixes,
+			Errors:       errorConvention,
+		},
+		Enums: *enums,
+		Rules: rules,
+	}
+	outputs := []genOutput{
+		{*out, (*gen.Config).Generate},
+		{*test, (*gen.Config).GenerateTest},
+		{*cgo, (*gen.Config).GenerateCgo},
+	}
+	pf.resolve = true
+	if *targets != "" {
+		genTargets(cfg, flags.Arg(0), &pf, rules, strings.Split(*targets, ","), outputs)
+		return
+	}
+
+	nodes, fset := parseFile(flags.Arg(0), &pf)
+	nodes = filterFiles(nodes, fset, rules)
+	if t := pf.parseTarget(); t != nil {
+		cfg.Sizes, cfg.Model = t.Sizes, t.Model()
+	}
+	cfg.Predefined = predefinedMacros(pf.predefined())
+	if *out == "" {
+		if err := cfg.Generate(os.Stdout, nodes); err != nil {
+			panic(err)
+		}
+	}
+	for _, o := range outputs {
+		if o.name == "" {
+			continue
+		}
+		buf := new(bytes.Buffer)
+		if err := o.generate(cfg, buf, nodes); err != nil {
+			panic(err)
+		}
+		writeFile(o.name, buf.Bytes())
+	}
+}
+
+// A genOutput is a file written by cgen gen.
+type genOutput struct {
+	name     string // or "", if the file is not written
+	generate func(c *gen.Config, w io.Writer, nodes []ast.Node) error
+}
+
+// genTargets parses the file filename once for each of the targets and
+// writes the outputs. The declarations that are the same for all targets
+// are written to the output files, the others to files for the targets,
+// named like file_linux_amd64.go, with matching build constraints.
+func genTargets(cfg *gen.Config, filename string, pf *ppFlags, rules *config.Rules, targets []string, outputs []genOutput) {
+	srcs := make([][][]byte, len(outputs))
+	var ports, constraints []string
+	for _, name := range targets {
+		pf.target = name
+		t := pf.parseTarget()
+		port := t.GOOS + "_" + t.GOARCH
+		for _, p := range ports {
+			if p == port {
+				fmt.Fprintf(os.Stderr, "cgen: duplicate target %s/%s\n", t.GOOS, t.GOARCH)
+				os.Exit(2)
+			}
+		}
+		ports = append(ports, port)
+		constraints = append(constraints, t.GOOS+" && "+t.GOARCH)
+
+		nodes, fset := parseFile(filename, pf)
+		nodes = filterFiles(nodes, fset, rules)
+		c := *cfg
+		c.Sizes, c.Model = t.Sizes, t.Model()
+		c.Predefined = predefinedMacros(pf.predefined())
+		for i, o := range outputs {
+			if o.name == "" {
+				continue
+			}
+			buf := new(bytes.Buffer)
+			if err := o.generate(&c, buf, nodes); err != nil {
+				panic(err)
+			}
+			srcs[i] = append(srcs[i], buf.Bytes())
+		}
+	}
+
+	for i, o := range outputs {
+		if o.name == "" {
+			continue
+		}
+		shared, specific, err := gen.SplitTargets(srcs[i], constraints)
+		if err != nil {
+			panic(err)
+		}
+		if shared != nil {
+			writeFile(o.name, shared)
+		}
+		for j, src := range specific {
+			if src != nil {
+				writeFile(targetFile(o.name, ports[j]), src)
+			}
+		}
+	}
+}
+
+// targetFile returns the name of the file for the port goos_goarch
+// derived from the file name, e.g. z_linux_amd64.go for z.go and
+// z_linux_amd64_test.go for z_test.go.
+func targetFile(name, port string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	if strings.HasSuffix(base, "_test") {
+		return strings.TrimSuffix(base, "_test") + "_" + port + "_test" + ext
+	}
+	return base + "_" + port + ext
+}
+
+// writeFile writes the generated source src to the file name.
+func writeFile(name string, src []byte) {
+	if err := os.WriteFile(name, src, 0666); err != nil {
+		fmt.Fprintf(os.Stderr, "cgen: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// predefinedMacros returns the macro definitions for the macros defs,
+// given in the syntax of -D.
+func predefinedMacros(defs []string) []*ast.MacroDir {
+	var macros []*ast.MacroDir
+	for _, def := range defs {
+		name, value, ok := strings.Cut(def, "=")
+		if !ok {
+			value = "1"
+		}
+		for _, node := range parser.NewParser("<command-line>", "#define "+name+" "+value+"\n").Nodes() {
+			if m, ok := node.(*ast.MacroDir); ok {
+				macros = append(macros, m)
+			}
+		}
+	}
+	return macros
+}
+
+// filterFiles returns the nodes declared in the files kept by rules.
+func filterFiles(nodes []ast.Node, fset *token.FileSet, rules *config.Rules) []ast.Node {
+	var kept []ast.Node
+	for _, node := range nodes {
+		if f := fset.File(node.Pos()); f == nil || rules.KeepFile(f.Name()) {
+			kept = append(kept, node)
+		}
+	}
+	return kept
+}
@@ -1,57 +1,189 @@
 package main
 
 import (
-	"bytes"
-	//"fmt"
+	"flag"
+	"fmt"
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/astjson"
 	"github.com/SHyx0rmZ/cgen/parser"
-	"go/ast"
-	"io"
+	"github.com/SHyx0rmZ/cgen/preprocessor"
+	"github.com/SHyx0rmZ/cgen/target"
+	"github.com/SHyx0rmZ/cgen/token"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
+const usage = `usage: cgen [command] [flags] file.h
+
+commands:
+  dump    print the parsed nodes (default)
+  gen     generate Go constants
+`
+
 func main() {
-	f, err := os.Open(os.Args[1])
-	if err != nil {
-		panic(err)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "gen":
+		genMain(os.Args[2:])
+	case "dump":
+		dumpMain(os.Args[2:])
+	default:
+		dumpMain(os.Args[1:])
+	}
+}
+
+// listFlag collects the values of a repeated flag.
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// ppFlags are the preprocessor and parser flags shared by all commands.
+type ppFlags struct {
+	target      string
+	defines     listFlag
+	undefines   listFlag
+	includeDirs listFlag
+	systemDirs  listFlag
+	trace       bool
+	allErrors   bool
+	declErrors  bool
+	resolve     bool // link identifiers to their declarations
+}
+
+func (f *ppFlags) register(flags *flag.FlagSet) {
+	flags.StringVar(&f.target, "target", "", "predefine the macros of `goos/goarch[/compiler]` and use its type sizes, e.g. linux/amd64 or windows/amd64/gcc")
+	flags.Var(&f.defines, "D", "predefine `name[=value]` as a macro")
+	flags.Var(&f.undefines, "U", "undefine the predefined macro `name`, after the target and -D")
+	flags.Var(&f.includeDirs, "I", "add `dir` to the include search path")
+	flags.Var(&f.systemDirs, "isystem", "add `dir` to the system include search path")
+	flags.BoolVar(&f.trace, "trace", false, "print a trace of the parser to stderr")
+	flags.BoolVar(&f.allErrors, "e", false, "report all errors, not just the first 10 on different lines")
+	flags.BoolVar(&f.declErrors, "declerrors", false, "report redefinitions of tags and enumerators")
+}
+
+// mode returns the parser mode selected by f.
+func (f *ppFlags) mode() parser.Mode {
+	mode := parser.ParseComments
+	if f.trace {
+		mode |= parser.Trace
+	}
+	if f.allErrors {
+		mode |= parser.AllErrors
 	}
-	defer f.Close()
+	if f.declErrors {
+		mode |= parser.DeclarationErrors
+	}
+	if f.resolve {
+		mode |= parser.ResolveObjects
+	}
+	return mode
+}
 
-	b := new(bytes.Buffer)
-	_, err = io.Copy(b, f)
+// parseTarget returns the target selected by f, or nil if there is none.
+func (f *ppFlags) parseTarget() *target.Target {
+	if f.target == "" {
+		return nil
+	}
+	t, err := target.Parse(f.target)
 	if err != nil {
-		panic(err)
-	}
-
-	//for i := range cgen.NewParser(filepath.Base(os.Args[1]), b.String()).Parse() {
-	//	fmt.Printf("%#v\n", i)
-	/*
-		if td, ok := i.(cgen.TypeDecl); ok {
-			if ed, ok := td.Expr.(cgen.EnumDecl); ok {
-				fmt.Printf("typedef enum {\n")
-				for _, s := range ed.Specs {
-					switch es := s.(type) {
-					case cgen.EnumValue:
-						fmt.Printf("\t%s", es.Name.Name)
-						if es.Value != nil {
-							fmt.Printf(" = %s", es.Value.Value)
-						}
-						fmt.Printf(",\n")
-					case cgen.EnumConstExpr:
-						if be, ok := es.Expr.(cgen.BinaryExpr); ok {
-							fmt.Printf("\t%s = %s | %s,\n", es.Name.Name, be.X.(cgen.Ident).Name, be.Y.(cgen.BasicLit).Value)
-						}
-					}
-				}
-				fmt.Printf("} %s;", td.Name.Name)
-			}
+		fmt.Fprintf(os.Stderr, "cgen: %s\nsupported targets: %s\n", err, strings.Join(target.List(), " "))
+		os.Exit(2)
+	}
+	return t
+}
+
+// predefined returns the macros predefined by the target and the -D
+// flags, in the syntax of -D, without the ones removed by -U.
+func (f *ppFlags) predefined() []string {
+	var defines []string
+	if t := f.parseTarget(); t != nil {
+		defines = t.Macros()
+	}
+	var kept []string
+	for _, def := range append(defines, f.defines...) {
+		name := def
+		if i := strings.IndexAny(def, "=("); i >= 0 {
+			name = def[:i]
 		}
-	*/
-	//}
-	parser := parser.NewParser(filepath.Base(os.Args[1]), b.String())
-	ast.Print(nil, parser.Nodes())
-	err = parser.Err()
+		undefined := false
+		for _, u := range f.undefines {
+			undefined = undefined || u == name
+		}
+		if !undefined {
+			kept = append(kept, def)
+		}
+	}
+	return kept
+}
+
+// parseFile preprocesses and parses the file filename and the files it
+// includes. The macros of the target selected by f are predefined. The
+// positions of the nodes refer to the files in fset.
+func parseFile(filename string, f *ppFlags) (nodes []ast.Node, fset *token.FileSet) {
+	fset = token.NewFileSet()
+	pp, err := preprocessor.NewFile(fset, filename)
 	if err != nil {
-		panic(err)
+		fmt.Fprintf(os.Stderr, "cgen: %s\n", err)
+		os.Exit(1)
+	}
+	pp.IncludeDirs = f.includeDirs
+	pp.SystemDirs = f.systemDirs
+	for _, def := range f.predefined() {
+		if err := pp.Predefine(def); err != nil {
+			fmt.Fprintf(os.Stderr, "cgen: %s\n", err)
+			os.Exit(2)
+		}
+	}
+	p := parser.NewParserFromLexer(filepath.Base(filename), pp)
+	p.SetMode(f.mode())
+	nodes = p.Nodes()
+	// Syntax errors only drop the declarations they occur in, so the
+	// rest of the file is still used.
+	if err, ok := p.Err().(parser.ErrorList); ok {
+		for _, e := range err {
+			fmt.Fprintln(os.Stderr, e)
+		}
+	}
+	return nodes, fset
+}
+
+func dumpMain(args []string) {
+	var pf ppFlags
+	flags := flag.NewFlagSet("dump", flag.ExitOnError)
+	pf.register(flags)
+	format := flags.String("format", "go", "output `format`: go or json")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: cgen dump [flags] file.h\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	if *format != "go" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "cgen: unknown format %q\n", *format)
+		os.Exit(2)
+	}
+
+	nodes, fset := parseFile(flags.Arg(0), &pf)
+	if *format == "json" {
+		if err := astjson.Encode(os.Stdout, fset, nodes); err != nil {
+			fmt.Fprintf(os.Stderr, "cgen: %s\n", err)
+			os.Exit(1)
+		}
+		return
 	}
+	ast.Print(fset, nodes)
 }
@@ -0,0 +1,419 @@
+// Package config reads the rules files of cgen, which control how the
+// C declarations of a header are named, filtered and typed in the
+// generated Go code.
+//
+// Rules files are written in a subset of TOML:
+//
+//	trim_prefix = ["FOO_", "foo_"] # removed from names, first match only
+//	camel_case = true              # FOO_BAR_BAZ becomes BarBaz
+//	include = ["^foo_", "^FOO_"]   # generate only matching names
+//	exclude = ["_internal$"]       # never generate matching names
+//	include_files = ["foo*.h"]     # generate only declarations in these files
+//	exclude_files = ["*_impl.h"]
+//
+//	[types]
+//	size_t = "uintptr"             # Go type of a C type name
+//
+//	[[rename]]
+//	match = "^foo_ctx_(.*)$"       # regular expression matching whole names
+//	replace = "Context_$1"         # used as is, without trimming or camel case
+//
+//	[[param]]
+//	func = "foo_write"
+//	name = "buf"
+//	type = "[]byte"                # Go type of a parameter of a cgo wrapper
+//
+//	[[group]]
+//	prefix = "FOO_FLAG_"           # macros starting with prefix
+//	type = "Flag"                  # become constants of the Go type Flag
+//	underlying = "uint32"          # with this underlying type
+//
+// Names are regular expressions in the syntax of package regexp, files
+// are patterns in the syntax of path.Match, matched against both the
+// path and the base name of a file.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rules control the names and types of the generated Go code. The
+// methods of a nil *Rules keep names and types as they are.
+type Rules struct {
+	TrimPrefix   []string
+	CamelCase    bool
+	Include      []*regexp.Regexp
+	Exclude      []*regexp.Regexp
+	IncludeFiles []string
+	ExcludeFiles []string
+	Types        map[string]string // Go types by C type name
+	Renames      []Rename
+	Params       []Param
+	Groups       []Group
+}
+
+// A Rename renames the declarations matching a regular expression.
+type Rename struct {
+	Match   *regexp.Regexp
+	Replace string // replacement in the syntax of Regexp.Expand
+}
+
+// A Param overrides the Go type of a parameter of a cgo wrapper.
+type Param struct {
+	Func string // name of the C function
+	Name string // name of the parameter
+	Type string // "string", "[]byte" or a Go type converted to the C type
+}
+
+// A Group collects the macros with a common prefix into constants of a
+// named Go type.
+type Group struct {
+	Prefix     string
+	Type       string
+	Underlying string // or "", for the type of the first macro
+}
+
+// ReadFile reads the rules file filename.
+func ReadFile(filename string) (*Rules, error) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(filename, src)
+}
+
+// Parse parses the rules in src. The name is used in error messages.
+func Parse(name string, src []byte) (*Rules, error) {
+	t, err := parseTOML(name, string(src))
+	if err != nil {
+		return nil, err
+	}
+	d := &decoder{name: name}
+	r := &Rules{
+		TrimPrefix:   d.strings(t, "trim_prefix"),
+		CamelCase:    d.bool(t, "camel_case"),
+		Include:      d.regexps(t, "include"),
+		Exclude:      d.regexps(t, "exclude"),
+		IncludeFiles: d.patterns(t, "include_files"),
+		ExcludeFiles: d.patterns(t, "exclude_files"),
+	}
+	if types := d.table(t, "types"); types != nil {
+		r.Types = make(map[string]string)
+		for _, k := range sortedKeys(types) {
+			r.Types[k] = d.string(types, k, "types")
+		}
+	}
+	for _, rt := range d.tables(t, "rename") {
+		r.Renames = append(r.Renames, Rename{
+			Match:   d.regexp(d.required(rt, "match", "rename"), "rename.match"),
+			Replace: d.required(rt, "replace", "rename"),
+		})
+		d.unknown(rt, "rename", "match", "replace")
+	}
+	for _, pt := range d.tables(t, "param") {
+		r.Params = append(r.Params, Param{
+			Func: d.required(pt, "func", "param"),
+			Name: d.required(pt, "name", "param"),
+			Type: d.required(pt, "type", "param"),
+		})
+		d.unknown(pt, "param", "func", "name", "type")
+	}
+	for _, gt := range d.tables(t, "group") {
+		r.Groups = append(r.Groups, Group{
+			Prefix:     d.required(gt, "prefix", "group"),
+			Type:       d.required(gt, "type", "group"),
+			Underlying: d.string(gt, "underlying", "group"),
+		})
+		d.unknown(gt, "group", "prefix", "type", "underlying")
+	}
+	d.unknown(t, "", "trim_prefix", "camel_case", "include", "exclude",
+		"include_files", "exclude_files", "types", "rename", "param", "group")
+	if d.err != nil {
+		return nil, d.err
+	}
+	return r, nil
+}
+
+// A decoder converts TOML tables to rules, remembering the first error.
+type decoder struct {
+	name string
+	err  error
+}
+
+func (d *decoder) errorf(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("config: %s: %s", d.name, fmt.Sprintf(format, args...))
+	}
+}
+
+func qualified(table, key string) string {
+	if table == "" {
+		return key
+	}
+	return table + "." + key
+}
+
+func (d *decoder) string(t table, key, tab string) string {
+	v, ok := t[key]
+	if !ok {
+		return ""
+	}
+	s, ok := v.(string)
+	if !ok {
+		d.errorf("%s must be a string", qualified(tab, key))
+	}
+	return s
+}
+
+func (d *decoder) required(t table, key, tab string) string {
+	if _, ok := t[key]; !ok {
+		d.errorf("%s is missing", qualified(tab, key))
+	}
+	return d.string(t, key, tab)
+}
+
+func (d *decoder) bool(t table, key string) bool {
+	v, ok := t[key]
+	if !ok {
+		return false
+	}
+	b, ok := v.(bool)
+	if !ok {
+		d.errorf("%s must be a boolean", key)
+	}
+	return b
+}
+
+func (d *decoder) strings(t table, key string) []string {
+	v, ok := t[key]
+	if !ok {
+		return nil
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		d.errorf("%s must be an array of strings", key)
+		return nil
+	}
+	var ss []string
+	for _, x := range list {
+		s, ok := x.(string)
+		if !ok {
+			d.errorf("%s must be an array of strings", key)
+			return nil
+		}
+		ss = append(ss, s)
+	}
+	return ss
+}
+
+func (d *decoder) regexp(expr, key string) *regexp.Regexp {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		d.errorf("%s: %s", key, err)
+	}
+	return re
+}
+
+func (d *decoder) regexps(t table, key string) []*regexp.Regexp {
+	var res []*regexp.Regexp
+	for _, s := range d.strings(t, key) {
+		res = append(res, d.regexp(s, key))
+	}
+	return res
+}
+
+func (d *decoder) patterns(t table, key string) []string {
+	patterns := d.strings(t, key)
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			d.errorf("%s: invalid pattern %q", key, p)
+		}
+	}
+	return patterns
+}
+
+func (d *decoder) table(t table, key string) table {
+	v, ok := t[key]
+	if !ok {
+		return nil
+	}
+	tt, ok := v.(table)
+	if !ok {
+		d.errorf("%s must be a table", key)
+	}
+	return tt
+}
+
+func (d *decoder) tables(t table, key string) []table {
+	v, ok := t[key]
+	if !ok {
+		return nil
+	}
+	tt, ok := v.([]table)
+	if !ok {
+		d.errorf("%s must be an array of tables", key)
+	}
+	return tt
+}
+
+// unknown reports the first key of t not in keys, which is most likely
+// misspelled.
+func (d *decoder) unknown(t table, tab string, keys ...string) {
+	known := make(map[string]bool)
+	for _, k := range keys {
+		known[k] = true
+	}
+	for _, k := range sortedKeys(t) {
+		if !known[k] {
+			d.errorf("unknown key %s", qualified(tab, k))
+		}
+	}
+}
+
+func sortedKeys(t table) []string {
+	var keys []string
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Name returns the Go name of the C declaration name. The first rename
+// matching name is applied as is. Otherwise the first prefix in
+// TrimPrefix is removed, unless that leaves no identifier, and the
+// name is converted to CamelCase if requested.
+func (r *Rules) Name(name string) string {
+	if r == nil {
+		return name
+	}
+	for _, rn := range r.Renames {
+		if m := rn.Match.FindStringSubmatchIndex(name); m != nil && m[0] == 0 && m[1] == len(name) {
+			return string(rn.Match.ExpandString(nil, rn.Replace, name, m))
+		}
+	}
+	s := name
+	for _, prefix := range r.TrimPrefix {
+		if strings.HasPrefix(s, prefix) {
+			if c, _ := utf8.DecodeRuneInString(s[len(prefix):]); unicode.IsLetter(c) || c == '_' {
+				s = s[len(prefix):]
+			}
+			break
+		}
+	}
+	if r.CamelCase {
+		s = CamelCase(s)
+	}
+	return s
+}
+
+// CamelCase converts the words of name, separated by underscores, to
+// CamelCase. Words in upper case are converted to lower case first, so
+// FOO_BAR and foo_bar both become FooBar.
+func CamelCase(name string) string {
+	var s strings.Builder
+	for _, word := range strings.Split(name, "_") {
+		if word == "" {
+			continue
+		}
+		if strings.ToUpper(word) == word {
+			word = strings.ToLower(word)
+		}
+		r, n := utf8.DecodeRuneInString(word)
+		s.WriteRune(unicode.ToUpper(r))
+		s.WriteString(word[n:])
+	}
+	if s.Len() == 0 {
+		return name
+	}
+	return s.String()
+}
+
+// Keep reports whether the declaration name is generated.
+func (r *Rules) Keep(name string) bool {
+	if r == nil {
+		return true
+	}
+	for _, re := range r.Exclude {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	if len(r.Include) == 0 {
+		return true
+	}
+	for _, re := range r.Include {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// KeepFile reports whether the declarations in the file filename are
+// generated.
+func (r *Rules) KeepFile(filename string) bool {
+	if r == nil {
+		return true
+	}
+	if matchFile(r.ExcludeFiles, filename) {
+		return false
+	}
+	return len(r.IncludeFiles) == 0 || matchFile(r.IncludeFiles, filename)
+}
+
+func matchFile(patterns []string, filename string) bool {
+	filename = strings.Replace(filename, "\\", "/", -1)
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, filename); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, path.Base(filename)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Type returns the Go type the C type name is overridden with, if any.
+func (r *Rules) Type(name string) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	typ, ok := r.Types[name]
+	return typ, ok
+}
+
+// ParamType returns the Go type the parameter param of the C function fn
+// is overridden with, if any.
+func (r *Rules) ParamType(fn, param string) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	for _, p := range r.Params {
+		if p.Func == fn && p.Name == param {
+			return p.Type, true
+		}
+	}
+	return "", false
+}
+
+// Group returns the first group the macro name belongs to, or nil.
+func (r *Rules) Group(name string) *Group {
+	if r == nil {
+		return nil
+	}
+	for i := range r.Groups {
+		if strings.HasPrefix(name, r.Groups[i].Prefix) {
+			return &r.Groups[i]
+		}
+	}
+	return nil
+}
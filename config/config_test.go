@@ -0,0 +1,166 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	src := `# Rules of libfoo.
+trim_prefix = ["FOO_", 'foo_'] # both spellings
+camel_case = true
+include = [
+	"^foo_",
+	"^FOO_",
+]
+exclude_files = ["*_impl.h"]
+
+[types]
+size_t = "uintptr"
+"unsigned long" = "uint64"
+
+[[rename]]
+match = "^foo_ctx_(.*)$"
+replace = "Context_$1"
+
+[[param]]
+func = "foo_write"
+name = "buf"
+type = "[]byte"
+
+[[group]]
+prefix = "FOO_FLAG_"
+type = "Flag"
+`
+	r, err := Parse("rules.toml", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"FOO_", "foo_"}; !reflect.DeepEqual(r.TrimPrefix, want) {
+		t.Errorf("TrimPrefix = %q, want %q", r.TrimPrefix, want)
+	}
+	if !r.CamelCase {
+		t.Errorf("CamelCase = false, want true")
+	}
+	if len(r.Include) != 2 || r.Include[1].String() != "^FOO_" {
+		t.Errorf("Include = %v, want [^foo_ ^FOO_]", r.Include)
+	}
+	if want := []string{"*_impl.h"}; !reflect.DeepEqual(r.ExcludeFiles, want) {
+		t.Errorf("ExcludeFiles = %q, want %q", r.ExcludeFiles, want)
+	}
+	if want := map[string]string{"size_t": "uintptr", "unsigned long": "uint64"}; !reflect.DeepEqual(r.Types, want) {
+		t.Errorf("Types = %q, want %q", r.Types, want)
+	}
+	if len(r.Renames) != 1 || r.Renames[0].Match.String() != "^foo_ctx_(.*)$" || r.Renames[0].Replace != "Context_$1" {
+		t.Errorf("Renames = %v", r.Renames)
+	}
+	if want := []Param{{"foo_write", "buf", "[]byte"}}; !reflect.DeepEqual(r.Params, want) {
+		t.Errorf("Params = %v, want %v", r.Params, want)
+	}
+	if want := []Group{{"FOO_FLAG_", "Flag", ""}}; !reflect.DeepEqual(r.Groups, want) {
+		t.Errorf("Groups = %v, want %v", r.Groups, want)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value string
+	}{
+		{"camel_case = yes", "config: rules.toml:1: invalid value starting with 'y'"},
+		{"a = 1\nb = \"x", "config: rules.toml:2: unterminated string"},
+		{"a = 1 2", "config: rules.toml:1: unexpected '2' after value"},
+		{"a = 1\na = 2", "config: rules.toml:2: duplicate key a"},
+		{"[types\nx = 1", "config: rules.toml:1: unterminated table header"},
+		{"a = [1, 2", "config: rules.toml:1: expected , or ] in array"},
+		{`a = "\q"`, "config: rules.toml:1: invalid escape sequence \\q"},
+		{"trimprefix = []", "config: rules.toml: unknown key trimprefix"},
+		{"camel_case = \"yes\"", "config: rules.toml: camel_case must be a boolean"},
+		{"include = [\"(\"]", "config: rules.toml: include: error parsing regexp: missing closing ): `(`"},
+		{"[[group]]\ntype = \"Flag\"", "config: rules.toml: group.prefix is missing"},
+		{"[types]\nsize_t = 8", "config: rules.toml: types.size_t must be a string"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			_, err := Parse("rules.toml", []byte(test.Input))
+			if err == nil {
+				t.Fatalf("got no error, want %q", test.Value)
+			}
+			if err.Error() != test.Value {
+				t.Errorf("got %q, want %q", err, test.Value)
+			}
+		})
+	}
+}
+
+func TestRules_Name(t *testing.T) {
+	r, err := Parse("rules.toml", []byte(`
+trim_prefix = ["FOO_", "foo_"]
+camel_case = true
+
+[[rename]]
+match = "^foo_ctx_(.*)$"
+replace = "Context_$1"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		Input string
+		Value string
+	}{
+		{"FOO_BAR_BAZ", "BarBaz"},
+		{"foo_open", "Open"},
+		{"foo_getURL", "GetURL"},
+		{"FOO_2D", "Foo2d"},
+		{"foo_ctx_create", "Context_create"},
+		{"bar", "Bar"},
+		{"FOO_", "Foo"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			if got := r.Name(test.Input); got != test.Value {
+				t.Errorf("got %q, want %q", got, test.Value)
+			}
+		})
+	}
+
+	var nilRules *Rules
+	if got := nilRules.Name("foo_open"); got != "foo_open" {
+		t.Errorf("nil rules: got %q, want %q", got, "foo_open")
+	}
+}
+
+func TestRules_Keep(t *testing.T) {
+	r, err := Parse("rules.toml", []byte(`
+include = ["^foo_"]
+exclude = ["_internal$"]
+include_files = ["include/foo/*.h"]
+exclude_files = ["*_impl.h"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{
+		"foo_open":          true,
+		"bar_open":          false,
+		"foo_open_internal": false,
+	}
+	for name, want := range names {
+		if got := r.Keep(name); got != want {
+			t.Errorf("Keep(%q) = %v, want %v", name, got, want)
+		}
+	}
+	files := map[string]bool{
+		"include/foo/foo.h":      true,
+		"include/foo/foo_impl.h": false,
+		"include/bar.h":          false,
+	}
+	for name, want := range files {
+		if got := r.KeepFile(name); got != want {
+			t.Errorf("KeepFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
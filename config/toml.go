@@ -0,0 +1,267 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A table is a TOML table. Values are strings, int64s, bools, []interface{}
+// for arrays and []table for arrays of tables.
+type table map[string]interface{}
+
+// tomlParser parses the subset of TOML used by rules files: comments,
+// tables, arrays of tables, bare and quoted keys, basic and literal
+// strings, integers, booleans and arrays, which may span several lines.
+// Dotted keys, floats, dates and inline tables are not supported.
+type tomlParser struct {
+	name string
+	src  string
+	pos  int
+	line int
+}
+
+// parseTOML parses src and returns its root table.
+func parseTOML(name, src string) (t table, err error) {
+	p := &tomlParser{name: name, src: src, line: 1}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(tomlError)
+			if !ok {
+				panic(r)
+			}
+			t, err = nil, e
+		}
+	}()
+	return p.parse(), nil
+}
+
+// A tomlError is a syntax error in a TOML file.
+type tomlError struct {
+	name string
+	line int
+	msg  string
+}
+
+func (e tomlError) Error() string {
+	return fmt.Sprintf("config: %s:%d: %s", e.name, e.line, e.msg)
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) {
+	panic(tomlError{p.name, p.line, fmt.Sprintf(format, args...)})
+}
+
+func (p *tomlParser) peek() byte {
+	if p.pos >= len(p.src) {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+// skip skips spaces and comments, and line breaks if lines is set.
+func (p *tomlParser) skip(lines bool) {
+	for p.pos < len(p.src) {
+		switch c := p.src[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '\n' && lines:
+			p.pos++
+			p.line++
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// endLine expects the end of a line, after an optional comment.
+func (p *tomlParser) endLine() {
+	p.skip(false)
+	switch p.peek() {
+	case 0:
+	case '\n':
+		p.pos++
+		p.line++
+	default:
+		p.errorf("unexpected %q after value", p.peek())
+	}
+}
+
+func (p *tomlParser) parse() table {
+	root := make(table)
+	current := root
+	for {
+		p.skip(true)
+		switch p.peek() {
+		case 0:
+			return root
+		case '[':
+			p.pos++
+			array := p.peek() == '['
+			if array {
+				p.pos++
+			}
+			p.skip(false)
+			key := p.key()
+			p.skip(false)
+			if !strings.HasPrefix(p.src[p.pos:], "]") || array && !strings.HasPrefix(p.src[p.pos:], "]]") {
+				p.errorf("unterminated table header")
+			}
+			p.pos++
+			if array {
+				p.pos++
+			}
+			p.endLine()
+
+			current = make(table)
+			if array {
+				tables, ok := root[key].([]table)
+				if !ok && root[key] != nil {
+					p.errorf("%s is not an array of tables", key)
+				}
+				root[key] = append(tables, current)
+			} else {
+				if root[key] != nil {
+					p.errorf("duplicate table %s", key)
+				}
+				root[key] = current
+			}
+		default:
+			key := p.key()
+			p.skip(false)
+			if p.peek() != '=' {
+				p.errorf("expected = after key %s", key)
+			}
+			p.pos++
+			p.skip(false)
+			if _, ok := current[key]; ok {
+				p.errorf("duplicate key %s", key)
+			}
+			current[key] = p.value()
+			p.endLine()
+		}
+	}
+}
+
+func isBare(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) key() string {
+	switch p.peek() {
+	case '"', '\'':
+		return p.string()
+	}
+	start := p.pos
+	for p.pos < len(p.src) && isBare(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.errorf("expected key, found %q", p.peek())
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *tomlParser) value() interface{} {
+	switch c := p.peek(); {
+	case c == '"' || c == '\'':
+		return p.string()
+	case c == '[':
+		p.pos++
+		var list []interface{}
+		for {
+			p.skip(true)
+			if p.peek() == ']' {
+				p.pos++
+				return list
+			}
+			list = append(list, p.value())
+			p.skip(true)
+			switch p.peek() {
+			case ',':
+				p.pos++
+			case ']':
+			default:
+				p.errorf("expected , or ] in array")
+			}
+		}
+	case c == '-' || c == '+' || '0' <= c && c <= '9':
+		start := p.pos
+		for p.pos < len(p.src) && (isBare(p.src[p.pos]) || p.src[p.pos] == '+') {
+			p.pos++
+		}
+		n, err := strconv.ParseInt(strings.Replace(p.src[start:p.pos], "_", "", -1), 0, 64)
+		if err != nil {
+			p.errorf("invalid integer %s", p.src[start:p.pos])
+		}
+		return n
+	case strings.HasPrefix(p.src[p.pos:], "true"):
+		p.pos += 4
+		return true
+	case strings.HasPrefix(p.src[p.pos:], "false"):
+		p.pos += 5
+		return false
+	case c == 0 || c == '\n':
+		p.errorf("missing value")
+	}
+	p.errorf("invalid value starting with %q", p.peek())
+	return nil
+}
+
+// string parses a basic string, in which backslash escapes are
+// interpreted, or a literal string, which is used as is.
+func (p *tomlParser) string() string {
+	quote := p.src[p.pos]
+	p.pos++
+	var s strings.Builder
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' {
+			p.errorf("unterminated string")
+		}
+		c := p.src[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return s.String()
+		case c == '\\' && quote == '"':
+			p.escape(&s)
+		default:
+			s.WriteByte(c)
+		}
+	}
+}
+
+var tomlEscapes = map[byte]byte{
+	'b': '\b', 't': '\t', 'n': '\n', 'f': '\f', 'r': '\r', '"': '"', '\\': '\\',
+}
+
+func (p *tomlParser) escape(s *strings.Builder) {
+	c := p.peek()
+	p.pos++
+	if e, ok := tomlEscapes[c]; ok {
+		s.WriteByte(e)
+		return
+	}
+	n := 0
+	switch c {
+	case 'u':
+		n = 4
+	case 'U':
+		n = 8
+	default:
+		p.errorf("invalid escape sequence \\%c", c)
+	}
+	if p.pos+n > len(p.src) {
+		p.errorf("invalid escape sequence \\%c", c)
+	}
+	r, err := strconv.ParseUint(p.src[p.pos:p.pos+n], 16, 32)
+	if err != nil || !utf8.ValidRune(rune(r)) {
+		p.errorf("invalid escape sequence \\%c%s", c, p.src[p.pos:p.pos+n])
+	}
+	p.pos += n
+	s.WriteRune(rune(r))
+}
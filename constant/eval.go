@@ -0,0 +1,276 @@
+package constant
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+// An Evaluator folds integer constant expressions.
+type Evaluator struct {
+	Model Model
+
+	// Ident returns the value of an identifier, such as a macro or an
+	// enumerator. If it is nil, or returns an error, expressions using
+	// the identifier cannot be evaluated.
+	Ident func(name string) (Value, error)
+
+	// Preprocessor selects the rules of #if directives, which evaluate
+	// all signed types as intmax_t and all unsigned types as uintmax_t.
+	Preprocessor bool
+}
+
+// kind returns the kind values of kind k have in e.
+func (e *Evaluator) kind(k Kind) Kind {
+	if e.Preprocessor {
+		if k.Unsigned() {
+			return UlongLong
+		}
+		return LongLong
+	}
+	return k
+}
+
+func (e *Evaluator) make(x *big.Int, k Kind) Value {
+	k = e.kind(k)
+	return Value{k, e.Model.wrap(x, e.Model.Bits(k), k.Unsigned())}
+}
+
+func (e *Evaluator) truth(b bool) Value {
+	if b {
+		return e.make(big.NewInt(1), Int)
+	}
+	return e.make(new(big.Int), Int)
+}
+
+// Eval returns the value of x.
+func (e *Evaluator) Eval(x ast.Expr) (Value, error) {
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		var v Value
+		var err error
+		switch x.Kind {
+		case token.INT:
+			v, err = e.Model.ParseInt(x.Value)
+		case token.CHAR:
+			v, err = e.Model.ParseChar(x.Value)
+		default:
+			return Value{}, fmt.Errorf("%s is not an integer constant", x.Value)
+		}
+		if err != nil {
+			return Value{}, err
+		}
+		return e.make(v.val, v.kind), nil
+	case *ast.Ident:
+		if e.Ident == nil {
+			return Value{}, fmt.Errorf("undefined: %s", x.Name)
+		}
+		v, err := e.Ident(x.Name)
+		if err != nil {
+			return Value{}, err
+		}
+		return e.make(v.Int(), v.kind), nil
+	case *ast.ParenExpr:
+		if x.Expr == nil {
+			return Value{}, fmt.Errorf("empty parentheses")
+		}
+		return e.Eval(x.Expr)
+	case *ast.UnaryExpr:
+		return e.unary(x)
+	case *ast.BinaryExpr:
+		return e.binary(x)
+	case *ast.CondExpr:
+		return e.cond(x)
+	case *ast.CastExpr:
+		return e.cast(x)
+	}
+	return Value{}, fmt.Errorf("%T is not an integer constant expression", x)
+}
+
+func (e *Evaluator) unary(x *ast.UnaryExpr) (Value, error) {
+	y, err := e.Eval(x.X)
+	if err != nil {
+		return Value{}, err
+	}
+	switch x.Op {
+	case token.ADD:
+		return y, nil
+	case token.SUB:
+		return e.make(y.Int().Neg(y.val), y.kind), nil
+	case token.NOT:
+		return e.truth(y.Sign() == 0), nil
+	case token.TILDE:
+		return e.make(y.Int().Not(y.val), y.kind), nil
+	}
+	return Value{}, fmt.Errorf("invalid unary operator %s", x.Op)
+}
+
+func (e *Evaluator) binary(x *ast.BinaryExpr) (Value, error) {
+	a, err := e.Eval(x.X)
+	if err != nil {
+		return Value{}, err
+	}
+	// The right operand of && and || is not evaluated if the left
+	// operand decides the result, so it may divide by zero.
+	switch {
+	case x.Op == token.LAND && a.Sign() == 0:
+		return e.truth(false), nil
+	case x.Op == token.LOR && a.Sign() != 0:
+		return e.truth(true), nil
+	}
+	b, err := e.Eval(x.Y)
+	if err != nil {
+		return Value{}, err
+	}
+
+	switch x.Op {
+	case token.LAND, token.LOR:
+		return e.truth(b.Sign() != 0), nil
+	case token.SHL, token.SHR:
+		// The result has the type of the left operand.
+		if b.Sign() < 0 || b.val.Cmp(big.NewInt(int64(e.Model.Bits(a.kind)))) >= 0 {
+			return Value{}, fmt.Errorf("shift count %s out of range", b)
+		}
+		n := uint(b.val.Uint64())
+		if x.Op == token.SHL {
+			return e.make(a.Int().Lsh(a.val, n), a.kind), nil
+		}
+		return e.make(a.Int().Rsh(a.val, n), a.kind), nil
+	}
+
+	k := e.kind(e.Model.Common(a.kind, b.kind))
+	a, b = e.Model.Convert(a, k), e.Model.Convert(b, k)
+	switch x.Op {
+	case token.EQL:
+		return e.truth(a.val.Cmp(b.val) == 0), nil
+	case token.NEQ:
+		return e.truth(a.val.Cmp(b.val) != 0), nil
+	case token.LSS:
+		return e.truth(a.val.Cmp(b.val) < 0), nil
+	case token.LEQ:
+		return e.truth(a.val.Cmp(b.val) <= 0), nil
+	case token.GTR:
+		return e.truth(a.val.Cmp(b.val) > 0), nil
+	case token.GEQ:
+		return e.truth(a.val.Cmp(b.val) >= 0), nil
+	}
+
+	r := new(big.Int)
+	switch x.Op {
+	case token.ADD:
+		r.Add(a.val, b.val)
+	case token.SUB:
+		r.Sub(a.val, b.val)
+	case token.MUL:
+		r.Mul(a.val, b.val)
+	case token.QUO, token.REM:
+		if b.Sign() == 0 {
+			return Value{}, fmt.Errorf("division by zero")
+		}
+		// C division truncates toward zero.
+		if x.Op == token.QUO {
+			r.Quo(a.val, b.val)
+		} else {
+			r.Rem(a.val, b.val)
+		}
+	case token.AND:
+		r.And(a.val, b.val)
+	case token.OR:
+		r.Or(a.val, b.val)
+	case token.XOR:
+		r.Xor(a.val, b.val)
+	default:
+		return Value{}, fmt.Errorf("invalid binary operator %s", x.Op)
+	}
+	return e.make(r, k), nil
+}
+
+func (e *Evaluator) cond(x *ast.CondExpr) (Value, error) {
+	c, err := e.Eval(x.Cond)
+	if err != nil {
+		return Value{}, err
+	}
+	taken, other := x.X, x.Y
+	if c.Sign() == 0 {
+		taken, other = other, taken
+	}
+	v, err := e.Eval(taken)
+	if err != nil {
+		return Value{}, err
+	}
+	// The operand that isn't evaluated still takes part in the
+	// conversion to the type of the result.
+	if w, err := e.Eval(other); err == nil {
+		return e.Model.Convert(v, e.kind(e.Model.Common(v.kind, w.kind))), nil
+	}
+	return v, nil
+}
+
+func (e *Evaluator) cast(x *ast.CastExpr) (Value, error) {
+	v, err := e.Eval(x.X)
+	if err != nil {
+		return Value{}, err
+	}
+	typ := x.Type
+	if q, ok := typ.(*ast.QualType); ok {
+		typ = q.Type
+	}
+	name := ""
+	switch t := typ.(type) {
+	case *ast.BasicType:
+		name = t.Name
+	case *ast.Ident:
+		name = t.Name
+	}
+
+	if name == "_Bool" || name == "bool" {
+		return e.truth(v.Sign() != 0), nil
+	}
+	if bits, unsigned, ok := e.Model.narrowTypes(name); ok {
+		// Narrow types are promoted to int right away.
+		return e.make(e.Model.wrap(v.Int(), bits, unsigned), Int), nil
+	}
+	k, ok := castKinds[name]
+	if !ok {
+		return Value{}, fmt.Errorf("cannot convert %s to a non-integer type", v)
+	}
+	return e.make(v.Int(), k), nil
+}
+
+// narrowTypes returns the width and signedness of the integer types of a
+// lower rank than int.
+func (m Model) narrowTypes(name string) (bits int, unsigned bool, ok bool) {
+	switch name {
+	case "char":
+		return 8, m.CharUnsigned, true
+	case "signed char", "int8_t":
+		return 8, false, true
+	case "unsigned char", "uint8_t":
+		return 8, true, true
+	case "short", "int16_t":
+		return 16, false, true
+	case "unsigned short", "uint16_t":
+		return 16, true, true
+	}
+	return 0, false, false
+}
+
+// castKinds maps the names of integer types to their kinds, including
+// the fixed-width types of stdint.h that have the same kind on all
+// common targets.
+var castKinds = map[string]Kind{
+	"int":                Int,
+	"unsigned int":       Uint,
+	"long":               Long,
+	"unsigned long":      Ulong,
+	"long long":          LongLong,
+	"unsigned long long": UlongLong,
+	"int32_t":            Int,
+	"uint32_t":           Uint,
+	"int64_t":            LongLong,
+	"uint64_t":           UlongLong,
+	"intmax_t":           LongLong,
+	"uintmax_t":          UlongLong,
+}
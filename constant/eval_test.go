@@ -0,0 +1,154 @@
+package constant
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/lexer"
+	"github.com/SHyx0rmZ/cgen/parser"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+func TestEvaluator_Eval(t *testing.T) {
+	idents := map[string]Value{
+		"FLAG_A": MakeInt64(1, Int),
+		"FLAG_B": MakeInt64(2, Int),
+		"MASK":   MakeUint64(0xff, Uint),
+	}
+	e := &Evaluator{
+		Model: LP64,
+		Ident: func(name string) (Value, error) {
+			if v, ok := idents[name]; ok {
+				return v, nil
+			}
+			return Value{}, fmt.Errorf("undefined: %s", name)
+		},
+	}
+
+	tests := []struct {
+		Input string
+		Value string
+		Kind  Kind
+	}{
+		{"(FLAG_A | FLAG_B << 4)", "33", Int},
+		{"-1 / 2", "0", Int},
+		{"-7 / 2 * 2", "-6", Int},
+		{"-1 < 0u", "0", Int},
+		{"-1 == 0xffffffff", "1", Int},
+		{"-1 & MASK", "255", Uint},
+		{"0 - 1u", "4294967295", Uint},
+		{"0 - 0x8000000000000000", "9223372036854775808", Ulong},
+		{"2147483647 * 2", "-2", Int},
+		{"1 << 31", "-2147483648", Int},
+		{"0x100000000 << 8", "1099511627776", Long},
+		{"0x80000000 >> 31", "1", Uint},
+		{"!0 && (0 || 3)", "1", Int},
+		{"0 && 1 / 0", "0", Int},
+		{"1 + 2 * 3 % 4", "3", Int},
+		{"~0u >> 28 ^ 1", "14", Uint},
+		{"FLAG_A | FLAG_B == 2", "1", Int},
+		{"FLAG_A ? (uint32_t)-1 : 0", "4294967295", Uint},
+		{"(unsigned long long)~0 != (unsigned char)~0", "1", Int},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			x, err := parser.ParseExpr("test", lexer.NewLexer("test", test.Input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, err := e.Eval(x)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.String() != test.Value || v.Kind() != test.Kind {
+				t.Errorf("got %s (%s), want %s (%s)", v, v.Kind(), test.Value, test.Kind)
+			}
+		})
+	}
+}
+
+func TestEvaluator_EvalCondCast(t *testing.T) {
+	lit := func(value string) *ast.BasicLit { return &ast.BasicLit{Kind: token.INT, Value: value} }
+	neg := func(x ast.Expr) ast.Expr { return &ast.UnaryExpr{Op: token.SUB, X: x} }
+	cast := func(typ string, x ast.Expr) ast.Expr {
+		return &ast.CastExpr{Type: &ast.BasicType{Name: typ}, X: x}
+	}
+
+	tests := []struct {
+		Name  string
+		Expr  ast.Expr
+		Value string
+		Kind  Kind
+	}{
+		{"1 ? -1 : 0u", &ast.CondExpr{Cond: lit("1"), X: neg(lit("1")), Y: lit("0u")}, "4294967295", Uint},
+		{"0 ? 1 / 0 : 2", &ast.CondExpr{Cond: lit("0"), X: &ast.BinaryExpr{X: lit("1"), Op: token.QUO, Y: lit("0")}, Y: lit("2")}, "2", Int},
+		{"(unsigned char)-1", cast("unsigned char", neg(lit("1"))), "255", Int},
+		{"(signed char)200", cast("signed char", lit("200")), "-56", Int},
+		{"(unsigned long)-1", cast("unsigned long", neg(lit("1"))), "18446744073709551615", Ulong},
+		{"(_Bool)42", cast("_Bool", lit("42")), "1", Int},
+		{"(uint32_t)-1", &ast.CastExpr{Type: &ast.Ident{Name: "uint32_t"}, X: neg(lit("1"))}, "4294967295", Uint},
+	}
+
+	e := &Evaluator{Model: LP64}
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			v, err := e.Eval(test.Expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.String() != test.Value || v.Kind() != test.Kind {
+				t.Errorf("got %s (%s), want %s (%s)", v, v.Kind(), test.Value, test.Kind)
+			}
+		})
+	}
+}
+
+func TestEvaluator_EvalCharUnsigned(t *testing.T) {
+	e := &Evaluator{Model: Model{Long: 64, CharUnsigned: true}}
+	for _, test := range []struct {
+		Input string
+		Value string
+	}{
+		{"'\\377'", "255"},
+		{"(char)-1", "255"},
+		{"(signed char)-1", "-1"},
+	} {
+		x, err := parser.ParseExpr("test", lexer.NewLexer("test", test.Input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := e.Eval(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.String() != test.Value {
+			t.Errorf("%s: got %s, want %s", test.Input, v, test.Value)
+		}
+	}
+}
+
+func TestEvaluator_EvalPreprocessor(t *testing.T) {
+	e := &Evaluator{Preprocessor: true}
+	for _, test := range []struct {
+		Input string
+		Value string
+	}{
+		{"2147483647 * 2", "4294967294"},
+		{"-1 < 0u", "0"},
+		{"0 - 1", "-1"},
+	} {
+		x, err := parser.ParseExpr("test", lexer.NewLexer("test", test.Input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := e.Eval(x)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.String() != test.Value {
+			t.Errorf("%s: got %s, want %s", test.Input, v, test.Value)
+		}
+	}
+}
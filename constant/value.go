@@ -0,0 +1,371 @@
+// Package constant evaluates C integer constant expressions.
+//
+// Values are computed with arbitrary precision and then converted to
+// the C type of the expression, so they wrap around exactly like they
+// would in C.
+package constant
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Kind is the C type of an integer constant. Types of a lower rank than
+// int are promoted to int before they take part in an expression, so
+// they don't have a Kind of their own.
+type Kind int
+
+const (
+	Int Kind = iota
+	Uint
+	Long
+	Ulong
+	LongLong
+	UlongLong
+)
+
+var kindNames = [...]string{
+	Int:       "int",
+	Uint:      "unsigned int",
+	Long:      "long",
+	Ulong:     "unsigned long",
+	LongLong:  "long long",
+	UlongLong: "unsigned long long",
+}
+
+func (k Kind) String() string {
+	if 0 <= k && int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Unsigned reports whether k is an unsigned type.
+func (k Kind) Unsigned() bool {
+	return k == Uint || k == Ulong || k == UlongLong
+}
+
+// rank returns the integer conversion rank of k.
+func (k Kind) rank() int {
+	return int(k) / 2
+}
+
+// signed returns the signed type corresponding to k.
+func (k Kind) signed() Kind {
+	if k.Unsigned() {
+		return k - 1
+	}
+	return k
+}
+
+// unsigned returns the unsigned type corresponding to k.
+func (k Kind) unsigned() Kind {
+	if k.Unsigned() {
+		return k
+	}
+	return k + 1
+}
+
+// A Value is an integer constant of a C type.
+type Value struct {
+	kind Kind
+	val  *big.Int
+}
+
+// MakeInt64 returns the value x of kind k.
+func MakeInt64(x int64, k Kind) Value {
+	return Value{k, big.NewInt(x)}
+}
+
+// MakeUint64 returns the value x of kind k.
+func MakeUint64(x uint64, k Kind) Value {
+	return Value{k, new(big.Int).SetUint64(x)}
+}
+
+// Kind returns the C type of v.
+func (v Value) Kind() Kind { return v.kind }
+
+// Int returns a copy of the value of v.
+func (v Value) Int() *big.Int {
+	if v.val == nil {
+		return new(big.Int)
+	}
+	return new(big.Int).Set(v.val)
+}
+
+// Int64 returns the value of v and whether it fits into an int64.
+func (v Value) Int64() (int64, bool) {
+	x := v.Int()
+	return x.Int64(), x.IsInt64()
+}
+
+// Uint64 returns the value of v and whether it fits into a uint64.
+func (v Value) Uint64() (uint64, bool) {
+	x := v.Int()
+	return x.Uint64(), x.IsUint64()
+}
+
+// Sign returns -1, 0 or +1 depending on whether v is negative, zero or
+// positive.
+func (v Value) Sign() int {
+	if v.val == nil {
+		return 0
+	}
+	return v.val.Sign()
+}
+
+// String returns v in decimal.
+func (v Value) String() string {
+	return v.Int().String()
+}
+
+// A Model describes the widths of the integer types of a target.
+type Model struct {
+	Long         int  // width of long in bits
+	CharUnsigned bool // whether plain char is unsigned
+}
+
+// LP64 is the model of most 64-bit Unix systems.
+var LP64 = Model{Long: 64}
+
+// Bits returns the width of k in bits.
+func (m Model) Bits(k Kind) int {
+	switch k {
+	case Int, Uint:
+		return 32
+	case Long, Ulong:
+		if m.Long == 0 {
+			return 64
+		}
+		return m.Long
+	}
+	return 64
+}
+
+// Convert converts v to the kind k, wrapping around if the value does
+// not fit.
+func (m Model) Convert(v Value, k Kind) Value {
+	return Value{k, m.wrap(v.Int(), m.Bits(k), k.Unsigned())}
+}
+
+// wrap reduces x modulo 2**bits into the range of the integer type of
+// the given width and signedness.
+func (m Model) wrap(x *big.Int, bits int, unsigned bool) *big.Int {
+	mod := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	x.Mod(x, mod)
+	if !unsigned && x.Bit(bits-1) == 1 {
+		x.Sub(x, mod)
+	}
+	return x
+}
+
+// fits reports whether x is within the range of k.
+func (m Model) fits(x *big.Int, k Kind) bool {
+	bits := m.Bits(k)
+	if k.Unsigned() {
+		return x.Sign() >= 0 && x.BitLen() <= bits
+	}
+	return x.BitLen() < bits || (x.Sign() < 0 && new(big.Int).Add(x, big.NewInt(1)).BitLen() < bits)
+}
+
+// Common returns the type both operands of a binary operation with the
+// kinds a and b are converted to, following the usual arithmetic
+// conversions.
+func (m Model) Common(a, b Kind) Kind {
+	switch {
+	case a == b:
+		return a
+	case a.Unsigned() == b.Unsigned():
+		if a.rank() > b.rank() {
+			return a
+		}
+		return b
+	}
+	u, s := a, b
+	if s.Unsigned() {
+		u, s = s, u
+	}
+	switch {
+	case u.rank() >= s.rank():
+		return u
+	case m.Bits(s) > m.Bits(u):
+		return s
+	}
+	return s.unsigned()
+}
+
+// ParseInt parses a C integer literal, including its prefix and suffix,
+// and returns it with the first type of the candidate types for the
+// literal its value fits into. Like GCC, a decimal literal too large for
+// the signed candidates has type unsigned long long.
+func (m Model) ParseInt(lit string) (Value, error) {
+	digits := strings.TrimRight(lit, "uUlL")
+	suffix := strings.ToLower(lit[len(digits):])
+	base := 10
+	switch {
+	case strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X"):
+		base, digits = 16, digits[2:]
+	case strings.HasPrefix(digits, "0b") || strings.HasPrefix(digits, "0B"):
+		base, digits = 2, digits[2:]
+	case len(digits) > 1 && digits[0] == '0':
+		base, digits = 8, digits[1:]
+	}
+	x, ok := new(big.Int).SetString(digits, base)
+	if !ok || x.Sign() < 0 || strings.ContainsAny(digits, "+-_") {
+		return Value{}, fmt.Errorf("invalid integer literal %s", lit)
+	}
+
+	var kinds []Kind
+	decimal := base == 10
+	switch suffix {
+	case "":
+		kinds = []Kind{Int, Long, LongLong}
+		if !decimal {
+			kinds = []Kind{Int, Uint, Long, Ulong, LongLong, UlongLong}
+		}
+	case "u":
+		kinds = []Kind{Uint, Ulong, UlongLong}
+	case "l":
+		kinds = []Kind{Long, LongLong}
+		if !decimal {
+			kinds = []Kind{Long, Ulong, LongLong, UlongLong}
+		}
+	case "ul", "lu":
+		kinds = []Kind{Ulong, UlongLong}
+	case "ll":
+		kinds = []Kind{LongLong}
+		if !decimal {
+			kinds = []Kind{LongLong, UlongLong}
+		}
+	case "ull", "llu":
+		kinds = []Kind{UlongLong}
+	default:
+		return Value{}, fmt.Errorf("invalid suffix %q on integer literal %s", lit[len(lit)-len(suffix):], lit)
+	}
+	for _, k := range kinds {
+		if m.fits(x, k) {
+			return Value{k, x}, nil
+		}
+	}
+	if decimal && !strings.Contains(suffix, "u") && m.fits(x, UlongLong) {
+		return Value{UlongLong, x}, nil
+	}
+	return Value{}, fmt.Errorf("integer literal %s is too large for its type", lit)
+}
+
+// ParseChar parses a C character constant, including its encoding prefix,
+// and returns its value with the type of the constant. Plain character
+// constants have the value of a char promoted to int.
+func (m Model) ParseChar(lit string) (Value, error) {
+	i := strings.IndexByte(lit, '\'')
+	if i < 0 || len(lit) < i+3 || lit[len(lit)-1] != '\'' {
+		return Value{}, fmt.Errorf("invalid character constant %s", lit)
+	}
+	prefix, body := lit[:i], lit[i+1:len(lit)-1]
+	c, n, err := unescape(body)
+	if err != nil {
+		return Value{}, fmt.Errorf("%s in character constant %s", err, lit)
+	}
+	if n != len(body) {
+		return Value{}, fmt.Errorf("multi-character constant %s", lit)
+	}
+
+	x := new(big.Int).SetUint64(uint64(c))
+	switch prefix {
+	case "":
+		// A character encoded in several bytes is a multi-character
+		// constant.
+		if body[0] != '\\' && n > 1 {
+			return Value{}, fmt.Errorf("multi-character constant %s", lit)
+		}
+		if c > 0xff {
+			break
+		}
+		return Value{Int, m.wrap(x, 8, m.CharUnsigned)}, nil
+	case "L":
+		return Value{Int, m.wrap(x, 32, false)}, nil
+	case "u":
+		if c > 0xffff {
+			break
+		}
+		return Value{Int, x}, nil
+	case "U":
+		return Value{Uint, x}, nil
+	default:
+		return Value{}, fmt.Errorf("invalid character constant %s", lit)
+	}
+	return Value{}, fmt.Errorf("character constant %s is out of range", lit)
+}
+
+var simpleEscapes = map[byte]uint32{
+	'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v',
+	'\\': '\\', '\'': '\'', '"': '"', '?': '?',
+}
+
+// unescape decodes the first character or escape sequence of s and
+// returns its value and the number of bytes it takes up. Characters that
+// aren't escaped are decoded as UTF-8, so the value of a multi-byte
+// character is its code point.
+func unescape(s string) (uint32, int, error) {
+	if s == "" {
+		return 0, 0, fmt.Errorf("missing character")
+	}
+	if s[0] != '\\' {
+		r, n := utf8.DecodeRuneInString(s)
+		if r == utf8.RuneError && n == 1 {
+			return uint32(s[0]), 1, nil
+		}
+		return uint32(r), n, nil
+	}
+	if len(s) < 2 {
+		return 0, 0, fmt.Errorf("incomplete escape sequence")
+	}
+	if c, ok := simpleEscapes[s[1]]; ok {
+		return c, 2, nil
+	}
+
+	base, min, max := 8, 1, 3
+	n := 1
+	switch s[1] {
+	case 'x':
+		base, min, max, n = 16, 1, len(s), 2
+	case 'u':
+		base, min, max, n = 16, 4, 4, 2
+	case 'U':
+		base, min, max, n = 16, 8, 8, 2
+	}
+	var c uint64
+	digits := 0
+	for ; n < len(s) && digits < max; n++ {
+		d, ok := digitValue(s[n], base)
+		if !ok {
+			break
+		}
+		c = c*uint64(base) + uint64(d)
+		if c > 0xffffffff {
+			return 0, 0, fmt.Errorf("escape sequence out of range")
+		}
+		digits++
+	}
+	if digits < min {
+		return 0, 0, fmt.Errorf("invalid escape sequence \\%c", s[1])
+	}
+	return uint32(c), n, nil
+}
+
+func digitValue(c byte, base int) (int, bool) {
+	var d int
+	switch {
+	case '0' <= c && c <= '9':
+		d = int(c - '0')
+	case 'a' <= c && c <= 'f':
+		d = int(c-'a') + 10
+	case 'A' <= c && c <= 'F':
+		d = int(c-'A') + 10
+	default:
+		return 0, false
+	}
+	return d, d < base
+}
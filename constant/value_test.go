@@ -0,0 +1,118 @@
+package constant
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestModel_ParseInt(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value string
+		Kind  Kind
+	}{
+		{"0", "0", Int},
+		{"2147483647", "2147483647", Int},
+		{"2147483648", "2147483648", Long},
+		{"0x7fffffff", "2147483647", Int},
+		{"0x80000000", "2147483648", Uint},
+		{"0xffffffffffffffff", "18446744073709551615", Ulong},
+		{"0755", "493", Int},
+		{"0b1010", "10", Int},
+		{"10u", "10", Uint},
+		{"10UL", "10", Ulong},
+		{"10lu", "10", Ulong},
+		{"10l", "10", Long},
+		{"10LL", "10", LongLong},
+		{"10ull", "10", UlongLong},
+		{"0x8000000000000000ll", "9223372036854775808", UlongLong},
+		{"9223372036854775808", "9223372036854775808", UlongLong},
+		{"18446744073709551615l", "18446744073709551615", UlongLong},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			v, err := LP64.ParseInt(test.Input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.String() != test.Value || v.Kind() != test.Kind {
+				t.Errorf("got %s (%s), want %s (%s)", v, v.Kind(), test.Value, test.Kind)
+			}
+		})
+	}
+}
+
+func TestModel_ParseIntError(t *testing.T) {
+	for _, input := range []string{"08", "0x", "1lul", "18446744073709551616", "18446744073709551616ll"} {
+		t.Run(input, func(t *testing.T) {
+			if v, err := LP64.ParseInt(input); err == nil {
+				t.Errorf("got %s (%s), want an error", v, v.Kind())
+			}
+		})
+	}
+}
+
+func TestModel_ParseChar(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value string
+		Kind  Kind
+	}{
+		{`'a'`, "97", Int},
+		{`'\n'`, "10", Int},
+		{`'\0'`, "0", Int},
+		{`'\377'`, "-1", Int},
+		{`'\x7f'`, "127", Int},
+		{`'\''`, "39", Int},
+		{`L'\xffffffff'`, "-1", Int},
+		{`u'\u00e9'`, "233", Int},
+		{`U'\U0001F600'`, "128512", Uint},
+		{`L'é'`, "233", Int},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			v, err := LP64.ParseChar(test.Input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.String() != test.Value || v.Kind() != test.Kind {
+				t.Errorf("got %s (%s), want %s (%s)", v, v.Kind(), test.Value, test.Kind)
+			}
+		})
+	}
+}
+
+func TestModel_ParseCharError(t *testing.T) {
+	for _, input := range []string{`''`, `'ab'`, `'\q'`, `'\x100'`, `'é'`, `u'\U0001F600'`, `'\u12'`} {
+		t.Run(input, func(t *testing.T) {
+			if v, err := LP64.ParseChar(input); err == nil {
+				t.Errorf("got %s (%s), want an error", v, v.Kind())
+			}
+		})
+	}
+}
+
+func TestModel_Common(t *testing.T) {
+	tests := []struct {
+		Model Model
+		A, B  Kind
+		Kind  Kind
+	}{
+		{LP64, Int, Int, Int},
+		{LP64, Int, Uint, Uint},
+		{LP64, Long, Uint, Long},
+		{Model{Long: 32}, Long, Uint, Ulong},
+		{LP64, LongLong, Ulong, UlongLong},
+		{LP64, UlongLong, Int, UlongLong},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s %s", test.A, test.B), func(t *testing.T) {
+			if k := test.Model.Common(test.A, test.B); k != test.Kind {
+				t.Errorf("got %s, want %s", k, test.Kind)
+			}
+		})
+	}
+}
@@ -0,0 +1,444 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/config"
+	"github.com/SHyx0rmZ/cgen/constant"
+	"github.com/SHyx0rmZ/cgen/types"
+)
+
+// An ErrorConvention selects which integer results of C functions are
+// turned into Go errors by the cgo wrappers.
+type ErrorConvention int
+
+const (
+	NoErrors       ErrorConvention = iota // results are returned as they are
+	NonZeroErrors                         // a nonzero result is an error code, zero success
+	NegativeErrors                        // a negative result is an error code
+	ErrnoErrors                           // a negative result reports an error in errno
+)
+
+// Conventions describe how the C functions of a library map to Go.
+type Conventions struct {
+	// TrimPrefix is removed from the names of C functions, like the
+	// name of the library in "foo_open".
+	TrimPrefix string
+
+	// BoolPrefixes lists the prefixes of the functions whose integer
+	// result is a truth value, like "is_" or "has_".
+	BoolPrefixes []string
+
+	// Errors applies to the functions with a signed integer result
+	// that isn't a truth value.
+	Errors ErrorConvention
+}
+
+// cgoTypes maps the builtin C types to their names in cgo.
+var cgoTypes = map[string]string{
+	"_Bool":              "C._Bool",
+	"char":               "C.char",
+	"signed char":        "C.schar",
+	"unsigned char":      "C.uchar",
+	"short":              "C.short",
+	"unsigned short":     "C.ushort",
+	"int":                "C.int",
+	"unsigned int":       "C.uint",
+	"long":               "C.long",
+	"unsigned long":      "C.ulong",
+	"long long":          "C.longlong",
+	"unsigned long long": "C.ulonglong",
+	"float":              "C.float",
+	"double":             "C.double",
+	"float _Complex":     "C.complexfloat",
+	"double _Complex":    "C.complexdouble",
+}
+
+// GenerateCgo writes a gofmt'd Go source file with cgo wrappers for the
+// function prototypes and inline functions in nodes to w. The preamble
+// includes the header c.Header, or c.Source if it is empty.
+//
+// A const char * parameter becomes a string, copied to C memory for the
+// duration of the call. A pointer to bytes followed by an integer
+// parameter, which must be unsigned after a const char *, becomes a
+// []byte passing its length. Integer, floating and
+// _Bool parameters and results use the Go types matching their size on
+// c.Sizes, and plain char its sign on c.Model; other types are passed as their cgo types. Results follow the conventions in
+// c.Cgo. Variadic functions cannot be called through cgo and are
+// skipped.
+//
+// If c.Rules is set, the wrappers are named by the rules instead of
+// c.Cgo.TrimPrefix, and parameters and results use the Go types the
+// rules give their C types. A parameter whose type the rules override
+// with string or []byte is passed like a const char * or a buffer.
+func (c *Config) GenerateCgo(w io.Writer, nodes []ast.Node) error {
+	g := &cgoGenerator{conv: c.Cgo, rules: c.Rules, sizes: c.Sizes, model: c.Model, imports: make(map[string]bool), seen: make(map[string]bool)}
+	if g.sizes == nil {
+		g.sizes = types.LP64
+	}
+	// Without collecting nodes, the checker only knows the standard typedefs.
+	g.std = types.NewChecker(&types.Config{Sizes: g.sizes})
+	for _, node := range nodes {
+		if d, ok := node.(*ast.ExternDecl); ok {
+			node = d.Decl
+		}
+		if f, ok := node.(*ast.FuncDecl); ok {
+			g.function(f)
+		}
+	}
+
+	header := c.Header
+	if header == "" {
+		header = c.Source
+	}
+	buf := new(bytes.Buffer)
+	c.header(buf)
+	fmt.Fprintf(buf, "\n/*\n")
+	if g.imports["stdlib.h"] {
+		fmt.Fprintf(buf, "#include <stdlib.h>\n")
+	}
+	if header != "" {
+		fmt.Fprintf(buf, "#include %q\n", header)
+	}
+	fmt.Fprintf(buf, "*/\nimport \"C\"\n")
+	var imports []string
+	for _, pkg := range []string{"strconv", "unsafe"} {
+		if g.imports[pkg] {
+			imports = append(imports, fmt.Sprintf("%q", pkg))
+		}
+	}
+	switch len(imports) {
+	case 0:
+	case 1:
+		fmt.Fprintf(buf, "\nimport %s\n", imports[0])
+	default:
+		fmt.Fprintf(buf, "\nimport (\n%s\n)\n", strings.Join(imports, "\n"))
+	}
+	if g.errorType {
+		fmt.Fprintf(buf, "\n// An Error is an error code returned by a C function.\n")
+		fmt.Fprintf(buf, "type Error int\n\n")
+		fmt.Fprintf(buf, "func (e Error) Error() string { return %q + strconv.Itoa(int(e)) }\n", c.Package+": error ")
+	}
+	buf.Write(g.buf.Bytes())
+	return format(w, buf.Bytes())
+}
+
+type cgoGenerator struct {
+	conv      Conventions
+	rules     *config.Rules
+	sizes     types.Sizes    // layout of the target
+	model     constant.Model // integer types of the target
+	std       *types.Checker // types of the standard typedefs on the target
+	buf       bytes.Buffer
+	imports   map[string]bool // packages and C headers used
+	seen      map[string]bool // functions wrapped so far
+	errorType bool            // whether the Error type is used
+}
+
+func (g *cgoGenerator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// camelCase returns the exported Go name of the C function name.
+func (g *cgoGenerator) camelCase(name string) string {
+	if g.rules != nil {
+		return exported(g.rules.Name(name))
+	}
+	var s strings.Builder
+	for _, word := range strings.Split(strings.TrimPrefix(name, g.conv.TrimPrefix), "_") {
+		if word != "" {
+			s.WriteString(exported(word))
+		}
+	}
+	if s.Len() == 0 {
+		return exported(name)
+	}
+	return s.String()
+}
+
+// unqualified returns x without its qualifiers.
+func unqualified(x ast.Expr) ast.Expr {
+	for {
+		q, ok := x.(*ast.QualType)
+		if !ok {
+			return x
+		}
+		x = q.Type
+	}
+}
+
+// basicName returns the name of the builtin type x, or of the builtin
+// type of the standard integer type x on the target, or "" if x is
+// neither.
+func (g *cgoGenerator) basicName(x ast.Expr) string {
+	switch y := unqualified(x).(type) {
+	case *ast.BasicType:
+		return y.Name
+	case *ast.Ident:
+		if t, err := g.std.TypeOf(&ast.Ident{Name: y.Name}); err == nil {
+			if b, ok := types.Underlying(t).(*types.Basic); ok {
+				return b.Name
+			}
+		}
+	}
+	return ""
+}
+
+// basic returns the layout of the builtin or standard integer type x on
+// the target.
+func (g *cgoGenerator) basic(x ast.Expr) (cType, bool) {
+	return basicType(g.sizes, g.model, g.basicName(x))
+}
+
+// cgoType returns the name of the C type x in cgo, or "" if cgo can't
+// represent it.
+func cgoType(x ast.Expr) string {
+	switch y := unqualified(x).(type) {
+	case *ast.BasicType:
+		return cgoTypes[y.Name]
+	case *ast.Ident:
+		return "C." + y.Name
+	case *ast.PointerType:
+		elem := unqualified(y.Elem)
+		if b, ok := elem.(*ast.BasicType); ok && b.Name == "void" {
+			return "unsafe.Pointer"
+		}
+		if _, ok := elem.(*ast.FuncType); ok {
+			return "*[0]byte"
+		}
+		if t := cgoType(elem); t != "" {
+			return "*" + t
+		}
+	case *ast.ArrayType:
+		if t := cgoType(y.Elem); t != "" {
+			return "*" + t
+		}
+	case *ast.StructType:
+		if y.Name != nil {
+			return "C." + y.Kind.String() + "_" + y.Name.Name
+		}
+	case *ast.EnumDecl:
+		if y.Name != nil {
+			return "C.enum_" + y.Name.Name
+		}
+	}
+	return ""
+}
+
+// isConstString reports whether x is const char *.
+func (g *cgoGenerator) isConstString(x ast.Expr) bool {
+	p, ok := unqualified(x).(*ast.PointerType)
+	if !ok {
+		return false
+	}
+	q, ok := p.Elem.(*ast.QualType)
+	return ok && q.Qual&ast.CONST != 0 && g.basicName(q.Type) == "char"
+}
+
+// isBytes reports whether x points to bytes of memory.
+func (g *cgoGenerator) isBytes(x ast.Expr) bool {
+	p, ok := unqualified(x).(*ast.PointerType)
+	if !ok {
+		return false
+	}
+	switch g.basicName(p.Elem) {
+	case "void", "char", "signed char", "unsigned char":
+		return true
+	}
+	return false
+}
+
+// isLength reports whether x is the type of the length of a buffer
+// passed in the preceding parameter. Unless signed is set, the length
+// must be unsigned, so that a string followed by an int isn't taken for
+// a buffer.
+func (g *cgoGenerator) isLength(x ast.Expr, signed bool) bool {
+	t, ok := g.basic(x)
+	return ok && t.integer && t.goType != "bool" && (signed || !t.signed)
+}
+
+// goType returns the Go type used for values of the C type x by the
+// wrappers, or "" if values are passed as their cgo type.
+func (g *cgoGenerator) goType(x ast.Expr) string {
+	switch y := unqualified(x).(type) {
+	case *ast.BasicType:
+		if typ, ok := g.rules.Type(y.Name); ok {
+			return typ
+		}
+	case *ast.Ident:
+		if typ, ok := g.rules.Type(y.Name); ok {
+			return typ
+		}
+	}
+	if t, ok := g.basic(x); ok && cgoTypes[g.basicName(x)] != "" {
+		return t.goType
+	}
+	return ""
+}
+
+// A cgoParam is a parameter of a wrapper.
+type cgoParam struct {
+	name, typ string
+}
+
+// function prints the wrapper of the C function f.
+func (g *cgoGenerator) function(f *ast.FuncDecl) {
+	name := f.Name.Name
+	if g.seen[name] || !g.rules.Keep(name) {
+		return
+	}
+
+	var params []cgoParam
+	var setup, args []string
+	list := f.Type.Params.List
+	if len(list) == 1 && list[0].Name == nil && g.basicName(list[0].Type) == "void" {
+		list = nil
+	}
+	for i := 0; i < len(list); i++ {
+		p := list[i]
+		if _, ok := p.Type.(*ast.Ellipsis); ok {
+			return
+		}
+		pname := fmt.Sprintf("p%d", i)
+		override := ""
+		if p.Name != nil {
+			pname = goName(p.Name.Name)
+			override, _ = g.rules.ParamType(name, p.Name.Name)
+		}
+		ctype := cgoType(p.Type)
+		if ctype == "" {
+			return
+		}
+		tmp := fmt.Sprintf("c%d", i)
+		str := override == "string" || override == "" && g.isConstString(p.Type)
+		buf := override == "[]byte" || override == "" && g.isBytes(p.Type)
+
+		switch {
+		case buf && i+1 < len(list) && g.isLength(list[i+1].Type, !g.isConstString(p.Type)):
+			lenType := cgoType(list[i+1].Type)
+			params = append(params, cgoParam{pname, "[]byte"})
+			setup = append(setup, fmt.Sprintf("var %s unsafe.Pointer\nif len(%s) > 0 {\n%s = unsafe.Pointer(&%s[0])\n}", tmp, pname, tmp, pname))
+			if ctype != "unsafe.Pointer" {
+				tmp = fmt.Sprintf("(%s)(%s)", ctype, tmp)
+			}
+			args = append(args, tmp, fmt.Sprintf("%s(len(%s))", lenType, pname))
+			g.imports["unsafe"] = true
+			i++
+		case override == "[]byte":
+			params = append(params, cgoParam{pname, "[]byte"})
+			setup = append(setup, fmt.Sprintf("var %s unsafe.Pointer\nif len(%s) > 0 {\n%s = unsafe.Pointer(&%s[0])\n}", tmp, pname, tmp, pname))
+			if ctype != "unsafe.Pointer" {
+				tmp = fmt.Sprintf("(%s)(%s)", ctype, tmp)
+			}
+			args = append(args, tmp)
+			g.imports["unsafe"] = true
+		case str:
+			params = append(params, cgoParam{pname, "string"})
+			setup = append(setup, fmt.Sprintf("%s := C.CString(%s)\ndefer C.free(unsafe.Pointer(%s))", tmp, pname, tmp))
+			if ctype != "*C.char" {
+				tmp = fmt.Sprintf("(%s)(unsafe.Pointer(%s))", ctype, tmp)
+			}
+			args = append(args, tmp)
+			g.imports["unsafe"] = true
+			g.imports["stdlib.h"] = true
+		case override != "":
+			params = append(params, cgoParam{pname, override})
+			args = append(args, fmt.Sprintf("(%s)(%s)", ctype, pname))
+		case g.goType(p.Type) != "":
+			params = append(params, cgoParam{pname, g.goType(p.Type)})
+			args = append(args, fmt.Sprintf("%s(%s)", ctype, pname))
+		default:
+			params = append(params, cgoParam{pname, ctype})
+			args = append(args, pname)
+			if ctype == "unsafe.Pointer" {
+				g.imports["unsafe"] = true
+			}
+		}
+	}
+
+	result, body := g.result(name, f.Type.Result)
+	if body == nil {
+		return
+	}
+	g.seen[name] = true
+
+	var ps []string
+	for _, p := range params {
+		ps = append(ps, p.name+" "+p.typ)
+	}
+	gname := g.camelCase(name)
+	g.printf("\n")
+	if f.Doc != nil {
+		for _, line := range strings.Split(strings.TrimSuffix(f.Doc.Text(), "\n"), "\n") {
+			g.printf("%s\n", strings.TrimRight("// "+line, " "))
+		}
+	} else {
+		g.printf("// %s calls the C function %s.\n", gname, name)
+	}
+	g.printf("func %s(%s) %s {\n", gname, strings.Join(ps, ", "), result)
+	for _, s := range setup {
+		g.printf("%s\n", s)
+	}
+	call := fmt.Sprintf("C.%s(%s)", name, strings.Join(args, ", "))
+	for _, s := range body {
+		g.printf("%s\n", strings.Replace(s, "$call", call, -1))
+	}
+	g.printf("}\n")
+}
+
+// result returns the result list of the wrapper of the C function name
+// with the result type x, and the statements of its body, where $call
+// stands for the call of the C function. The body is nil if the result
+// type can't be represented.
+func (g *cgoGenerator) result(name string, x ast.Expr) (string, []string) {
+	basic := g.basicName(x)
+	layout, _ := g.basic(x)
+	switch {
+	case basic == "void":
+		return "", []string{"$call"}
+	case g.isConstString(x):
+		return "string", []string{"return C.GoString($call)"}
+	case basic == "_Bool":
+		return "bool", []string{"return bool($call)"}
+	}
+	typ := g.goType(x)
+	if typ == "" {
+		ctype := cgoType(x)
+		if ctype == "" {
+			return "", nil
+		}
+		if ctype == "unsafe.Pointer" {
+			g.imports["unsafe"] = true
+		}
+		return ctype, []string{"return $call"}
+	}
+	if !layout.integer {
+		return typ, []string{fmt.Sprintf("return %s($call)", typ)}
+	}
+
+	for _, prefix := range g.conv.BoolPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return "bool", []string{"return $call != 0"}
+		}
+	}
+	if !layout.signed {
+		return typ, []string{fmt.Sprintf("return %s($call)", typ)}
+	}
+	switch g.conv.Errors {
+	case NonZeroErrors:
+		g.errorType = true
+		g.imports["strconv"] = true
+		return "error", []string{"if r := $call; r != 0 {\nreturn Error(r)\n}", "return nil"}
+	case NegativeErrors:
+		g.errorType = true
+		g.imports["strconv"] = true
+		return fmt.Sprintf("(%s, error)", typ), []string{"r := $call", "if r < 0 {\nreturn 0, Error(r)\n}", fmt.Sprintf("return %s(r), nil", typ)}
+	case ErrnoErrors:
+		return fmt.Sprintf("(%s, error)", typ), []string{"r, err := $call", "if r < 0 {\nreturn 0, err\n}", fmt.Sprintf("return %s(r), nil", typ)}
+	}
+	return typ, []string{fmt.Sprintf("return %s($call)", typ)}
+}
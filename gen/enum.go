@@ -0,0 +1,253 @@
+package gen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/config"
+	"github.com/SHyx0rmZ/cgen/constant"
+)
+
+// A namedType is a Go type generated for an enumeration or a group of
+// macros, along with its constants.
+type namedType struct {
+	name       string
+	underlying string
+	consts     []namedConst
+	integer    bool // whether all constants are integers
+}
+
+type namedConst struct {
+	name  string
+	value constant.Value
+}
+
+// namedType returns the Go type name, printing its declaration with
+// the underlying type the first time.
+func (g *generator) namedType(name, underlying string) *namedType {
+	if t, ok := g.namedTypes[name]; ok {
+		return t
+	}
+	t := &namedType{name: name, underlying: underlying, integer: true}
+	g.namedTypes[name] = t
+	g.namedOrder = append(g.namedOrder, t)
+	if !g.printed[name] {
+		g.printed[name] = true
+		g.printf("type %s %s\n", name, underlying)
+	}
+	return t
+}
+
+// enumName returns the name of the Go type of the enumeration e, which
+// is declared with name, or "" if its constants are of type int32.
+// Enumerations without a name are named after the common prefix of
+// their enumerators when inferring groups.
+func (g *generator) enumName(e *ast.EnumDecl, name *ast.Ident) string {
+	switch {
+	case e.Name != nil:
+		return g.structName(e.Name.Name)
+	case name != nil:
+		return g.name(name.Name)
+	case g.infer:
+		var names []string
+		for _, spec := range e.Specs {
+			id, _ := enumerator(spec)
+			names = append(names, id.Name)
+		}
+		if prefix := commonPrefix(names); prefix != "" {
+			return g.name(strings.TrimSuffix(prefix, "_"))
+		}
+	}
+	return ""
+}
+
+// add records a constant of t. The value is nil for constants that
+// aren't integers.
+func (t *namedType) add(name string, v *constant.Value) {
+	if v == nil {
+		t.integer = false
+		return
+	}
+	t.consts = append(t.consts, namedConst{name, *v})
+}
+
+// inferGroups assigns the macros in specs that aren't in a group yet to
+// groups of the consecutive macros whose names only differ in the last
+// word, like LOG_LEVEL_DEBUG and LOG_LEVEL_INFO. Groups have at least
+// two integer macros of the same Go type.
+func (g *generator) inferGroups(specs []constSpec) {
+	for i := 0; i < len(specs); {
+		prefix := wordPrefix(specs[i].cname)
+		j := i + 1
+		for j < len(specs) && prefix != "" && wordPrefix(specs[j].cname) == prefix {
+			j++
+		}
+		if j-i >= 2 && uniformGroup(specs[i:j]) {
+			group, ok := g.inferred[prefix]
+			if !ok {
+				group = &config.Group{Prefix: prefix, Type: g.name(strings.TrimSuffix(prefix, "_"))}
+				g.inferred[prefix] = group
+			}
+			for k := i; k < j; k++ {
+				specs[k].group = group
+			}
+		}
+		i = j
+	}
+}
+
+// wordPrefix returns name up to and including its last underscore, or ""
+// if name consists of a single word.
+func wordPrefix(name string) string {
+	i := strings.LastIndexByte(strings.TrimRight(name, "_"), '_')
+	if i <= 0 {
+		return ""
+	}
+	return name[:i+1]
+}
+
+// uniformGroup reports whether specs are integer macros of the same Go
+// type, which are not in a group yet.
+func uniformGroup(specs []constSpec) bool {
+	for _, s := range specs {
+		if s.group != nil || s.v == nil || s.typ != specs[0].typ {
+			return false
+		}
+	}
+	return true
+}
+
+// commonPrefix returns the longest prefix of names ending in an
+// underscore, like LOG_ for LOG_DEBUG and LOG_INFO.
+func commonPrefix(names []string) string {
+	if len(names) < 2 {
+		return ""
+	}
+	prefix := names[0]
+	for _, name := range names[1:] {
+		for !strings.HasPrefix(name, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	i := strings.LastIndexByte(prefix, '_')
+	if i <= 0 {
+		return ""
+	}
+	return prefix[:i+1]
+}
+
+// methods prints the String methods of the named types, and the Has
+// methods of the named types that are flag sets.
+func (g *generator) methods() {
+	for _, t := range g.namedOrder {
+		if !t.integer || len(t.consts) == 0 {
+			continue
+		}
+		g.stringMethod(t)
+	}
+}
+
+// stringMethod prints the String method of t, which returns the name of
+// a constant, like stringer does. The String method of a flag set joins
+// the names of the flags set with |.
+func (g *generator) stringMethod(t *namedType) {
+	g.imports["strconv"] = true
+	recv := receiver(t)
+	flags := isFlags(t.consts)
+
+	if flags {
+		g.printf("\n// String returns the names of the flags set in %s, separated by |.\n", recv)
+	} else {
+		g.printf("\n// String returns the name of the constant %s.\n", recv)
+	}
+	g.printf("func (%s %s) String() string {\n", recv, t.name)
+	g.printf("switch %s {\n", recv)
+	seen := make(map[string]bool)
+	var bits []string
+	for _, c := range t.consts {
+		v := c.value.String()
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		g.printf("case %s:\nreturn %q\n", c.name, c.name)
+		if u, _ := c.value.Uint64(); flags && u&(u-1) == 0 && u != 0 {
+			bits = append(bits, c.name)
+		}
+	}
+	g.printf("}\n")
+
+	format := "strconv.FormatInt(int64(%s), %d)"
+	if strings.HasPrefix(t.underlying, "uint") {
+		format = "strconv.FormatUint(uint64(%s), %d)"
+	}
+	if !flags {
+		g.printf("return %q + %s + \")\"\n", t.name+"(", fmt.Sprintf(format, recv, 10))
+		g.printf("}\n")
+		return
+	}
+	g.printf("s := \"\"\n")
+	g.printf("for _, f := range []%s{%s} {\n", t.name, strings.Join(bits, ", "))
+	g.printf("if %s&f != 0 {\ns += \"|\" + f.String()\n%s &^= f\n}\n}\n", recv, recv)
+	g.printf("if %s != 0 || s == \"\" {\ns += \"|0x\" + %s\n}\n", recv, fmt.Sprintf(format, recv, 16))
+	g.printf("return s[1:]\n")
+	g.printf("}\n")
+
+	g.printf("\n// Has reports whether all flags set in flags are set in %s.\n", recv)
+	g.printf("func (%s %s) Has(flags %s) bool { return %s&flags == flags }\n", recv, t.name, t.name, recv)
+}
+
+// receiver returns a receiver name for the methods of t that doesn't
+// shadow any of its constants.
+func receiver(t *namedType) string {
+	recv := "x"
+	for {
+		ok := recv != "flags"
+		for _, c := range t.consts {
+			if c.name == recv {
+				ok = false
+			}
+		}
+		if ok {
+			return recv
+		}
+		recv += "_"
+	}
+}
+
+// isFlags reports whether consts are a flag set: at least two constants
+// are single bits and all values are combinations of these bits. Three
+// or more consecutive values starting at 0, like 0, 1 and 2, are a plain
+// enumeration.
+func isFlags(consts []namedConst) bool {
+	var bits uint64
+	n := 0
+	var values []uint64
+	for _, c := range consts {
+		u, ok := c.value.Uint64()
+		if !ok {
+			return false
+		}
+		if u != 0 && u&(u-1) == 0 {
+			bits |= u
+			n++
+		}
+		values = append(values, u)
+	}
+	if n < 2 {
+		return false
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	distinct := 1
+	for i, u := range values {
+		if u&^bits != 0 {
+			return false
+		}
+		if i > 0 && u != values[i-1] {
+			distinct++
+		}
+	}
+	return distinct < 3 || values[0] != 0 || values[len(values)-1]+1 != uint64(distinct)
+}
@@ -0,0 +1,668 @@
+// Package gen generates Go source code from parsed C headers.
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	goformat "go/format"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/config"
+	"github.com/SHyx0rmZ/cgen/constant"
+	"github.com/SHyx0rmZ/cgen/token"
+	"github.com/SHyx0rmZ/cgen/types"
+)
+
+// A Config controls the output of Generate, GenerateTest and GenerateCgo.
+type Config struct {
+	Package string      // name of the generated package
+	Source  string      // name of the C header, used in the file comment
+	Header  string      // header included by cgo wrappers; or "", for Source
+	Cgo     Conventions // conventions of the C functions wrapped with cgo
+
+	// Enums groups consecutive macros whose names only differ in the
+	// last word, and the enumerators of an enumeration without a name
+	// that share a prefix, into named Go types.
+	Enums bool
+
+	// Rules rename, filter and retype the generated declarations; or
+	// nil, to keep the C names and types.
+	Rules *config.Rules
+
+	// Sizes and Model describe the C types of the target; or nil and
+	// the zero Model, for LP64.
+	Sizes types.Sizes
+	Model constant.Model
+
+	// Predefined are the macros predefined for the target, like
+	// __SIZEOF_LONG__. Constants may refer to them, but they are not
+	// generated themselves.
+	Predefined []*ast.MacroDir
+}
+
+// Generate writes a gofmt'd Go source file for nodes to w.
+//
+// Object-like macros whose values fold to integer constants become
+// typed constant declarations, enumerations become iota-style
+// constant blocks. Nodes that have no Go representation are skipped.
+//
+// Structs and unions become Go struct types with the memory layout of
+// c.Sizes, as computed by package types, including explicit padding and
+// honouring #pragma pack and the packed and aligned attributes. Unions
+// are represented by their bytes, with methods returning pointers to
+// their members. Bit-fields are accessed through getter and setter
+// methods. Other typedefs become defined Go types. Fields of a struct or
+// union type the rules exclude are represented by their bytes, and of
+// an excluded typedef by its underlying type.
+//
+// Identifiers in constant expressions refer to the macros and
+// enumerators anywhere in nodes and to c.Predefined, like they would
+// after macro expansion.
+//
+// Named enumerations and groups of macros become named Go types with a
+// String method. Flag sets, whose constants are single bits and their
+// combinations, also get a Has method.
+//
+// If c.Rules is set, declarations are named and filtered by the rules,
+// enumerators individually, and macros of a group become constants of
+// the group's type.
+func (c *Config) Generate(w io.Writer, nodes []ast.Node) error {
+	g := newGenerator(c, nodes)
+
+	buf := new(bytes.Buffer)
+	c.header(buf)
+	var imports []string
+	for _, pkg := range []string{"strconv", "unsafe"} {
+		if g.imports[pkg] {
+			imports = append(imports, fmt.Sprintf("%q", pkg))
+		}
+	}
+	switch len(imports) {
+	case 0:
+	case 1:
+		fmt.Fprintf(buf, "\nimport %s\n", imports[0])
+	default:
+		fmt.Fprintf(buf, "\nimport (\n%s\n)\n", strings.Join(imports, "\n"))
+	}
+	buf.Write(g.buf.Bytes())
+	return format(w, buf.Bytes())
+}
+
+// GenerateTest writes a gofmt'd Go test file for the source file written
+// by Generate to w. The test checks that the sizes of the generated
+// struct types and the offsets of their fields match the C layout.
+func (c *Config) GenerateTest(w io.Writer, nodes []ast.Node) error {
+	g := newGenerator(c, nodes)
+
+	buf := new(bytes.Buffer)
+	c.header(buf)
+	if len(g.structLayouts) == 0 {
+		return format(w, buf.Bytes())
+	}
+	fmt.Fprintf(buf, "\nimport (\n\"testing\"\n\"unsafe\"\n)\n")
+	fmt.Fprintf(buf, "\nfunc TestLayout(t *testing.T) {\n")
+	fmt.Fprintf(buf, "tests := []struct {\nName string\nGot, Want uintptr\n}{\n")
+	for _, s := range g.structLayouts {
+		fmt.Fprintf(buf, "{%q, unsafe.Sizeof(%s{}), %d},\n", "unsafe.Sizeof("+s.name+"{})", s.name, s.size)
+		if s.kind == token.UNION {
+			continue
+		}
+		for _, f := range s.fields {
+			x := fmt.Sprintf("unsafe.Offsetof(%s{}.%s)", s.name, f.name)
+			fmt.Fprintf(buf, "{%q, %s, %d},\n", x, x, f.offset)
+		}
+	}
+	fmt.Fprintf(buf, "}\n\n")
+	fmt.Fprintf(buf, "for _, test := range tests {\nif test.Got != test.Want {\n")
+	fmt.Fprintf(buf, "t.Errorf(\"%%s = %%d, want %%d\", test.Name, test.Got, test.Want)\n")
+	fmt.Fprintf(buf, "}\n}\n}\n")
+	return format(w, buf.Bytes())
+}
+
+// header writes the file comment and the package clause of a generated
+// file to buf.
+func (c *Config) header(buf *bytes.Buffer) {
+	if c.Source != "" {
+		fmt.Fprintf(buf, "// Code generated by cgen from %s. DO NOT EDIT.\n\n", c.Source)
+	} else {
+		fmt.Fprintf(buf, "// Code generated by cgen. DO NOT EDIT.\n\n")
+	}
+	fmt.Fprintf(buf, "package %s\n", c.Package)
+}
+
+// format writes the gofmt'd Go source code src to w.
+func format(w io.Writer, src []byte) error {
+	src, err := goformat.Source(src)
+	if err != nil {
+		return fmt.Errorf("cgen: formatting generated code: %s", err)
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+type generator struct {
+	rules  *config.Rules
+	infer  bool // whether to infer groups of constants
+	eval   constant.Evaluator
+	buf    bytes.Buffer
+	macros []constSpec
+
+	defs      map[string]*ast.MacroDir  // object-like macros defined at the end of the input
+	enums     map[string]*ast.EnumDecl  // enumerations by enumerator
+	values    map[string]constant.Value // values resolved so far
+	resolving map[string]bool           // names being resolved
+
+	typedefs      map[string]ast.Expr               // types of typedef names
+	tags          map[string]*ast.StructType        // struct and union definitions by tag
+	tagNames      map[string]string                 // Go names of tags defined in a typedef
+	excluded      map[*ast.StructType]bool          // struct and union definitions the rules exclude
+	check         *types.Checker                    // types of the typedefs and struct tags
+	sizes         types.Sizes                       // layout of the target
+	goMaxAlign    int64                             // largest alignment of a Go type on the target
+	layouts       map[*ast.StructType]*structLayout // layouts computed so far; nil while computing
+	structLayouts []*structLayout                   // layouts of the struct types printed
+	printed       map[string]bool                   // names of the Go types printed
+	imports       map[string]bool                   // packages used by the generated code
+
+	namedTypes map[string]*namedType    // named types of constants by name
+	namedOrder []*namedType             // named types in the order printed
+	inferred   map[string]*config.Group // inferred groups of macros by prefix
+	enumTags   map[string]bool          // tags of the enumerations declared
+}
+
+// newGenerator returns a generator that has printed the Go code for nodes.
+func newGenerator(c *Config, nodes []ast.Node) *generator {
+	g := &generator{
+		rules:      c.Rules,
+		infer:      c.Enums,
+		namedTypes: make(map[string]*namedType),
+		inferred:   make(map[string]*config.Group),
+		enumTags:   make(map[string]bool),
+		defs:       make(map[string]*ast.MacroDir),
+		enums:      make(map[string]*ast.EnumDecl),
+		values:     make(map[string]constant.Value),
+		resolving:  make(map[string]bool),
+		typedefs:   make(map[string]ast.Expr),
+		tags:       make(map[string]*ast.StructType),
+		tagNames:   make(map[string]string),
+		excluded:   make(map[*ast.StructType]bool),
+		layouts:    make(map[*ast.StructType]*structLayout),
+		printed:    make(map[string]bool),
+		imports:    make(map[string]bool),
+	}
+	g.eval = constant.Evaluator{
+		Model: c.Model,
+		Ident: g.ident,
+	}
+	g.sizes = c.Sizes
+	if g.sizes == nil {
+		g.sizes = types.LP64
+	}
+	// Go aligns 64-bit types to 4 bytes on 32-bit architectures.
+	g.goMaxAlign = g.sizes.Sizeof(&types.Pointer{})
+	g.check = types.NewChecker(&types.Config{Sizes: g.sizes, Eval: g.evalInt})
+	for _, m := range c.Predefined {
+		g.collect(m)
+	}
+	for _, node := range nodes {
+		g.collect(node)
+	}
+	for _, node := range nodes {
+		g.node(node)
+	}
+	g.flushMacros()
+	g.methods()
+	return g
+}
+
+// A constSpec is a single line in a generated constant block or struct
+// type, along with the comments of the C declaration it was generated
+// from.
+type constSpec struct {
+	cname   string // name of the C declaration
+	name    string
+	typ     string
+	value   string
+	doc     *ast.CommentGroup
+	comment *ast.CommentGroup
+	group   *config.Group   // group of a macro; or nil
+	v       *constant.Value // value of an integer constant; or nil
+}
+
+// spec prints s as a line of a constant block or struct type.
+func (g *generator) spec(s constSpec) {
+	if s.doc != nil {
+		for _, line := range strings.Split(strings.TrimSuffix(s.doc.Text(), "\n"), "\n") {
+			g.printf("%s\n", strings.TrimRight("// "+line, " "))
+		}
+	}
+	switch {
+	case s.typ == "":
+		g.printf("%s", s.name)
+	case s.value == "":
+		g.printf("%s %s", s.name, s.typ)
+	default:
+		g.printf("%s %s = %s", s.name, s.typ, s.value)
+	}
+	if s.comment != nil {
+		g.printf(" // %s", strings.Join(strings.Fields(s.comment.Text()), " "))
+	}
+	g.printf("\n")
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// enumDecl returns the enumeration declared by node, if any.
+func enumDecl(node ast.Node) (*ast.EnumDecl, *ast.Ident) {
+	switch n := node.(type) {
+	case *ast.EnumDecl:
+		return n, n.Name
+	case *ast.StructDecl:
+		if e, ok := n.Type.(*ast.EnumDecl); ok {
+			return e, e.Name
+		}
+	case *ast.TypeDecl:
+		if e, ok := n.Type.(*ast.EnumDecl); ok {
+			return e, n.Name
+		}
+	}
+	return nil, nil
+}
+
+// collect records the macros, enumerators, typedefs and struct tags
+// declared by node.
+func (g *generator) collect(node ast.Node) {
+	switch m := node.(type) {
+	case *ast.MacroDir:
+		if m.Args == nil && m.Value != nil {
+			g.defs[m.Name.Name] = m
+		} else {
+			delete(g.defs, m.Name.Name)
+		}
+	case *ast.UndefDir:
+		delete(g.defs, m.Name.Name)
+	}
+	if e, _ := enumDecl(node); e != nil {
+		for _, spec := range e.Specs {
+			name, _ := enumerator(spec)
+			g.enums[name.Name] = e
+		}
+	}
+	if d, ok := node.(*ast.TypeDecl); ok {
+		g.typedefs[d.Name.Name] = d.Type
+		if st, ok := d.Type.(*ast.StructType); ok && st.Name != nil && st.Fields != nil {
+			g.tagNames[st.Name.Name] = g.name(d.Name.Name)
+		}
+		if e, ok := d.Type.(*ast.EnumDecl); ok && e.Name != nil && e.Specs != nil {
+			g.tagNames[e.Name.Name] = g.name(d.Name.Name)
+		}
+	}
+	if e, _ := enumDecl(node); e != nil && e.Name != nil {
+		g.enumTags[e.Name.Name] = true
+	}
+	g.check.Collect(node)
+	if st, _ := g.structDecl(node); st != nil {
+		if st.Name != nil {
+			g.tags[st.Name.Name] = st
+		}
+		g.excluded[st] = !g.keep(node)
+	}
+}
+
+// ident resolves the name of a macro or an enumerator to its value.
+func (g *generator) ident(name string) (constant.Value, error) {
+	if v, ok := g.values[name]; ok {
+		return v, nil
+	}
+	if g.resolving[name] {
+		return constant.Value{}, fmt.Errorf("%s refers to itself", name)
+	}
+	g.resolving[name] = true
+	defer delete(g.resolving, name)
+
+	if e, ok := g.enums[name]; ok {
+		g.enumValues(e)
+		if v, ok := g.values[name]; ok {
+			return v, nil
+		}
+		return constant.Value{}, fmt.Errorf("cannot evaluate %s", name)
+	}
+	if m, ok := g.defs[name]; ok {
+		v, err := g.eval.Eval(m.Value)
+		if err != nil {
+			return constant.Value{}, err
+		}
+		g.values[name] = v
+		return v, nil
+	}
+	return constant.Value{}, fmt.Errorf("undefined: %s", name)
+}
+
+// evalInt returns the value of the integer constant expression x.
+func (g *generator) evalInt(x ast.Expr) (int64, error) {
+	v, err := g.eval.Eval(x)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := v.Int64()
+	if !ok {
+		return 0, fmt.Errorf("%s overflows int64", v)
+	}
+	return n, nil
+}
+
+func enumerator(spec ast.EnumSpec) (*ast.Ident, ast.Expr) {
+	switch s := spec.(type) {
+	case *ast.EnumValue:
+		if s.Value != nil {
+			return s.Name, s.Value
+		}
+		return s.Name, nil
+	case *ast.EnumConstExpr:
+		return s.Name, s.Expr
+	}
+	return nil, nil
+}
+
+// enumValues computes the values of the enumerators of e, up to the
+// first one that cannot be evaluated. Enumerators have type int.
+func (g *generator) enumValues(e *ast.EnumDecl) []constant.Value {
+	var values []constant.Value
+	next := constant.MakeInt64(0, constant.Int)
+	for _, spec := range e.Specs {
+		name, x := enumerator(spec)
+		v, ok := g.values[name.Name]
+		if !ok {
+			v = next
+			if x != nil {
+				var err error
+				v, err = g.eval.Eval(x)
+				if err != nil {
+					// Later enumerators depend on this value.
+					break
+				}
+			}
+			v = g.eval.Model.Convert(v, constant.Int)
+			g.values[name.Name] = v
+		}
+		values = append(values, v)
+		n, _ := v.Int64()
+		next = constant.MakeInt64(n+1, constant.Int)
+	}
+	return values
+}
+
+func (g *generator) node(node ast.Node) {
+	if e, name := enumDecl(node); e != nil {
+		g.flushMacros()
+		g.enum(e, name)
+		return
+	}
+	if st, name := g.structDecl(node); st != nil {
+		g.flushMacros()
+		if g.keep(node) {
+			g.structs(st, name)
+		}
+		return
+	}
+	switch n := node.(type) {
+	case *ast.MacroDir:
+		g.macro(n)
+	case *ast.TypeDecl:
+		g.flushMacros()
+		if g.keep(node) {
+			g.typedef(n)
+		}
+	}
+}
+
+// keep reports whether the rules keep the typedef, struct or union
+// declared by node, which requires keeping both the typedef name and
+// the tag.
+func (g *generator) keep(node ast.Node) bool {
+	var names []string
+	var x ast.Expr
+	switch n := node.(type) {
+	case *ast.TypeDecl:
+		names, x = append(names, n.Name.Name), n.Type
+	case *ast.StructDecl:
+		x = n.Type
+	case *ast.VarDecl:
+		x = n.Type
+	}
+	if st, ok := x.(*ast.StructType); ok && st.Name != nil {
+		names = append(names, st.Name.Name)
+	}
+	for _, name := range names {
+		if !g.rules.Keep(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// macro records the value of an object-like macro if it folds to an
+// integer. Consecutive macros are collected into a single block. Only
+// the definition in effect at the end of the input is recorded.
+func (g *generator) macro(m *ast.MacroDir) {
+	if g.defs[m.Name.Name] != m || !g.rules.Keep(m.Name.Name) {
+		return
+	}
+	name, group := g.name(m.Name.Name), g.rules.Group(m.Name.Name)
+	v, err := g.ident(m.Name.Name)
+	if err != nil {
+		if typ, value, ok := floatConst(m.Value); ok {
+			g.macros = append(g.macros, constSpec{
+				cname:   m.Name.Name,
+				name:    name,
+				typ:     typ,
+				value:   value,
+				doc:     m.Doc,
+				comment: m.Comment,
+				group:   group,
+			})
+		}
+		return
+	}
+	g.macros = append(g.macros, constSpec{
+		cname:   m.Name.Name,
+		name:    name,
+		typ:     g.goType(v.Kind()),
+		value:   literal(m.Value, v),
+		doc:     m.Doc,
+		comment: m.Comment,
+		group:   group,
+		v:       &v,
+	})
+}
+
+// flushMacros prints the macros collected so far. The macros of a group
+// follow in a block of their own, typed with the Go type of the group,
+// which is declared before its first block.
+func (g *generator) flushMacros() {
+	if len(g.macros) == 0 {
+		return
+	}
+	if g.infer {
+		g.inferGroups(g.macros)
+	}
+	var groups []*config.Group
+	blocks := make(map[*config.Group][]constSpec)
+	for _, s := range g.macros {
+		if _, ok := blocks[s.group]; !ok && s.group != nil {
+			groups = append(groups, s.group)
+		}
+		blocks[s.group] = append(blocks[s.group], s)
+	}
+	g.macros = nil
+
+	if specs := blocks[nil]; len(specs) > 0 {
+		g.constBlock(specs)
+	}
+	for _, group := range groups {
+		specs := blocks[group]
+		underlying := group.Underlying
+		if underlying == "" {
+			underlying = specs[0].typ
+		}
+		g.printf("\n")
+		t := g.namedType(goName(group.Type), underlying)
+		for i := range specs {
+			specs[i].typ = t.name
+			t.add(specs[i].name, specs[i].v)
+		}
+		g.constBlock(specs)
+	}
+}
+
+func (g *generator) constBlock(specs []constSpec) {
+	g.printf("\nconst (\n")
+	for _, s := range specs {
+		g.spec(s)
+	}
+	g.printf(")\n")
+}
+
+// enum prints an enumeration as a constant block using iota, preceded
+// by the declaration of its named type, if any. An enumerator only
+// repeats the previous expression as long as it continues the previous
+// enumerator's sequence. Enumerators the rules don't keep are left out.
+func (g *generator) enum(e *ast.EnumDecl, name *ast.Ident) {
+	typ := g.enumName(e, name)
+	var specs []constSpec
+	var values []constant.Value
+	offset := int64(0)
+	for i, v := range g.enumValues(e) {
+		id, _ := enumerator(e.Specs[i])
+		if !g.rules.Keep(id.Name) {
+			continue
+		}
+		doc, comment := enumComments(e.Specs[i])
+		values = append(values, v)
+		n, _ := v.Int64()
+		j := int64(len(specs))
+		if j > 0 && n-j == offset {
+			specs = append(specs, constSpec{name: g.name(id.Name), doc: doc, comment: comment})
+			continue
+		}
+		offset = n - j
+		specs = append(specs, constSpec{
+			name:    g.name(id.Name),
+			typ:     "int32",
+			value:   iotaExpr(offset),
+			doc:     doc,
+			comment: comment,
+		})
+	}
+	if len(specs) == 0 && typ == "" {
+		return
+	}
+
+	g.printf("\n")
+	if name != nil {
+		g.printf("// enum %s\n", name.Name)
+	}
+	if typ != "" {
+		t := g.namedType(typ, "int32")
+		for i := range specs {
+			if specs[i].typ != "" {
+				specs[i].typ = t.name
+			}
+			t.add(specs[i].name, &values[i])
+		}
+		if len(specs) == 0 {
+			return
+		}
+		g.printf("\n")
+	}
+	g.printf("const (\n")
+	for _, s := range specs {
+		g.spec(s)
+	}
+	g.printf(")\n")
+}
+
+// enumComments returns the comments of an enumerator.
+func enumComments(spec ast.EnumSpec) (doc, comment *ast.CommentGroup) {
+	switch s := spec.(type) {
+	case *ast.EnumValue:
+		return s.Doc, s.Comment
+	case *ast.EnumConstExpr:
+		return s.Doc, s.Comment
+	}
+	return nil, nil
+}
+
+func iotaExpr(offset int64) string {
+	switch {
+	case offset > 0:
+		return fmt.Sprintf("iota + %d", offset)
+	case offset < 0:
+		return fmt.Sprintf("iota - %d", -offset)
+	}
+	return "iota"
+}
+
+// goType returns the Go type matching the integer kind k.
+func (g *generator) goType(k constant.Kind) string {
+	if k.Unsigned() {
+		return fmt.Sprintf("uint%d", g.eval.Model.Bits(k))
+	}
+	return fmt.Sprintf("int%d", g.eval.Model.Bits(k))
+}
+
+// literal returns the Go representation of v. A single C literal keeps
+// its spelling without the suffix, as long as Go reads it the same way.
+// Computed values are printed in decimal.
+func literal(x ast.Expr, v constant.Value) string {
+	lit, ok := x.(*ast.BasicLit)
+	if !ok {
+		return v.String()
+	}
+	switch lit.Kind {
+	case token.INT:
+		return strings.TrimSuffix(lit.Value, lit.Suffix)
+	case token.CHAR:
+		// Go rune literals share the common escape sequences of C.
+		s, err := strconv.Unquote(lit.Value)
+		r, size := utf8.DecodeRuneInString(s)
+		if n, _ := v.Int64(); err == nil && r != utf8.RuneError && size == len(s) && int64(r) == n {
+			return lit.Value
+		}
+	}
+	return v.String()
+}
+
+// floatConst returns the Go type and value of a macro that is a single,
+// possibly negated, floating constant.
+func floatConst(x ast.Expr) (typ, value string, ok bool) {
+	sign := ""
+	for {
+		switch y := x.(type) {
+		case *ast.ParenExpr:
+			x = y.Expr
+			continue
+		case *ast.UnaryExpr:
+			if y.Op == token.SUB && sign == "" {
+				sign, x = "-", y.X
+				continue
+			}
+		}
+		break
+	}
+	lit, ok := x.(*ast.BasicLit)
+	if !ok || lit.Kind != token.FLOAT {
+		return "", "", false
+	}
+	typ = "float64"
+	if lit.Suffix == "f" || lit.Suffix == "F" {
+		typ = "float32"
+	}
+	return typ, sign + strings.TrimSuffix(lit.Value, lit.Suffix), true
+}
@@ -0,0 +1,966 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/config"
+	"github.com/SHyx0rmZ/cgen/constant"
+	"github.com/SHyx0rmZ/cgen/lexer"
+	"github.com/SHyx0rmZ/cgen/parser"
+	"github.com/SHyx0rmZ/cgen/preprocessor"
+	"github.com/SHyx0rmZ/cgen/token"
+	"github.com/SHyx0rmZ/cgen/types"
+)
+
+func TestConfig_Generate(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value string
+	}{
+		{
+			"#define A 1\n#define B (A | 0x10)\n#define C 0x80000000\n#define D -1 / 1u\n#define E(X) X\n#define F",
+			`// Code generated by cgen from test.h. DO NOT EDIT.
+
+package test
+
+const (
+	A int32  = 1
+	B int32  = 17
+	C uint32 = 0x80000000
+	D uint32 = 4294967295
+)
+`,
+		},
+		{
+			"#define A 1\n#define B 2\n#undef A\n#define A 0x80000000\n#define C 3\n#undef C\n#define B(X) X",
+			`// Code generated by cgen from test.h. DO NOT EDIT.
+
+package test
+
+const (
+	A uint32 = 0x80000000
+)
+`,
+		},
+		{
+			"#define FLAG_C (FLAG_A | FLAG_B << 4)\n#define FLAG_A 1\n#define FLAG_B 2\n#define BIG 0x100000000\n#define LOOP LOOP\n#define E (X - 1)\nenum { X = FLAG_C };",
+			`// Code generated by cgen from test.h. DO NOT EDIT.
+
+package test
+
+const (
+	FLAG_C int32 = 33
+	FLAG_A int32 = 1
+	FLAG_B int32 = 2
+	BIG    int64 = 0x100000000
+	E      int32 = 32
+)
+
+const (
+	X int32 = iota + 33
+)
+`,
+		},
+		{
+			"#define A 10UL\n#define B 017\n#define C 'a'\n#define D '\\0'\n#define E '\\xff'\n#define F 1.5f\n#define G (-.5e3)\n#define H 0x1p-2L",
+			`// Code generated by cgen from test.h. DO NOT EDIT.
+
+package test
+
+const (
+	A uint64  = 10
+	B int32   = 017
+	C int32   = 'a'
+	D int32   = 0
+	E int32   = -1
+	F float32 = 1.5
+	G float64 = -.5e3
+	H float64 = 0x1p-2
+)
+`,
+		},
+		{
+			"typedef enum { A, B, C = 7, D } letters;\nenum { E = -1 };",
+			`// Code generated by cgen from test.h. DO NOT EDIT.
+
+package test
+
+import "strconv"
+
+// enum letters
+type letters int32
+
+const (
+	A letters = iota
+	B
+	C letters = iota + 5
+	D
+)
+
+const (
+	E int32 = iota - 1
+)
+
+// String returns the name of the constant x.
+func (x letters) String() string {
+	switch x {
+	case A:
+		return "A"
+	case B:
+		return "B"
+	case C:
+		return "C"
+	case D:
+		return "D"
+	}
+	return "letters(" + strconv.FormatInt(int64(x), 10) + ")"
+}
+`,
+		},
+		{
+			"/**\n * Maximum length.\n */\n#define MAX 16 // in bytes\n\n// Modes.\nenum {\n\t// Read only.\n\tREAD,\n\tWRITE, /* also reads */\n};",
+			`// Code generated by cgen from test.h. DO NOT EDIT.
+
+package test
+
+const (
+	// Maximum length.
+	MAX int32 = 16 // in bytes
+)
+
+const (
+	// Read only.
+	READ  int32 = iota
+	WRITE       // also reads
+)
+`,
+		},
+		{
+			"struct hdr {\n\tchar kind;\n\tunsigned len : 12;\n\tint *data;\n\tunion { int i; float f; } v;\n};\ntypedef struct hdr hdr_t;\n",
+			`// Code generated by cgen from test.h. DO NOT EDIT.
+
+package test
+
+import "unsafe"
+
+// union hdr_V
+type hdr_V struct {
+	_ [0]uint32
+	_ [4]byte
+}
+
+// I returns a pointer to the member I of u.
+func (u *hdr_V) I() *int32 { return (*int32)(unsafe.Pointer(u)) }
+
+// F returns a pointer to the member F of u.
+func (u *hdr_V) F() *float32 { return (*float32)(unsafe.Pointer(u)) }
+
+// struct hdr
+type hdr struct {
+	Kind int8
+	_    [7]byte
+	Data *int32
+	V    hdr_V
+	_    [4]byte
+}
+
+// Len returns the bit-field Len of s.
+func (s *hdr) Len() uint32 {
+	return *(*uint32)(unsafe.Pointer(s)) >> 8 & 0xfff
+}
+
+// SetLen sets the bit-field Len of s to v.
+func (s *hdr) SetLen(v uint32) {
+	p := (*uint32)(unsafe.Pointer(s))
+	*p = *p&^(0xfff<<8) | (v&0xfff)<<8
+}
+
+type hdr_t = hdr
+`,
+		},
+		{
+			"struct __attribute__((packed)) p {\n\tchar c;\n\tint i;\n\tshort s __attribute__((aligned(2)));\n};\n",
+			`// Code generated by cgen from test.h. DO NOT EDIT.
+
+package test
+
+// struct p
+type p struct {
+	C int8
+	I [4]byte
+	_ [1]byte
+	S int16
+}
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s", test.Input), func(t *testing.T) {
+			nodes := parser.NewParser(t.Name(), test.Input).Nodes()
+			config := &Config{Package: "test", Source: "test.h"}
+			buf := new(bytes.Buffer)
+			if err := config.Generate(buf, nodes); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != test.Value {
+				t.Errorf("got:\n%s\nwant:\n%s", buf.String(), test.Value)
+			}
+		})
+	}
+}
+
+func TestConfig_GenerateEnum(t *testing.T) {
+	ident := func(name string) *ast.Ident { return &ast.Ident{Name: name} }
+	lit := func(value string) *ast.BasicLit { return &ast.BasicLit{Kind: token.INT, Value: value} }
+	nodes := []ast.Node{
+		&ast.EnumDecl{
+			Name: ident("color"),
+			Specs: []ast.EnumSpec{
+				&ast.EnumValue{Name: ident("RED")},
+				&ast.EnumValue{Name: ident("GREEN")},
+				&ast.EnumValue{Name: ident("BLUE"), Value: lit("5")},
+				&ast.EnumValue{Name: ident("ALPHA")},
+				&ast.EnumConstExpr{
+					Name: ident("WHITE"),
+					Expr: &ast.BinaryExpr{X: ident("RED"), Op: token.OR, Y: lit("0x10")},
+				},
+			},
+		},
+	}
+	want := `// Code generated by cgen. DO NOT EDIT.
+
+package test
+
+import "strconv"
+
+// enum color
+type color int32
+
+const (
+	RED color = iota
+	GREEN
+	BLUE color = iota + 3
+	ALPHA
+	WHITE color = iota + 12
+)
+
+// String returns the name of the constant x.
+func (x color) String() string {
+	switch x {
+	case RED:
+		return "RED"
+	case GREEN:
+		return "GREEN"
+	case BLUE:
+		return "BLUE"
+	case ALPHA:
+		return "ALPHA"
+	case WHITE:
+		return "WHITE"
+	}
+	return "color(" + strconv.FormatInt(int64(x), 10) + ")"
+}
+`
+
+	config := &Config{Package: "test"}
+	buf := new(bytes.Buffer)
+	if err := config.Generate(buf, nodes); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestConfig_GenerateEnums(t *testing.T) {
+	input := "#define OPEN_READ 0x1\n#define OPEN_WRITE 0x2\n#define OPEN_RW (OPEN_READ | OPEN_WRITE)\n#define MAX_SIZE 10\n" +
+		"enum { LOG_DEBUG, LOG_INFO, LOG_WARN };\nstruct logger { enum log_dest { DEST_FILE } dest; };\n"
+	want := `// Code generated by cgen from test.h. DO NOT EDIT.
+
+package test
+
+import "strconv"
+
+const (
+	MAX_SIZE int32 = 10
+)
+
+type OPEN int32
+
+const (
+	OPEN_READ  OPEN = 0x1
+	OPEN_WRITE OPEN = 0x2
+	OPEN_RW    OPEN = 3
+)
+
+type LOG int32
+
+const (
+	LOG_DEBUG LOG = iota
+	LOG_INFO
+	LOG_WARN
+)
+
+// struct logger
+type logger struct {
+	Dest int32
+}
+
+// String returns the names of the flags set in x, separated by |.
+func (x OPEN) String() string {
+	switch x {
+	case OPEN_READ:
+		return "OPEN_READ"
+	case OPEN_WRITE:
+		return "OPEN_WRITE"
+	case OPEN_RW:
+		return "OPEN_RW"
+	}
+	s := ""
+	for _, f := range []OPEN{OPEN_READ, OPEN_WRITE} {
+		if x&f != 0 {
+			s += "|" + f.String()
+			x &^= f
+		}
+	}
+	if x != 0 || s == "" {
+		s += "|0x" + strconv.FormatInt(int64(x), 16)
+	}
+	return s[1:]
+}
+
+// Has reports whether all flags set in flags are set in x.
+func (x OPEN) Has(flags OPEN) bool { return x&flags == flags }
+
+// String returns the name of the constant x.
+func (x LOG) String() string {
+	switch x {
+	case LOG_DEBUG:
+		return "LOG_DEBUG"
+	case LOG_INFO:
+		return "LOG_INFO"
+	case LOG_WARN:
+		return "LOG_WARN"
+	}
+	return "LOG(" + strconv.FormatInt(int64(x), 10) + ")"
+}
+`
+
+	config := &Config{Package: "test", Source: "test.h", Enums: true}
+	buf := new(bytes.Buffer)
+	if err := config.Generate(buf, parser.NewParser("test.h", input).Nodes()); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestConfig_GenerateSizes(t *testing.T) {
+	input := "#define MAX 10UL\nstruct s {\n\tchar c;\n\tlong l;\n\tlong long ll;\n\tsize_t n;\n};\n"
+	tests := []struct {
+		Sizes types.Sizes
+		Model constant.Model
+		Value string
+	}{
+		{
+			types.LP64, constant.LP64,
+			`// Code generated by cgen from test.h. DO NOT EDIT.
+
+package test
+
+const (
+	MAX uint64 = 10
+)
+
+// struct s
+type s struct {
+	C  int8
+	_  [7]byte
+	L  int64
+	Ll int64
+	N  uint64
+}
+`,
+		},
+		{
+			types.ILP32, constant.Model{Long: 32},
+			`// Code generated by cgen from test.h. DO NOT EDIT.
+
+package test
+
+const (
+	MAX uint32 = 10
+)
+
+// struct s
+type s struct {
+	C  int8
+	_  [3]byte
+	L  int32
+	Ll int64
+	N  uint32
+}
+`,
+		},
+		{
+			types.LLP64, constant.Model{Long: 32},
+			`// Code generated by cgen from test.h. DO NOT EDIT.
+
+package test
+
+const (
+	MAX uint32 = 10
+)
+
+// struct s
+type s struct {
+	C  int8
+	_  [3]byte
+	L  int32
+	Ll int64
+	N  uint64
+}
+`,
+		},
+		{
+			types.SizesFor("linux", "arm"), constant.Model{Long: 32, CharUnsigned: true},
+			`// Code generated by cgen from test.h. DO NOT EDIT.
+
+package test
+
+const (
+	MAX uint32 = 10
+)
+
+// struct s
+type s struct {
+	C  uint8
+	_  [3]byte
+	L  int32
+	Ll int64
+	N  uint32
+	_  [4]byte
+}
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%v", test.Sizes), func(t *testing.T) {
+			config := &Config{Package: "test", Source: "test.h", Sizes: test.Sizes, Model: test.Model}
+			buf := new(bytes.Buffer)
+			if err := config.Generate(buf, parser.NewParser("test.h", input).Nodes()); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != test.Value {
+				t.Errorf("got:\n%s\nwant:\n%s", buf.String(), test.Value)
+			}
+		})
+	}
+}
+
+func TestSplitTargets(t *testing.T) {
+	input := "#define MAX 10UL\nenum { X, Y };\nstruct s {\n\tchar c;\n\tlong l;\n};\nunion u { short *p; };\n"
+	var files [][]byte
+	for _, sizes := range []types.Sizes{types.LP64, types.LLP64} {
+		config := &Config{Package: "test", Source: "test.h", Sizes: sizes, Model: constant.Model{Long: 8 * int(sizes.Sizeof(&types.Basic{Name: "long"}))}}
+		buf := new(bytes.Buffer)
+		if err := config.Generate(buf, parser.NewParser("test.h", input).Nodes()); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, buf.Bytes())
+	}
+	shared, targets, err := SplitTargets(files, []string{"linux && amd64", "windows && amd64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`// Code generated by cgen from test.h. DO NOT EDIT.
+
+//go:build (linux && amd64) || (windows && amd64)
+
+package test
+
+import "unsafe"
+
+const (
+	X int32 = iota
+	Y
+)
+
+// union u
+type u struct {
+	_ [0]uint64
+	_ [8]byte
+}
+
+// P returns a pointer to the member P of u.
+func (u *u) P() **int16 { return (**int16)(unsafe.Pointer(u)) }
+`,
+		`// Code generated by cgen from test.h. DO NOT EDIT.
+
+//go:build linux && amd64
+
+package test
+
+const (
+	MAX uint64 = 10
+)
+
+// struct s
+type s struct {
+	C int8
+	_ [7]byte
+	L int64
+}
+`,
+		`// Code generated by cgen from test.h. DO NOT EDIT.
+
+//go:build windows && amd64
+
+package test
+
+const (
+	MAX uint32 = 10
+)
+
+// struct s
+type s struct {
+	C int8
+	_ [3]byte
+	L int32
+}
+`,
+	}
+	for i, got := range append([][]byte{shared}, targets...) {
+		if string(got) != want[i] {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want[i])
+		}
+	}
+}
+
+func TestConfig_GenerateTest(t *testing.T) {
+	input := "struct hdr {\n\tchar kind;\n\tunsigned len : 12;\n\tint *data;\n\tunion { int i; float f; } v;\n};"
+	want := `// Code generated by cgen from test.h. DO NOT EDIT.
+
+package test
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestLayout(t *testing.T) {
+	tests := []struct {
+		Name      string
+		Got, Want uintptr
+	}{
+		{"unsafe.Sizeof(hdr_V{})", unsafe.Sizeof(hdr_V{}), 4},
+		{"unsafe.Sizeof(hdr{})", unsafe.Sizeof(hdr{}), 24},
+		{"unsafe.Offsetof(hdr{}.Kind)", unsafe.Offsetof(hdr{}.Kind), 0},
+		{"unsafe.Offsetof(hdr{}.Data)", unsafe.Offsetof(hdr{}.Data), 8},
+		{"unsafe.Offsetof(hdr{}.V)", unsafe.Offsetof(hdr{}.V), 16},
+	}
+
+	for _, test := range tests {
+		if test.Got != test.Want {
+			t.Errorf("%s = %d, want %d", test.Name, test.Got, test.Want)
+		}
+	}
+}
+`
+
+	config := &Config{Package: "test", Source: "test.h"}
+	buf := new(bytes.Buffer)
+	if err := config.GenerateTest(buf, parser.NewParser("test.h", input).Nodes()); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestConfig_GenerateCgo(t *testing.T) {
+	input := "// Opens the device at path.\nint dev_open(const char *path);\nint dev_write(int fd, const void *buf, size_t len);\nint dev_is_ready(int fd);\nconst char *dev_name(int fd);\nvoid dev_log(const char *format, ...);\n"
+	want := `// Code generated by cgen from dev.h. DO NOT EDIT.
+
+package dev
+
+/*
+#include <stdlib.h>
+#include "dev.h"
+*/
+import "C"
+
+import (
+	"strconv"
+	"unsafe"
+)
+
+// An Error is an error code returned by a C function.
+type Error int
+
+func (e Error) Error() string { return "dev: error " + strconv.Itoa(int(e)) }
+
+// Opens the device at path.
+func Open(path string) (int32, error) {
+	c0 := C.CString(path)
+	defer C.free(unsafe.Pointer(c0))
+	r := C.dev_open(c0)
+	if r < 0 {
+		return 0, Error(r)
+	}
+	return int32(r), nil
+}
+
+// Write calls the C function dev_write.
+func Write(fd int32, buf []byte) (int32, error) {
+	var c1 unsafe.Pointer
+	if len(buf) > 0 {
+		c1 = unsafe.Pointer(&buf[0])
+	}
+	r := C.dev_write(C.int(fd), c1, C.size_t(len(buf)))
+	if r < 0 {
+		return 0, Error(r)
+	}
+	return int32(r), nil
+}
+
+// IsReady calls the C function dev_is_ready.
+func IsReady(fd int32) bool {
+	return C.dev_is_ready(C.int(fd)) != 0
+}
+
+// Name calls the C function dev_name.
+func Name(fd int32) string {
+	return C.GoString(C.dev_name(C.int(fd)))
+}
+`
+
+	config := &Config{
+		Package: "dev",
+		Source:  "dev.h",
+		Cgo: Conventions{
+			TrimPrefix:   "dev_",
+			BoolPrefixes: []string{"dev_is_"},
+			Errors:       NegativeErrors,
+		},
+	}
+	buf := new(bytes.Buffer)
+	if err := config.GenerateCgo(buf, parser.NewParser("dev.h", input).Nodes()); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestConfig_GenerateCgoSizes(t *testing.T) {
+	input := "long dev_seek(long off);\nint64_t dev_size(size_t n);\n"
+	tests := []struct {
+		Input types.Sizes
+		Value string
+	}{
+		{types.LP64, "func Seek(off int64) int64 {\nfunc Size(n uint64) int64 {\n"},
+		{types.LLP64, "func Seek(off int32) int32 {\nfunc Size(n uint64) int64 {\n"},
+		{types.ILP32, "func Seek(off int32) int32 {\nfunc Size(n uint32) int64 {\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("long=%d", test.Input.Sizeof(&types.Basic{Name: "long"})), func(t *testing.T) {
+			config := &Config{Package: "dev", Source: "dev.h", Sizes: test.Input, Cgo: Conventions{TrimPrefix: "dev_"}}
+			buf := new(bytes.Buffer)
+			if err := config.GenerateCgo(buf, parser.NewParser("dev.h", input).Nodes()); err != nil {
+				t.Fatal(err)
+			}
+			var got strings.Builder
+			for _, line := range strings.SplitAfter(buf.String(), "\n") {
+				if strings.HasPrefix(line, "func ") {
+					got.WriteString(line)
+				}
+			}
+			if got.String() != test.Value {
+				t.Errorf("got:\n%s\nwant:\n%s", got.String(), test.Value)
+			}
+		})
+	}
+}
+
+func TestConfig_GenerateRules(t *testing.T) {
+	rules, err := config.Parse("rules.toml", []byte(`
+trim_prefix = ["FOO_", "foo_"]
+camel_case = true
+exclude = ["_internal"]
+
+[types]
+size_t = "uintptr"
+
+[[rename]]
+match = "^foo_ctx_(.*)$"
+replace = "Context_$1"
+
+[[param]]
+func = "foo_read"
+name = "buf"
+type = "[]byte"
+
+[[group]]
+prefix = "FOO_FLAG_"
+type = "Flag"
+underlying = "uint32"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := "#define FOO_FLAG_READ 1\n#define FOO_VERSION 3\n#define FOO_FLAG_WRITE 2\n#define FOO_internal 4\n" +
+		"enum foo_color { FOO_RED, FOO_BLUE_internal, FOO_GREEN };\n" +
+		"struct foo_ctx_data { size_t len; };\n" +
+		"int foo_read(char *buf, size_t size);\nint foo_internal_reset(void);\n"
+	nodes := parser.NewParser("foo.h", input).Nodes()
+	c := &Config{Package: "foo", Source: "foo.h", Rules: rules}
+
+	want := `// Code generated by cgen from foo.h. DO NOT EDIT.
+
+package foo
+
+import "strconv"
+
+const (
+	Version int32 = 3
+)
+
+type Flag uint32
+
+const (
+	FlagRead  Flag = 1
+	FlagWrite Flag = 2
+)
+
+// enum foo_color
+type Color int32
+
+const (
+	Red   Color = iota
+	Green Color = iota + 1
+)
+
+// struct foo_ctx_data
+type Context_data struct {
+	Len uintptr
+}
+
+// String returns the names of the flags set in x, separated by |.
+func (x Flag) String() string {
+	switch x {
+	case FlagRead:
+		return "FlagRead"
+	case FlagWrite:
+		return "FlagWrite"
+	}
+	s := ""
+	for _, f := range []Flag{FlagRead, FlagWrite} {
+		if x&f != 0 {
+			s += "|" + f.String()
+			x &^= f
+		}
+	}
+	if x != 0 || s == "" {
+		s += "|0x" + strconv.FormatUint(uint64(x), 16)
+	}
+	return s[1:]
+}
+
+// Has reports whether all flags set in flags are set in x.
+func (x Flag) Has(flags Flag) bool { return x&flags == flags }
+
+// String returns the name of the constant x.
+func (x Color) String() string {
+	switch x {
+	case Red:
+		return "Red"
+	case Green:
+		return "Green"
+	}
+	return "Color(" + strconv.FormatInt(int64(x), 10) + ")"
+}
+`
+	buf := new(bytes.Buffer)
+	if err := c.Generate(buf, nodes); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	want = `// Code generated by cgen from foo.h. DO NOT EDIT.
+
+package foo
+
+/*
+#include "foo.h"
+*/
+import "C"
+
+import "unsafe"
+
+// Read calls the C function foo_read.
+func Read(buf []byte) int32 {
+	var c0 unsafe.Pointer
+	if len(buf) > 0 {
+		c0 = unsafe.Pointer(&buf[0])
+	}
+	return int32(C.foo_read((*C.char)(c0), C.size_t(len(buf))))
+}
+`
+	buf.Reset()
+	if err := c.GenerateCgo(buf, nodes); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestConfig_GenerateExcluded(t *testing.T) {
+	rules, err := config.Parse("rules.toml", []byte("camel_case = true\nexclude = [\"inner\", \"count\"]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := "typedef int count;\ntypedef struct inner { short a; } inner_t;\n" +
+		"struct outer { inner_t in; struct inner *p; count n; struct inner arr[2]; };\n"
+	want := `// Code generated by cgen from test.h. DO NOT EDIT.
+
+package test
+
+import "unsafe"
+
+// struct outer
+type Outer struct {
+	In  [2]byte
+	_   [6]byte
+	P   unsafe.Pointer
+	N   int32
+	Arr [2][2]byte
+}
+`
+
+	config := &Config{Package: "test", Source: "test.h", Rules: rules}
+	buf := new(bytes.Buffer)
+	if err := config.Generate(buf, parser.NewParser("test.h", input).Nodes()); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+	vet(t, map[string][]byte{"test.go": buf.Bytes()})
+}
+
+func TestSplitTargets_Specs(t *testing.T) {
+	input := "#define A 1\n#ifdef _WIN32\n#define W 2\n#endif\n#define B 3\n"
+	var files [][]byte
+	for _, predefined := range [][]string{nil, {"_WIN32=1"}} {
+		pp := preprocessor.New(lexer.NewLexer("test.h", input))
+		for _, def := range predefined {
+			if err := pp.Predefine(def); err != nil {
+				t.Fatal(err)
+			}
+		}
+		config := &Config{Package: "test", Source: "test.h"}
+		buf := new(bytes.Buffer)
+		if err := config.Generate(buf, parser.NewParserFromLexer("test.h", pp).Nodes()); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, buf.Bytes())
+	}
+	shared, targets, err := SplitTargets(files, []string{"linux", "windows"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`// Code generated by cgen from test.h. DO NOT EDIT.
+
+//go:build linux || windows
+
+package test
+
+const (
+	A int32 = 1
+	B int32 = 3
+)
+`,
+		"",
+		`// Code generated by cgen from test.h. DO NOT EDIT.
+
+//go:build windows
+
+package test
+
+const (
+	W int32 = 2
+)
+`,
+	}
+	for i, got := range append([][]byte{shared}, targets...) {
+		if string(got) != want[i] {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want[i])
+		}
+	}
+}
+
+// vet writes files to a package in a temporary directory and runs go vet
+// on it, which type-checks the package, through cgo if it imports "C".
+func vet(t *testing.T, files map[string][]byte) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	dir := t.TempDir()
+	files["go.mod"] = []byte("module test\n\ngo 1.21\n")
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), src, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(goTool, "vet", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GO111MODULE=on", "GOFLAGS=", "CGO_ENABLED=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go vet: %s\n%s", err, out)
+	}
+}
+
+func TestSplitTargets_Cgo(t *testing.T) {
+	input := "#include <stddef.h>\nint dev_open(const char *path);\nint dev_write(int fd, const void *buf, size_t len);\nlong dev_seek(int fd, long off);\n"
+	var files [][]byte
+	for _, sizes := range []types.Sizes{types.LP64, types.ILP32} {
+		config := &Config{Package: "dev", Source: "dev.h", Sizes: sizes, Cgo: Conventions{TrimPrefix: "dev_", Errors: NegativeErrors}}
+		buf := new(bytes.Buffer)
+		if err := config.GenerateCgo(buf, parser.NewParser("dev.h", input).Nodes()); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, buf.Bytes())
+	}
+	shared, targets, err := SplitTargets(files, []string{"linux && amd64", "linux && 386"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pkg := map[string][]byte{"dev.h": []byte(input), "dev.go": shared}
+	for i, port := range []string{"linux_amd64", "linux_386"} {
+		if targets[i] != nil {
+			pkg["dev_"+port+".go"] = targets[i]
+		}
+	}
+	vet(t, pkg)
+}
@@ -0,0 +1,621 @@
+package gen
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/constant"
+	"github.com/SHyx0rmZ/cgen/token"
+	"github.com/SHyx0rmZ/cgen/types"
+)
+
+// A cType describes the layout of a C type on the target, and the Go
+// type with the same layout.
+type cType struct {
+	size, align int64
+	goType      string
+	goAlign     int64 // alignment of goType, at most align
+	integer     bool  // whether the type can hold a bit-field
+	signed      bool
+}
+
+// basicLayouts contains the layouts of the builtin C types on LP64,
+// where plain char is signed. Go has no type matching long double, so
+// it is represented by its bytes.
+var basicLayouts = map[string]cType{
+	"_Bool":                {1, 1, "bool", 1, true, false},
+	"char":                 {1, 1, "int8", 1, true, true},
+	"signed char":          {1, 1, "int8", 1, true, true},
+	"unsigned char":        {1, 1, "uint8", 1, true, false},
+	"short":                {2, 2, "int16", 2, true, true},
+	"unsigned short":       {2, 2, "uint16", 2, true, false},
+	"int":                  {4, 4, "int32", 4, true, true},
+	"unsigned int":         {4, 4, "uint32", 4, true, false},
+	"long":                 {8, 8, "int64", 8, true, true},
+	"unsigned long":        {8, 8, "uint64", 8, true, false},
+	"long long":            {8, 8, "int64", 8, true, true},
+	"unsigned long long":   {8, 8, "uint64", 8, true, false},
+	"float":                {4, 4, "float32", 4, false, false},
+	"double":               {8, 8, "float64", 8, false, false},
+	"long double":          {16, 16, "[16]byte", 1, false, false},
+	"float _Complex":       {8, 4, "complex64", 4, false, false},
+	"double _Complex":      {16, 8, "complex128", 8, false, false},
+	"long double _Complex": {32, 16, "[32]byte", 1, false, false},
+}
+
+// A structField is a field of a generated struct type.
+type structField struct {
+	name    string // Go name; "_" for padding
+	typ     cType
+	offset  int64
+	doc     *ast.CommentGroup
+	comment *ast.CommentGroup
+}
+
+// A bitField is a bit-field of a struct or union, accessed through the
+// storage unit of its declared type.
+type bitField struct {
+	name   string // Go name
+	typ    cType  // declared type
+	offset int64  // offset of the storage unit
+	shift  int64
+	width  int64
+}
+
+// A structLayout is the layout of a struct or union type.
+type structLayout struct {
+	name        string      // Go name
+	kind        token.Token // token.STRUCT or token.UNION
+	size, align int64
+	fields      []structField // fields in the order of their offsets, or union members
+	bits        []bitField
+	flex        *structField // flexible array member; or nil
+}
+
+// exported returns the exported Go name of the C identifier name, as
+// cgo -godefs does for the fields of structs.
+func exported(name string) string {
+	r, n := utf8.DecodeRuneInString(name)
+	return string(unicode.ToUpper(r)) + name[n:]
+}
+
+// goName returns the C identifier name as a Go identifier.
+func goName(name string) string {
+	switch name {
+	case "break", "case", "chan", "const", "continue", "default", "defer", "else",
+		"fallthrough", "for", "func", "go", "goto", "if", "import", "interface",
+		"map", "package", "range", "return", "select", "struct", "switch", "type", "var":
+		return name + "_"
+	}
+	return name
+}
+
+// name returns the Go name of the C declaration name, following the
+// rules of g.
+func (g *generator) name(name string) string {
+	return goName(g.rules.Name(name))
+}
+
+// alignType returns an unsigned Go integer type aligned to a.
+func alignType(a int64) string {
+	if a > 8 {
+		a = 8
+	}
+	return fmt.Sprintf("uint%d", 8*a)
+}
+
+// structName returns the Go name of the struct, union or enum tag.
+func (g *generator) structName(tag string) string {
+	if name, ok := g.tagNames[tag]; ok {
+		return name
+	}
+	return g.name(tag)
+}
+
+// typedefType reports whether the typedef name is generated as a Go type
+// of its own. Structs and unions are generated under the name of their
+// typedef, enumerations as constants only, and excluded typedefs not at
+// all.
+func (g *generator) typedefType(name string) bool {
+	if !g.rules.Keep(name) {
+		return false
+	}
+	switch g.typedefs[name].(type) {
+	case nil, *ast.StructType, *ast.EnumDecl, *ast.FuncType:
+		return false
+	}
+	_, ok := g.rules.Type(name)
+	return !ok
+}
+
+// override returns t with the Go type the rules give the C type name,
+// if any. The layout of the C type is kept.
+func (g *generator) override(name string, t cType) cType {
+	if typ, ok := g.rules.Type(name); ok {
+		t.goType = typ
+	}
+	return t
+}
+
+// basic returns the layout of the builtin type name on the target.
+func (g *generator) basic(name string) (cType, bool) {
+	t, ok := basicType(g.sizes, g.eval.Model, name)
+	t.goAlign = min(t.goAlign, t.align, g.goMaxAlign)
+	return t, ok
+}
+
+// basicType returns the layout of the builtin type name with sizes and
+// model. The Go types of integers and of long double follow their size,
+// and that of plain char its sign.
+func basicType(sizes types.Sizes, model constant.Model, name string) (cType, bool) {
+	t, ok := basicLayouts[name]
+	if !ok {
+		return cType{}, false
+	}
+	if name == "char" {
+		t.signed = !model.CharUnsigned
+	}
+	b := &types.Basic{Name: name}
+	t.size, t.align = sizes.Sizeof(b), sizes.Alignof(b)
+	switch {
+	case t.integer && t.signed:
+		t.goType = fmt.Sprintf("int%d", 8*t.size)
+	case t.integer && t.goType != "bool":
+		t.goType = fmt.Sprintf("uint%d", 8*t.size)
+	case strings.HasSuffix(t.goType, "byte"):
+		t.goType = fmt.Sprintf("[%d]byte", t.size)
+	}
+	t.goAlign = min(t.goAlign, t.align)
+	return t, true
+}
+
+// typeOf returns the layout of the C type x. The name aux is used for
+// the type generated for a struct or union without a tag.
+func (g *generator) typeOf(x ast.Expr, aux string) (cType, error) {
+	switch x := x.(type) {
+	case *ast.BasicType:
+		if t, ok := g.basic(x.Name); ok {
+			return g.override(x.Name, t), nil
+		}
+		return cType{}, fmt.Errorf("%s has no size", x.Name)
+	case *ast.Ident:
+		if u, ok := g.typedefOf(x); ok {
+			t, err := g.typeOf(u, g.name(x.Name))
+			if e, ok := u.(*ast.EnumDecl); ok && e.Name == nil && e.Specs != nil || err == nil && g.typedefType(x.Name) {
+				t.goType = g.name(x.Name)
+			}
+			return g.override(x.Name, t), err
+		}
+		// the typedefs of the standard headers depend on the target
+		u, err := g.check.TypeOf(x)
+		if err != nil {
+			return cType{}, err
+		}
+		if b, ok := types.Underlying(u).(*types.Basic); ok {
+			t, _ := g.basic(b.Name)
+			return g.override(x.Name, t), nil
+		}
+		return cType{}, fmt.Errorf("undefined: %s", x.Name)
+	case *ast.QualType:
+		return g.typeOf(x.Type, aux)
+	case *ast.EnumDecl:
+		t, _ := g.basic("int")
+		if x.Name != nil && g.enumTags[x.Name.Name] {
+			t.goType = g.structName(x.Name.Name)
+		}
+		return t, nil
+	case *ast.PointerType:
+		size := g.sizes.Sizeof(&types.Pointer{})
+		return cType{size: size, align: size, goType: g.pointerType(x.Elem), goAlign: size}, nil
+	case *ast.ArrayType:
+		elem, err := g.typeOf(x.Elem, aux)
+		if err != nil {
+			return cType{}, err
+		}
+		if x.Len == nil {
+			return cType{}, fmt.Errorf("array of unknown size")
+		}
+		v, err := g.eval.Eval(x.Len)
+		if err != nil {
+			return cType{}, err
+		}
+		n, ok := v.Int64()
+		if !ok || n < 0 {
+			return cType{}, fmt.Errorf("invalid array length %s", v)
+		}
+		return cType{
+			size:    n * elem.size,
+			align:   elem.align,
+			goType:  fmt.Sprintf("[%d]%s", n, elem.goType),
+			goAlign: elem.goAlign,
+		}, nil
+	case *ast.StructType:
+		s, err := g.structType(x, aux)
+		if err != nil {
+			return cType{}, err
+		}
+		if g.excludedStruct(x) {
+			// the Go type isn't generated, so only the bytes are kept
+			return cType{size: s.size, align: s.align, goType: fmt.Sprintf("[%d]byte", s.size), goAlign: 1}, nil
+		}
+		return cType{size: s.size, align: s.align, goType: s.name, goAlign: min(s.align, g.goMaxAlign)}, nil
+	}
+	return cType{}, fmt.Errorf("%T has no size", x)
+}
+
+// pointerType returns the Go type of a pointer to x. Pointers to types
+// without a Go representation become unsafe.Pointer, function pointers
+// uintptr.
+func (g *generator) pointerType(x ast.Expr) string {
+	for {
+		q, ok := x.(*ast.QualType)
+		if !ok {
+			break
+		}
+		x = q.Type
+	}
+	switch y := x.(type) {
+	case *ast.FuncType:
+		return "uintptr"
+	case *ast.StructType:
+		// The layout of the struct may depend on this pointer.
+		if y.Name != nil && (y.Fields != nil || g.tagOf(y) != nil) && !g.excludedStruct(y) {
+			return "*" + g.structName(y.Name.Name)
+		}
+		g.imports["unsafe"] = true
+		return "unsafe.Pointer"
+	case *ast.Ident:
+		u, _ := g.typedefOf(y)
+		if u, ok := u.(*ast.StructType); ok {
+			if u.Name == nil && u.Fields != nil && !g.excludedStruct(u) {
+				return "*" + g.name(y.Name)
+			}
+			return g.pointerType(u)
+		}
+	}
+	if t, err := g.typeOf(x, ""); err == nil {
+		return "*" + t.goType
+	}
+	g.imports["unsafe"] = true
+	return "unsafe.Pointer"
+}
+
+// typedefOf returns the type of the typedef name x. An identifier linked
+// to its declaration by the parser refers to that typedef, even if its
+// name is declared again.
+func (g *generator) typedefOf(x *ast.Ident) (ast.Expr, bool) {
+	if x.Obj != nil && x.Obj.Kind == ast.Typ {
+		if d, ok := x.Obj.Decl.(*ast.TypeDecl); ok {
+			return d.Type, true
+		}
+	}
+	u, ok := g.typedefs[x.Name]
+	return u, ok
+}
+
+// excludedStruct reports whether the rules exclude the struct or union
+// type x, which may be a reference to a tag defined elsewhere.
+func (g *generator) excludedStruct(x *ast.StructType) bool {
+	if x.Name != nil && x.Fields == nil {
+		x = g.tagOf(x)
+	}
+	return g.excluded[x]
+}
+
+// tagOf returns the definition of the tag of the struct or union type x,
+// or nil if it is not defined.
+func (g *generator) tagOf(x *ast.StructType) *ast.StructType {
+	if x.Name.Obj != nil {
+		if st, ok := x.Name.Obj.Decl.(*ast.StructType); ok && st.Fields != nil {
+			return st
+		}
+	}
+	return g.tags[x.Name.Name]
+}
+
+// structType returns the layout of the struct or union type x, which may
+// be a reference to a tag defined elsewhere.
+func (g *generator) structType(x *ast.StructType, aux string) (*structLayout, error) {
+	name := aux
+	if x.Name != nil {
+		name = g.structName(x.Name.Name)
+		if x.Fields == nil {
+			x = g.tagOf(x)
+			if x == nil {
+				return nil, fmt.Errorf("incomplete type %s", name)
+			}
+		}
+	}
+	if name == "" {
+		return nil, fmt.Errorf("anonymous %s", x.Kind)
+	}
+	if s, ok := g.layouts[x]; ok {
+		if s == nil {
+			return nil, fmt.Errorf("%s contains itself", name)
+		}
+		return s, nil
+	}
+	g.layouts[x] = nil
+	s, err := g.layout(name, x)
+	if err != nil {
+		delete(g.layouts, x)
+		return nil, err
+	}
+	g.layouts[x] = s
+	return s, nil
+}
+
+// layout computes the layout of the struct or union type x on the
+// target, using package types. A bit-field is accessed through a storage
+// unit of its declared type, aligned to the type if the bit-field fits,
+// or else starting at the byte of its first bit.
+func (g *generator) layout(name string, x *ast.StructType) (*structLayout, error) {
+	t, err := g.check.TypeOf(x)
+	if err != nil {
+		return nil, err
+	}
+	st := t.(*types.Struct)
+	offsets := g.sizes.Offsetsof(st)
+	s := &structLayout{name: name, kind: x.Kind, size: g.sizes.Sizeof(st), align: g.sizes.Alignof(st)}
+	for i, f := range x.Fields.List {
+		fname := fieldName(f, i)
+		if arr, ok := f.Type.(*ast.ArrayType); ok && arr.Len == nil {
+			elem, err := g.typeOf(arr.Elem, name+"_"+fname)
+			if err != nil {
+				return nil, err
+			}
+			s.flex = &structField{name: fname, typ: elem, offset: offsets[i] / 8, doc: f.Doc, comment: f.Comment}
+			continue
+		}
+
+		t, err := g.typeOf(f.Type, name+"_"+fname)
+		if err != nil {
+			return nil, err
+		}
+		if b := st.Fields[i]; b.BitField {
+			if f.Name == nil || b.Bits == 0 {
+				continue
+			}
+			offset := offsets[i] / (8 * t.align) * t.align
+			if offsets[i]-8*offset+b.Bits > 8*t.size {
+				offset = offsets[i] / 8
+			}
+			if offsets[i]-8*offset+b.Bits > 8*t.size {
+				return nil, fmt.Errorf("bit-field %s crosses its storage unit", fname)
+			}
+			s.bits = append(s.bits, bitField{
+				name:   fname,
+				typ:    t,
+				offset: offset,
+				shift:  offsets[i] - 8*offset,
+				width:  b.Bits,
+			})
+			continue
+		}
+		s.fields = append(s.fields, structField{name: fname, typ: t, offset: offsets[i] / 8, doc: f.Doc, comment: f.Comment})
+	}
+	return s, nil
+}
+
+// fieldName returns the Go name of the i'th member f of a struct.
+func fieldName(f *ast.Field, i int) string {
+	if f.Name == nil {
+		return fmt.Sprintf("Anon%d", i)
+	}
+	return exported(f.Name.Name)
+}
+
+// padding returns a padding field of n bytes.
+func padding(n int64) structField {
+	return structField{name: "_", typ: cType{size: n, align: 1, goType: fmt.Sprintf("[%d]byte", n), goAlign: 1}}
+}
+
+// goFields returns the fields of the Go struct type for s, with padding
+// for the bytes not covered by a field, such as bit-fields. Fields that
+// Go would align differently than the C type, as in packed structs, are
+// represented by their bytes. If the Go fields are aligned less strictly
+// than the C type, a leading zero-size field adds the alignment, up to
+// maxAlign, the largest alignment of a Go type.
+func goFields(s *structLayout, maxAlign int64) []structField {
+	var fields []structField
+	if s.kind == token.UNION {
+		fields = append(fields, padding(s.size))
+	} else {
+		var offset int64
+		for _, f := range s.fields {
+			if f.offset > offset {
+				fields = append(fields, padding(f.offset-offset))
+			}
+			if f.offset%f.typ.goAlign != 0 || s.size%f.typ.goAlign != 0 {
+				f.typ = padding(f.typ.size).typ
+			}
+			fields = append(fields, f)
+			offset = f.offset + f.typ.size
+		}
+		if s.size > offset {
+			fields = append(fields, padding(s.size-offset))
+		}
+	}
+
+	goAlign := int64(1)
+	for _, f := range fields {
+		goAlign = max(goAlign, f.typ.goAlign)
+	}
+	if goAlign < min(s.align, maxAlign) {
+		fields = append([]structField{{name: "_", typ: cType{goType: "[0]" + alignType(s.align)}}}, fields...)
+	}
+	return fields
+}
+
+// structDecl returns the struct or union type defined by node, if any,
+// and the Go name of the type.
+func (g *generator) structDecl(node ast.Node) (*ast.StructType, string) {
+	var x ast.Expr
+	name := ""
+	switch n := node.(type) {
+	case *ast.StructDecl:
+		x = n.Type
+	case *ast.VarDecl:
+		x = n.Type
+	case *ast.TypeDecl:
+		x, name = n.Type, g.name(n.Name.Name)
+	}
+	st, ok := x.(*ast.StructType)
+	if !ok || st.Fields == nil {
+		return nil, ""
+	}
+	if st.Name != nil {
+		name = g.structName(st.Name.Name)
+	}
+	return st, name
+}
+
+// structs prints the Go type for the struct or union type x and for the
+// types without a tag it contains.
+func (g *generator) structs(x *ast.StructType, name string) {
+	s, err := g.structType(x, name)
+	if err != nil || g.printed[s.name] {
+		return
+	}
+	for i, f := range x.Fields.List {
+		if t := fieldStruct(f.Type); t != nil && t.Fields != nil {
+			g.structs(t, s.name+"_"+fieldName(f, i))
+		}
+	}
+	g.printed[s.name] = true
+	g.structLayouts = append(g.structLayouts, s)
+
+	// the doc comment names the C type, unless it is anonymous
+	tag := s.name
+	if x.Name != nil {
+		tag = x.Name.Name
+	}
+	g.printf("\n// %s %s\n", s.kind, tag)
+	g.printf("type %s struct {\n", s.name)
+	for _, f := range goFields(s, g.goMaxAlign) {
+		g.spec(constSpec{name: f.name, typ: f.typ.goType, doc: f.doc, comment: f.comment})
+	}
+	g.printf("}\n")
+
+	if s.kind == token.UNION {
+		for _, f := range s.fields {
+			g.imports["unsafe"] = true
+			g.printf("\n// %s returns a pointer to the member %s of u.\n", f.name, f.name)
+			g.printf("func (u *%s) %s() *%s { return (*%s)(unsafe.Pointer(u)) }\n", s.name, f.name, f.typ.goType, f.typ.goType)
+		}
+	}
+	if s.flex != nil {
+		f := s.flex
+		g.imports["unsafe"] = true
+		g.printf("\n// %s returns a pointer to the first element of the flexible array\n// member %s of s.\n", f.name, f.name)
+		g.printf("func (s *%s) %s() *%s { return (*%s)(%s) }\n", s.name, f.name, f.typ.goType, f.typ.goType, offsetPtr("s", f.offset))
+	}
+	for _, b := range s.bits {
+		g.bitField(s, b)
+	}
+}
+
+// fieldStruct returns the struct or union type of a member, or of the
+// elements of an array member.
+func fieldStruct(x ast.Expr) *ast.StructType {
+	for {
+		switch y := x.(type) {
+		case *ast.StructType:
+			return y
+		case *ast.ArrayType:
+			x = y.Elem
+		case *ast.QualType:
+			x = y.Type
+		default:
+			return nil
+		}
+	}
+}
+
+// offsetPtr returns an expression for an unsafe.Pointer to the byte at
+// offset in the struct pointed to by recv.
+func offsetPtr(recv string, offset int64) string {
+	if offset == 0 {
+		return fmt.Sprintf("unsafe.Pointer(%s)", recv)
+	}
+	return fmt.Sprintf("unsafe.Pointer(uintptr(unsafe.Pointer(%s)) + %d)", recv, offset)
+}
+
+// bitField prints the getter and setter methods of a bit-field. The
+// storage unit is read and written as an unsigned integer in the byte
+// order of the target, which is little endian on amd64 and arm64.
+func (g *generator) bitField(s *structLayout, b bitField) {
+	g.imports["unsafe"] = true
+	unit := fmt.Sprintf("uint%d", 8*b.typ.size)
+	ptr := fmt.Sprintf("(*%s)(%s)", unit, offsetPtr("s", b.offset))
+	mask := fmt.Sprintf("%#x", uint64(1)<<uint(b.width)-1)
+	shift := func(op string, n int64) string {
+		if n == 0 {
+			return ""
+		}
+		return fmt.Sprintf(" %s %d", op, n)
+	}
+
+	g.printf("\n// %s returns the bit-field %s of s.\n", b.name, b.name)
+	g.printf("func (s *%s) %s() %s {\n", s.name, b.name, b.typ.goType)
+	switch {
+	case b.typ.goType == "bool":
+		g.printf("return *%s%s&1 != 0\n", ptr, shift(">>", b.shift))
+	case b.typ.signed:
+		bits := 8 * b.typ.size
+		g.printf("return %s(*%s%s)%s\n", b.typ.goType, ptr, shift("<<", bits-b.shift-b.width), shift(">>", bits-b.width))
+	default:
+		g.printf("return *%s%s & %s\n", ptr, shift(">>", b.shift), mask)
+	}
+	g.printf("}\n")
+
+	g.printf("\n// Set%s sets the bit-field %s of s to v.\n", b.name, b.name)
+	g.printf("func (s *%s) Set%s(v %s) {\n", s.name, b.name, b.typ.goType)
+	value := "v"
+	switch b.typ.goType {
+	case unit:
+	case "bool":
+		g.printf("var u %s\nif v {\nu = 1\n}\n", unit)
+		value = "u"
+	default:
+		value = fmt.Sprintf("%s(v)", unit)
+	}
+	clear, set := mask, value+"&"+mask
+	if b.shift > 0 {
+		clear = fmt.Sprintf("(%s << %d)", mask, b.shift)
+		set = fmt.Sprintf("(%s)<<%d", set, b.shift)
+	}
+	g.printf("p := %s\n", ptr)
+	g.printf("*p = *p&^%s | %s\n", clear, set)
+	g.printf("}\n")
+}
+
+// typedef prints a typedef of a type other than a struct, union or
+// enumeration as a defined Go type, and a typedef of a struct or union
+// tag under a different name as an alias.
+func (g *generator) typedef(d *ast.TypeDecl) {
+	name := g.name(d.Name.Name)
+	if st, ok := d.Type.(*ast.StructType); ok {
+		if st.Name == nil || st.Fields != nil {
+			return
+		}
+		s, err := g.structType(st, "")
+		if err != nil || s.name == name {
+			return
+		}
+		g.printf("\ntype %s = %s\n", name, s.name)
+		return
+	}
+	if !g.typedefType(d.Name.Name) || g.printed[name] {
+		return
+	}
+	t, err := g.typeOf(d.Type, name)
+	if err != nil {
+		return
+	}
+	g.printed[name] = true
+	g.printf("\ntype %s %s\n", name, t.goType)
+}
@@ -0,0 +1,241 @@
+package gen
+
+import (
+	"bytes"
+	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"strings"
+)
+
+// SplitTargets splits the Go source files generated for several targets
+// into a file with the declarations that are the same for all targets,
+// and a file for each target with the declarations that differ. The
+// constants, variables and types of a grouped declaration are compared
+// one by one. The files must have been generated by the same Config,
+// apart from the target, and constraints[i] is the build constraint of
+// the target of files[i], like "linux && amd64".
+//
+// The shared file is constrained to the targets; each target file gets
+// its constraint. Only the imports the declarations of a file use are
+// kept. A file without declarations is nil.
+func SplitTargets(files [][]byte, constraints []string) (shared []byte, targets [][]byte, err error) {
+	if len(files) == 0 || len(files) != len(constraints) {
+		return nil, nil, fmt.Errorf("cgen: %d files for %d targets", len(files), len(constraints))
+	}
+	parsed := make([]*splitFile, len(files))
+	count := make(map[string]int)
+	for i, src := range files {
+		f, err := parseSplitFile(src)
+		if err != nil {
+			return nil, nil, err
+		}
+		parsed[i] = f
+		seen := make(map[string]bool)
+		for _, d := range f.decls {
+			for _, spec := range d.specs {
+				if !seen[spec] {
+					seen[spec] = true
+					count[spec]++
+				}
+			}
+		}
+	}
+	isShared := func(spec string) bool { return count[spec] == len(files) }
+
+	all := make([]string, len(constraints))
+	for i, c := range constraints {
+		all[i] = "(" + c + ")"
+	}
+	if len(constraints) == 1 {
+		all[0] = constraints[0]
+	}
+	if shared, err = parsed[0].build(strings.Join(all, " || "), isShared); err != nil {
+		return nil, nil, err
+	}
+
+	targets = make([][]byte, len(files))
+	for i, f := range parsed {
+		differs := func(spec string) bool { return !isShared(spec) }
+		if targets[i], err = f.build(constraints[i], differs); err != nil {
+			return nil, nil, err
+		}
+	}
+	return shared, targets, nil
+}
+
+// A splitFile is a generated Go source file split into its parts.
+type splitFile struct {
+	header  string       // file comment and package clause
+	cgo     string       // source of the import "C" declaration and its preamble; or ""
+	imports []string     // import paths, except "C"
+	decls   []*splitDecl // declarations
+}
+
+// A splitDecl is a declaration of a splitFile. The specs of a grouped
+// const, var or type declaration are compared one by one, so the specs
+// shared by all targets are split off the others. Declarations whose
+// specs depend on their order, like an iota block, are a single spec.
+type splitDecl struct {
+	doc   string   // source of the doc comment and the keyword of a grouped declaration
+	specs []string // source of the specs, including their comments
+}
+
+// parseSplitFile splits the generated Go source file src.
+func parseSplitFile(src []byte) (*splitFile, error) {
+	fset := gotoken.NewFileSet()
+	f, err := goparser.ParseFile(fset, "", src, goparser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("cgen: parsing generated code: %s", err)
+	}
+	offset := func(pos gotoken.Pos) int { return fset.Position(pos).Offset }
+	source := func(doc *goast.CommentGroup, node goast.Node, comment *goast.CommentGroup) string {
+		start, end := node.Pos(), node.End()
+		if doc != nil {
+			start = doc.Pos()
+		}
+		if comment != nil {
+			end = comment.End()
+		}
+		return string(src[offset(start):offset(end)])
+	}
+
+	s := &splitFile{header: string(src[:offset(f.Name.End())])}
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *goast.GenDecl:
+			if d.Tok == gotoken.IMPORT {
+				for _, spec := range d.Specs {
+					path := strings.Trim(spec.(*goast.ImportSpec).Path.Value, `"`)
+					switch {
+					case path != "C":
+						s.imports = append(s.imports, path)
+					case len(d.Specs) == 1:
+						// the preamble is the doc comment of the declaration
+						s.cgo = source(d.Doc, d, nil)
+					default:
+						s.cgo = `import "C"`
+					}
+				}
+				continue
+			}
+			if !d.Lparen.IsValid() || ordered(d) {
+				s.decls = append(s.decls, &splitDecl{specs: []string{source(d.Doc, d, nil)}})
+				continue
+			}
+			start := d.Pos()
+			if d.Doc != nil {
+				start = d.Doc.Pos()
+			}
+			sd := &splitDecl{doc: string(src[offset(start) : offset(d.Lparen)+1])}
+			for _, spec := range d.Specs {
+				switch spec := spec.(type) {
+				case *goast.ValueSpec:
+					sd.specs = append(sd.specs, source(spec.Doc, spec, spec.Comment))
+				case *goast.TypeSpec:
+					sd.specs = append(sd.specs, source(spec.Doc, spec, spec.Comment))
+				}
+			}
+			s.decls = append(s.decls, sd)
+		case *goast.FuncDecl:
+			s.decls = append(s.decls, &splitDecl{specs: []string{source(d.Doc, d, nil)}})
+		}
+	}
+	return s, nil
+}
+
+// ordered reports whether the meaning of the specs of the const
+// declaration d depends on their order, because they use iota or repeat
+// the values of the spec before them.
+func ordered(d *goast.GenDecl) bool {
+	if d.Tok != gotoken.CONST {
+		return false
+	}
+	for _, spec := range d.Specs {
+		v := spec.(*goast.ValueSpec)
+		if len(v.Values) == 0 {
+			return true
+		}
+		iota := false
+		for _, x := range v.Values {
+			goast.Inspect(x, func(n goast.Node) bool {
+				if id, ok := n.(*goast.Ident); ok && id.Name == "iota" {
+					iota = true
+				}
+				return !iota
+			})
+		}
+		if iota {
+			return true
+		}
+	}
+	return false
+}
+
+// build returns the source of a file with the header of f, the build
+// constraint and the specs of f for which keep reports true, or nil if
+// there are none. The specs kept of a grouped declaration stay grouped,
+// and the import "C" declaration keeps its preamble.
+func (f *splitFile) build(constraint string, keep func(spec string) bool) ([]byte, error) {
+	var decls []string
+	for _, d := range f.decls {
+		var specs []string
+		for _, spec := range d.specs {
+			if keep(spec) {
+				specs = append(specs, spec)
+			}
+		}
+		switch {
+		case len(specs) == 0:
+		case d.doc == "":
+			decls = append(decls, specs...)
+		default:
+			decls = append(decls, d.doc+"\n"+strings.Join(specs, "\n")+"\n)")
+		}
+	}
+	if len(decls) == 0 {
+		return nil, nil
+	}
+	// The constraint follows the file comment, if any.
+	buf := new(bytes.Buffer)
+	comment, pkg := "", f.header
+	if i := strings.LastIndex(f.header, "\npackage "); i >= 0 {
+		comment, pkg = f.header[:i+1], f.header[i+1:]
+	}
+	fmt.Fprintf(buf, "%s\n//go:build %s\n\n%s\n", comment, constraint, pkg)
+	if f.cgo != "" {
+		for _, d := range decls {
+			if strings.Contains(d, "C.") {
+				fmt.Fprintf(buf, "\n%s\n", f.cgo)
+				break
+			}
+		}
+	}
+
+	var imports []string
+	for _, path := range f.imports {
+		name := path[strings.LastIndex(path, "/")+1:]
+		for _, d := range decls {
+			if strings.Contains(d, name+".") {
+				imports = append(imports, fmt.Sprintf("%q", path))
+				break
+			}
+		}
+	}
+	switch len(imports) {
+	case 0:
+	case 1:
+		fmt.Fprintf(buf, "\nimport %s\n", imports[0])
+	default:
+		fmt.Fprintf(buf, "\nimport (\n%s\n)\n", strings.Join(imports, "\n"))
+	}
+	for _, d := range decls {
+		fmt.Fprintf(buf, "\n%s\n", d)
+	}
+	out := new(bytes.Buffer)
+	if err := format(out, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -6,10 +6,9 @@ import (
 )
 
 type Item struct {
-	Pos  token.Pos
-	Val  string
-	Tok  token.Token
-	Line int
+	Pos token.Pos
+	Val string
+	Tok token.Token
 }
 
 func (i Item) String() string {
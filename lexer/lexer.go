@@ -12,31 +12,53 @@ const eof = -1
 
 type stateFn func(*lexer) stateFn
 
+// A Lexer produces the items of some input. Once the input is exhausted,
+// NextItem keeps returning EOF items.
 type Lexer interface {
 	NextItem() Item
 }
 
 type lexer struct {
 	name    string
+	base    token.Pos // position of the first byte of input
 	input   string
 	state   stateFn
 	pos     token.Pos
 	start   token.Pos
 	width   token.Pos
 	lastPos token.Pos
-	items   chan Item
-	line    int
+	midLine bool        // whether a token was emitted on the current line
+	items   []Item      // items emitted, but not yet returned by NextItem
+	file    *token.File // line table, filled while lexing
 }
 
+// NewLexer returns a lexer for input. The positions of its items are
+// byte offsets into input.
 func NewLexer(name, input string) *lexer {
-	l := &lexer{
-		name:  name,
+	return NewFileLexer(token.NewFile(name, len(input)), input)
+}
+
+// NewFileLexer returns a lexer for the contents of file. The positions of
+// its items are relative to the base of file, so that they can be mapped
+// to a token.Position by the file set containing file. The lexer adds
+// the lines of input to file as it reads them.
+//
+// The lexer runs on demand within NextItem, so it holds no resources
+// and may be abandoned at any time. See NewStream for a lexer running
+// concurrently with its consumer.
+func NewFileLexer(file *token.File, input string) *lexer {
+	return &lexer{
+		name:  file.Name(),
+		base:  token.Pos(file.Base()),
 		input: input,
-		items: make(chan Item),
-		line:  1,
+		state: lexLineStart,
+		file:  file,
 	}
-	go l.run()
-	return l
+}
+
+// File returns the file the positions of the items of l belong to.
+func (l *lexer) File() *token.File {
+	return l.file
 }
 
 func (l *lexer) next() rune {
@@ -48,7 +70,7 @@ func (l *lexer) next() rune {
 	l.width = token.Pos(w)
 	l.pos += l.width
 	if r == '\n' {
-		l.line++
+		l.file.AddLine(int(l.pos))
 	}
 	return r
 }
@@ -61,16 +83,29 @@ func (l *lexer) peek() rune {
 
 func (l *lexer) backup() {
 	l.pos -= l.width
-	if l.width == 1 && l.input[l.pos] == '\n' {
-		l.line--
-	}
 }
 
 func (l *lexer) emit(t token.Token) {
-	l.items <- Item{l.start, l.input[l.start:l.pos], t, l.line}
+	l.items = append(l.items, Item{l.base + l.start, l.input[l.start:l.pos], t})
+	switch {
+	case t == token.WHITESPACE:
+		if strings.Contains(l.input[l.start:l.pos], "\n") {
+			l.midLine = false
+		}
+	case t != token.COMMENT:
+		l.midLine = true
+	}
 	l.start = l.pos
 }
 
+// emitEq emits tok, or tokEq if the operator is followed by '='.
+func (l *lexer) emitEq(tok, tokEq token.Token) {
+	if l.accept("=") {
+		tok = tokEq
+	}
+	l.emit(tok)
+}
+
 func (l *lexer) ignore() {
 	l.start = l.pos
 }
@@ -89,57 +124,102 @@ func (l *lexer) acceptRun(valid string) {
 	l.backup()
 }
 
+// errorf emits an ILLEGAL item for the input scanned so far and
+// continues lexing after it.
 func (l *lexer) errorf(format string, args ...interface{}) stateFn {
-	l.items <- Item{l.start, fmt.Sprintf(format, args...), token.ILLEGAL, l.line}
-	return nil
+	l.items = append(l.items, Item{l.base + l.start, fmt.Sprintf(format, args...), token.ILLEGAL})
+	l.midLine = true
+	l.ignore()
+	return lexLineStart
 }
 
+// NextItem returns the next item of the input, running the state
+// functions until they emit one.
 func (l *lexer) NextItem() Item {
-	item := <-l.items
+	for len(l.items) == 0 {
+		if l.state == nil {
+			return Item{l.base + token.Pos(len(l.input)), "", token.EOF}
+		}
+		l.state = l.state(l)
+	}
+	item := l.items[0]
+	if len(l.items) == 1 {
+		// reuse the backing array, usually only one item is pending
+		l.items = l.items[:0]
+	} else {
+		l.items = l.items[1:]
+	}
 	l.lastPos = item.Pos
 	return item
 }
 
-func (l *lexer) drain() {
-	for range l.items {
-	}
+// directives maps the names of directives to their tokens.
+var directives = map[string]token.Token{
+	"define":  token.DEFINE,
+	"elif":    token.ELIF,
+	"else":    token.ELSE,
+	"endif":   token.ENDIF,
+	"if":      token.IF,
+	"ifdef":   token.IFDEF,
+	"ifndef":  token.IFNDEF,
+	"include": token.INCLUDE,
+	"pragma":  token.PRAGMA,
+	"undef":   token.UNDEF,
 }
 
-func (l *lexer) run() {
-	for l.state = lexLineStart; l.state != nil; {
-		l.state = l.state(l)
+// directive returns the token of the directive the input continues with
+// and its length, or false. Horizontal whitespace and comments may occur
+// between the # and the name of the directive, as in "# define". The #
+// must be the first token of its line.
+func (l *lexer) directive() (token.Token, int, bool) {
+	rest := l.input[l.pos:]
+	if l.midLine || !strings.HasPrefix(rest, "#") {
+		return 0, 0, false
 	}
-	close(l.items)
+	i := 1
+	for i < len(rest) {
+		if strings.IndexByte(" \t\f\v", rest[i]) >= 0 {
+			i++
+			continue
+		}
+		if !strings.HasPrefix(rest[i:], "/*") {
+			break
+		}
+		// comments spanning lines end the directive line
+		n := strings.Index(rest[i:], "*/")
+		if n < 0 || strings.Contains(rest[i:i+n], "\n") {
+			return 0, 0, false
+		}
+		i += n + len("*/")
+	}
+	n := i
+	for n < len(rest) && strings.IndexByte("_"+groupLower+groupUpper+groupDigits, rest[n]) >= 0 {
+		n++
+	}
+	tok, ok := directives[rest[i:n]]
+	return tok, n, ok
 }
 
 func lexLineStart(l *lexer) stateFn {
+	if tok, n, ok := l.directive(); ok {
+		l.pos += token.Pos(n)
+		l.emit(tok)
+		if tok == token.INCLUDE {
+			return lexInclude
+		}
+		return lexLineStart
+	}
 	switch {
 	case strings.HasPrefix(l.input[l.pos:], "/*"):
 		return lexMultilineComment
-	case l.accept(" \n\t"):
-		l.acceptRun(" \n\t")
+	case strings.HasPrefix(l.input[l.pos:], "//"):
+		return lexLineComment
+	case l.accept(" \n\t\r\f\v"):
+		l.acceptRun(" \n\t\r\f\v")
 		l.emit(token.WHITESPACE)
 		return lexLineStart
-	case strings.HasPrefix(l.input[l.pos:], "#ifndef"):
-		return lexIfNotDefined
-	case strings.HasPrefix(l.input[l.pos:], "#ifdef"):
-		return lexIfDefined
-	case strings.HasPrefix(l.input[l.pos:], "#define"):
-		return lexDefine
-	case strings.HasPrefix(l.input[l.pos:], "#include"):
-		return lexInclude
-	case strings.HasPrefix(l.input[l.pos:], "extern"):
-		return lexExtern
-	case strings.HasPrefix(l.input[l.pos:], "#else"):
-		l.pos += token.Pos(len("#else"))
-		l.emit(token.ELSE)
-		return lexLineStart
-	case strings.HasPrefix(l.input[l.pos:], "#endif"):
-		l.pos += token.Pos(len("#endif"))
-		l.emit(token.ENDIF)
-		return lexLineStart
-	case l.accept(groupDigits):
-		return lexHexValue
+	case strings.ContainsRune(groupDigits, l.peek()) || l.hasFraction():
+		return lexNumber
 	case l.peek() == '{':
 		l.next()
 		l.emit(token.LBRACE)
@@ -152,29 +232,65 @@ func lexLineStart(l *lexer) stateFn {
 		l.next()
 		l.emit(token.LPAREN)
 		return lexLineStart
+	case l.peek() == '[':
+		l.next()
+		l.emit(token.LBRACK)
+		return lexLineStart
+	case l.peek() == ']':
+		l.next()
+		l.emit(token.RBRACK)
+		return lexLineStart
+	case l.peek() == ':':
+		l.next()
+		l.emit(token.COLON)
+		return lexLineStart
 	case l.peek() == ')':
 		l.next()
 		l.emit(token.RPAREN)
 		return lexLineStart
 	case l.peek() == '\\':
 		l.next()
+		// a backslash followed by a newline continues the line
+		if !l.accept("\n") && strings.HasPrefix(l.input[l.pos:], "\r\n") {
+			l.pos += 2
+			l.file.AddLine(int(l.pos))
+		}
 		l.ignore()
 		return lexLineStart
 	case l.peek() == '|':
 		l.next()
-		if l.accept("|") {
+		switch {
+		case l.accept("|"):
 			l.emit(token.LOR)
-			return lexLineStart
+		case l.accept("="):
+			l.emit(token.OR_ASSIGN)
+		default:
+			l.emit(token.OR)
 		}
-		l.emit(token.OR)
 		return lexLineStart
 	case l.peek() == ';':
 		l.next()
 		l.emit(token.SEMICOLON)
 		return lexLineStart
+	case l.peek() == '?':
+		l.next()
+		l.emit(token.QUESTION)
+		return lexLineStart
+	case l.peek() == '~':
+		l.next()
+		l.emit(token.TILDE)
+		return lexLineStart
 	case l.peek() == '*':
 		l.next()
-		l.emit(token.MUL)
+		l.emitEq(token.MUL, token.MUL_ASSIGN)
+		return lexLineStart
+	case l.peek() == '%':
+		l.next()
+		l.emitEq(token.REM, token.REM_ASSIGN)
+		return lexLineStart
+	case l.peek() == '^':
+		l.next()
+		l.emitEq(token.XOR, token.XOR_ASSIGN)
 		return lexLineStart
 	case l.peek() == ',':
 		l.next()
@@ -182,30 +298,91 @@ func lexLineStart(l *lexer) stateFn {
 		return lexLineStart
 	case l.peek() == '=':
 		l.next()
-		l.emit(token.ASSIGN)
+		l.emitEq(token.ASSIGN, token.EQL)
+		return lexLineStart
+	case l.peek() == '!':
+		l.next()
+		l.emitEq(token.NOT, token.NEQ)
+		return lexLineStart
+	case l.peek() == '<':
+		l.next()
+		switch {
+		case l.accept("<"):
+			l.emitEq(token.SHL, token.SHL_ASSIGN)
+		case l.accept("="):
+			l.emit(token.LEQ)
+		default:
+			l.emit(token.LSS)
+		}
+		return lexLineStart
+	case l.peek() == '>':
+		l.next()
+		switch {
+		case l.accept(">"):
+			l.emitEq(token.SHR, token.SHR_ASSIGN)
+		case l.accept("="):
+			l.emit(token.GEQ)
+		default:
+			l.emit(token.GTR)
+		}
 		return lexLineStart
 	case l.peek() == '&':
 		l.next()
-		if l.accept("&") {
+		switch {
+		case l.accept("&"):
 			l.emit(token.LAND)
-			return lexLineStart
+		case l.accept("="):
+			l.emit(token.AND_ASSIGN)
+		default:
+			l.emit(token.AND)
+		}
+		return lexLineStart
+	case l.peek() == '+':
+		l.next()
+		switch {
+		case l.accept("+"):
+			l.emit(token.INC)
+		case l.accept("="):
+			l.emit(token.ADD_ASSIGN)
+		default:
+			l.emit(token.ADD)
 		}
-		l.emit(token.AND)
 		return lexLineStart
 	case l.peek() == '-':
 		l.next()
-		if l.accept("-") {
+		switch {
+		case l.accept("-"):
 			l.emit(token.DEC)
-			return lexLineStart
+		case l.accept("="):
+			l.emit(token.SUB_ASSIGN)
+		case l.accept(">"):
+			l.emit(token.ARROW)
+		default:
+			l.emit(token.SUB)
 		}
-		l.emit(token.SUB)
 		return lexLineStart
 	case l.peek() == '/':
 		l.next()
-		l.emit(token.QUO)
+		l.emitEq(token.QUO, token.QUO_ASSIGN)
+		return lexLineStart
+	case strings.HasPrefix(l.input[l.pos:], "##"):
+		l.pos += token.Pos(len("##"))
+		l.emit(token.HASHHASH)
+		return lexLineStart
+	case l.peek() == '#':
+		l.next()
+		l.emit(token.HASH)
+		return lexLineStart
+	case strings.HasPrefix(l.input[l.pos:], "..."):
+		l.pos += token.Pos(len("..."))
+		l.emit(token.ELLIPSIS)
+		return lexLineStart
+	case l.peek() == '.':
+		l.next()
+		l.emit(token.PERIOD)
 		return lexLineStart
-	case l.peek() == '"':
-		return lexString
+	case l.peek() == '"' || l.peek() == '\'' || l.hasEncodingPrefix():
+		return lexQuote
 	default:
 		if l.accept("_" + groupLower + groupUpper) {
 			return lexIdentifier
@@ -214,7 +391,7 @@ func lexLineStart(l *lexer) stateFn {
 			l.emit(token.EOF)
 			return nil
 		}
-		return l.errorf("unknown: %.30q...", l.input[l.pos:])
+		return l.errorf("unknown character %q", l.next())
 	}
 }
 
@@ -222,42 +399,56 @@ const (
 	groupLower  = "abcdefghijklmnopqrstuvwxyz"
 	groupUpper  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
 	groupDigits = "0123456789"
+
+	groupHexDigits = groupDigits + "abcdefABCDEF"
 )
 
-func lexString(l *lexer) stateFn {
-	l.next()
-	for {
-		n := l.peek()
-		if n == '"' {
-			l.next()
-			l.emit(token.STRING)
-			return lexLineStart
+// hasEncodingPrefix reports whether the input continues with a character
+// constant or string literal with an encoding prefix.
+func (l *lexer) hasEncodingPrefix() bool {
+	for _, prefix := range []string{"u8\"", "u'", "u\"", "U'", "U\"", "L'", "L\""} {
+		if strings.HasPrefix(l.input[l.pos:], prefix) {
+			return true
 		}
-		if n == '\\' {
-			l.next()
-			n = l.peek()
-		}
-		l.next()
 	}
+	return false
 }
 
-func lexExtern(l *lexer) stateFn {
-	l.pos += token.Pos(len("extern"))
-	l.emit(token.EXTERN)
-	l.acceptRun(" ")
-	l.emit(token.WHITESPACE)
-	//if strings.HasPrefix(l.input[l.Pos:], `"C"`) {
-	//	l.Pos += token.Pos(len(`"C"`))
-	//	l.emit(ItemExternC)
-	//	//return lexLineStart
-	//}
-	return lexLineStart
+// lexQuote scans a character constant or a string literal, including its
+// encoding prefix. Escape sequences are validated by the consumers of the
+// literal.
+func lexQuote(l *lexer) stateFn {
+	if !l.accept("L") && l.accept("uU") {
+		l.accept("8")
+	}
+	quote := l.next()
+	tok, what := token.STRING, "string literal"
+	if quote == '\'' {
+		tok, what = token.CHAR, "character constant"
+	}
+	for n := 0; ; n++ {
+		switch l.next() {
+		case quote:
+			if n == 0 && tok == token.CHAR {
+				return l.errorf("empty character constant")
+			}
+			l.emit(tok)
+			return lexLineStart
+		case '\\':
+			if l.next() != eof {
+				break
+			}
+			fallthrough
+		case '\n', eof:
+			l.backup()
+			return l.errorf("unterminated %s", what)
+		}
+	}
 }
 
+// lexInclude scans the path of an #include directive.
 func lexInclude(l *lexer) stateFn {
-	l.pos += token.Pos(len("#include"))
-	l.emit(token.INCLUDE)
-	l.acceptRun(" ")
+	l.acceptRun(" \t")
 	l.emit(token.WHITESPACE)
 	switch l.next() {
 	case '"':
@@ -279,52 +470,152 @@ func lexInclude(l *lexer) stateFn {
 	return l.errorf("expected include path")
 }
 
-func lexHexValue(l *lexer) stateFn {
-	l.acceptRun("x0123456789abcdefABCDEF")
-	l.accept("u")
-	l.emit(token.INT)
+// hasFraction reports whether the input continues with a floating
+// constant starting with a period.
+func (l *lexer) hasFraction() bool {
+	rest := l.input[l.pos:]
+	return len(rest) > 1 && rest[0] == '.' && strings.ContainsRune(groupDigits, rune(rest[1]))
+}
+
+// ppNumber returns the end of the preprocessing number the input
+// continues with (C11 6.4.8): digits, letters, underscores, periods and
+// signs following an exponent letter.
+func (l *lexer) ppNumber() token.Pos {
+	rest := l.input[l.pos:]
+	i := 1
+	for i < len(rest) {
+		switch c := rest[i]; {
+		case strings.IndexByte("eEpP", c) >= 0 && i+1 < len(rest) && strings.IndexByte("+-", rest[i+1]) >= 0:
+			i += 2
+		case strings.IndexByte("._"+groupLower+groupUpper+groupDigits, c) >= 0:
+			i++
+		default:
+			return l.pos + token.Pos(i)
+		}
+	}
+	return l.pos + token.Pos(len(rest))
+}
+
+// lexNumber scans a preprocessing number, which must be an integer or
+// floating constant with an optional suffix. An invalid number is an
+// ILLEGAL item, so it is only an error outside of skipped groups.
+func lexNumber(l *lexer) stateFn {
+	end := l.ppNumber()
+	invalid := func(format string, args ...interface{}) stateFn {
+		l.pos = end
+		return l.errorf(format, args...)
+	}
+	digits, exponent := groupDigits, "eE"
+	octal := false
+	if l.accept("0") {
+		switch {
+		case l.accept("xX"):
+			digits, exponent = groupHexDigits, "pP"
+		case l.accept("bB"):
+			digits, exponent = "01", ""
+		default:
+			octal = true
+		}
+	}
+	start := l.pos
+	l.acceptRun(digits)
+	mantissa := l.pos > start
+
+	float := false
+	if exponent != "" && l.accept(".") {
+		float = true
+		n := l.pos
+		l.acceptRun(digits)
+		mantissa = mantissa || l.pos > n
+	}
+	switch {
+	case digits != groupDigits && !mantissa:
+		return invalid("invalid constant %q", l.input[l.start:l.pos])
+	case exponent != "" && l.accept(exponent):
+		float = true
+		l.accept("+-")
+		if !l.accept(groupDigits) {
+			return invalid("exponent has no digits")
+		}
+		l.acceptRun(groupDigits)
+	case float && exponent == "pP":
+		return invalid("hexadecimal floating constant requires an exponent")
+	}
+
+	if float {
+		l.accept("fFlL")
+	} else {
+		if octal && strings.ContainsAny(l.input[start:l.pos], "89") {
+			return invalid("invalid digit in octal constant %q", l.input[l.start:l.pos])
+		}
+		unsigned := l.accept("uU")
+		if rest := l.input[l.pos:]; strings.HasPrefix(rest, "ll") || strings.HasPrefix(rest, "LL") {
+			l.pos += 2
+		} else {
+			l.accept("lL")
+		}
+		if !unsigned {
+			l.accept("uU")
+		}
+	}
+	if l.pos != end {
+		return invalid("invalid suffix on constant %q", l.input[l.start:end])
+	}
+
+	if float {
+		l.emit(token.FLOAT)
+	} else {
+		l.emit(token.INT)
+	}
 	return lexLineStart
 }
 
 func lexIdentifier(l *lexer) stateFn {
 	//if l.accept("_" + groupLower + groupUpper) {
 	l.acceptRun("_" + groupLower + groupUpper + groupDigits)
-	l.emit(token.IDENT)
+	word := l.input[l.start:l.pos]
+	l.emit(token.Lookup(word))
+	if word == "__has_include" || word == "__has_include_next" {
+		return lexHasInclude
+	}
 	return lexLineStart
 	//}
 	//return l.errorf("expected identifier")
 }
 
-func lexDefine(l *lexer) stateFn {
-	l.pos += token.Pos(len("#define"))
-	l.emit(token.DEFINE)
-	return lexLineStart
-}
-
-//func lexIfDefined(l *lexer) stateFn {
-//	l.Pos += Pos(len("#ifdef"))
-//	l.acceptRun(" ")
-//	l.ignore()
-//	if l.accept("_" + groupUpper + groupLower) {
-//		l.acceptRun("_" + groupUpper + groupLower + groupDigits)
-//		if l.peek() != '\n' {
-//			return l.errorf("expected line break")
-//		}
-//		l.emit(ItemIfDefined)
-//		return lexLineStart
-//	}
-//	return l.errorf("expected identifier")
-//}
-
-func lexIfDefined(l *lexer) stateFn {
-	l.pos += token.Pos(len("#ifdef"))
-	l.emit(token.IFDEF)
-	return lexLineStart
-}
-
-func lexIfNotDefined(l *lexer) stateFn {
-	l.pos += token.Pos(len("#ifndef"))
-	l.emit(token.IFNDEF)
+// lexHasInclude lexes the header name argument of __has_include like the
+// path of an #include directive.
+func lexHasInclude(l *lexer) stateFn {
+	l.acceptRun(" \t")
+	if l.pos > l.start {
+		l.emit(token.WHITESPACE)
+	}
+	if !l.accept("(") {
+		return lexLineStart
+	}
+	l.emit(token.LPAREN)
+	l.acceptRun(" \t")
+	if l.pos > l.start {
+		l.emit(token.WHITESPACE)
+	}
+	switch l.peek() {
+	case '"':
+		l.next()
+		l.acceptRun(groupLower + groupUpper + groupDigits + "_-/\\.")
+		if l.accept(`"`) {
+			l.emit(token.INCLUDE_PATH)
+			return lexLineStart
+		}
+		return l.errorf("expected closing quotes")
+	case '<':
+		l.next()
+		l.acceptRun(groupLower + groupUpper + groupDigits + "_-/\\.")
+		if l.accept(">") {
+			l.emit(token.INCLUDE_PATH)
+			return lexLineStart
+		}
+		return l.errorf("expected closing angle bracket")
+	}
 	return lexLineStart
 }
 
@@ -334,8 +625,7 @@ func lexMultilineComment(l *lexer) stateFn {
 		n := l.next()
 		for n != '*' {
 			if n == eof {
-				l.emit(token.EOF)
-				return nil
+				return l.errorf("comment not terminated")
 			}
 			n = l.next()
 		}
@@ -347,3 +637,26 @@ func lexMultilineComment(l *lexer) stateFn {
 	}
 	return lexLineStart
 }
+
+// lexLineComment scans a comment up to the end of the line, which is
+// not part of the comment. A backslash at the end of the line continues
+// the comment.
+func lexLineComment(l *lexer) stateFn {
+	l.pos += 2
+	for {
+		i := strings.IndexByte(l.input[l.pos:], '\n')
+		if i < 0 {
+			l.pos = token.Pos(len(l.input))
+			break
+		}
+		line := strings.TrimSuffix(l.input[l.pos:int(l.pos)+i], "\r")
+		if !strings.HasSuffix(line, "\\") {
+			l.pos += token.Pos(len(line))
+			break
+		}
+		l.pos += token.Pos(i + 1)
+		l.file.AddLine(int(l.pos))
+	}
+	l.emit(token.COMMENT)
+	return lexLineStart
+}
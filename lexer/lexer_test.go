@@ -0,0 +1,59 @@
+package lexer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+// items returns the tokens and values of the items of input, except
+// whitespace, separated by spaces.
+func items(input string) string {
+	l := NewLexer("test.h", input)
+	var s []string
+	for {
+		item := l.NextItem()
+		switch item.Tok {
+		case token.EOF:
+			return strings.Join(s, " ")
+		case token.WHITESPACE:
+			continue
+		}
+		s = append(s, fmt.Sprintf("%s(%q)", item.Tok, item.Val))
+	}
+}
+
+func TestLexer_NextItem(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value string
+	}{
+		{"#define A 1", `#define("#define") IDENT("A") INT("1")`},
+		{"# define A 1", `#define("# define") IDENT("A") INT("1")`},
+		{"#\tif X", `#if("#\tif") IDENT("X")`},
+		{"#  ifdef X\n#  endif", `#ifdef("#  ifdef") IDENT("X") #endif("#  endif")`},
+		{"# /* c */ include <a.h>", `#include("# /* c */ include") INCLUDE_PATH("<a.h>")`},
+		{"#\tinclude\t\"a.h\"", `#include("#\tinclude") INCLUDE_PATH("\"a.h\"")`},
+		{"# define_x", `#("#") IDENT("define_x")`},
+		{"int x; #define A 1", `IDENT("int") IDENT("x") ;(";") #("#") IDENT("define") IDENT("A") INT("1")`},
+		{"/* c */ #define A\nx\n\t#if A", `COMMENT("/* c */") #define("#define") IDENT("A") IDENT("x") #if("#if") IDENT("A")`},
+		{"#x ## y", `#("#") IDENT("x") ##("##") IDENT("y")`},
+		{"32.h)", `ILLEGAL("invalid suffix on constant \"32.h\"") )(")")`},
+		{"0x1e+2 1e+2", `ILLEGAL("invalid suffix on constant \"0x1e+2\"") FLOAT("1e+2")`},
+		{"1.2.3;", `ILLEGAL("invalid suffix on constant \"1.2.3\"") ;(";")`},
+		{"int @x;\nint y;", `IDENT("int") ILLEGAL("unknown character '@'") IDENT("x") ;(";") IDENT("int") IDENT("y") ;(";")`},
+		{"x = '';\nint y;", `IDENT("x") =("=") ILLEGAL("empty character constant") ;(";") IDENT("int") IDENT("y") ;(";")`},
+		{"char *s = \"a\nint y;", `IDENT("char") *("*") IDENT("s") =("=") ILLEGAL("unterminated string literal") IDENT("int") IDENT("y") ;(";")`},
+		{"1.5f .5 0x1p-2", `FLOAT("1.5f") FLOAT(".5") FLOAT("0x1p-2")`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			if got := items(test.Input); got != test.Value {
+				t.Errorf("got %s, want %s", got, test.Value)
+			}
+		})
+	}
+}
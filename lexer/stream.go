@@ -0,0 +1,89 @@
+package lexer
+
+import (
+	"context"
+
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+// streamBuffer is the number of items a Stream lexes ahead.
+const streamBuffer = 64
+
+// A Stream is a Lexer running in its own goroutine, so that lexing a
+// large file overlaps with consuming its items. The goroutine stops at
+// the end of the input, when the context of the stream is done, or when
+// the stream is closed. A consumer that stops early must call Close.
+type Stream struct {
+	lex    *lexer
+	items  chan Item
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	eof    Item
+	ended  bool // whether the last item was returned, or Close was called
+}
+
+// NewStream returns a Stream lexing the contents of file, see
+// NewFileLexer.
+func NewStream(ctx context.Context, file *token.File, input string) *Stream {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &Stream{
+		lex:    NewFileLexer(file, input),
+		items:  make(chan Item, streamBuffer),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+		eof:    Item{token.Pos(file.Base() + len(input)), "", token.EOF},
+	}
+	go s.run()
+	return s
+}
+
+func (s *Stream) run() {
+	defer close(s.done)
+	defer close(s.items)
+	for {
+		item := s.lex.NextItem()
+		select {
+		case s.items <- item:
+		case <-s.ctx.Done():
+			return
+		}
+		if item.Tok == token.EOF {
+			return
+		}
+	}
+}
+
+// File returns the file the positions of the items of s belong to.
+func (s *Stream) File() *token.File {
+	return s.lex.file
+}
+
+// NextItem returns the next item of the input. If the context of s is
+// done before the end of the input, it returns an ILLEGAL item holding
+// the error of the context once, and EOF items afterwards.
+func (s *Stream) NextItem() Item {
+	item, ok := <-s.items
+	if ok {
+		s.ended = item.Tok == token.EOF
+		return item
+	}
+	if err := s.ctx.Err(); err != nil && !s.ended {
+		s.ended = true
+		return Item{s.eof.Pos, err.Error(), token.ILLEGAL}
+	}
+	return s.eof
+}
+
+// Close stops the goroutine of s and waits for it to exit. Items are
+// not returned after Close, so NextItem returns EOF items. Close may
+// be called any number of times; it always returns nil.
+func (s *Stream) Close() error {
+	s.ended = true
+	s.cancel()
+	<-s.done
+	for range s.items {
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package lexer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+func TestStream_NextItem(t *testing.T) {
+	tests := []string{
+		"#define A (1 << 2)\n",
+		"int @x;\nint y;\n",
+	}
+
+	for _, input := range tests {
+		t.Run(input, func(t *testing.T) {
+			want := NewLexer("test.h", input)
+			s := NewStream(context.Background(), token.NewFile("test.h", len(input)), input)
+			defer s.Close()
+			for {
+				w, got := want.NextItem(), s.NextItem()
+				if got != w {
+					t.Fatalf("got %v, want %v", got, w)
+				}
+				if got.Tok == token.EOF {
+					break
+				}
+			}
+			if got := s.NextItem(); got.Tok != token.EOF {
+				t.Errorf("got %v after EOF, want EOF", got)
+			}
+		})
+	}
+}
+
+func TestStream_Cancel(t *testing.T) {
+	input := strings.Repeat("a ", 10*streamBuffer)
+	ctx, cancel := context.WithCancel(context.Background())
+	s := NewStream(ctx, token.NewFile("test.h", len(input)), input)
+	s.NextItem()
+	cancel()
+	<-s.done
+
+	var last Item
+	for last.Tok != token.EOF {
+		last = s.NextItem()
+		if last.Tok == token.ILLEGAL {
+			break
+		}
+	}
+	if last.Tok != token.ILLEGAL || last.Val != context.Canceled.Error() {
+		t.Errorf("got %v, want %v", last, context.Canceled)
+	}
+	if err := s.Close(); err != nil {
+		t.Error(err)
+	}
+	if got := s.NextItem(); got.Tok != token.EOF {
+		t.Errorf("got %v after Close, want EOF", got)
+	}
+}
@@ -2,29 +2,332 @@ package parser
 
 import (
 	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/lexer"
 	"github.com/SHyx0rmZ/cgen/token"
 )
 
-func (p *parser) parseExternDecl() ast.Decl {
+func (p *parser) parseExternDecl() []ast.Decl {
+	defer un(trace(p, "ExternDecl"))
+
+	doc := p.leadComment
 	keyword := p.expect(token.EXTERN, "external declaration")
 	next := p.peekNonSpace()
 	if next.Tok == token.STRING && next.Val == `"C"` {
 		p.next()
 		curly := p.expect(token.LBRACE, "external declaration")
-		return &ast.ExternDecl{
-			KeyPos: keyword.Pos,
-			Decl: &ast.CDecl{
-				Value: &ast.BasicLit{
-					ValuePos: next.Pos,
-					Kind:     token.STRING,
-					Value:    next.Val,
+		p.cblocks++
+		return []ast.Decl{
+			&ast.ExternDecl{
+				KeyPos: keyword.Pos,
+				Decl: &ast.CDecl{
+					Value: &ast.BasicLit{
+						ValuePos: next.Pos,
+						Kind:     token.STRING,
+						Value:    next.Val,
+					},
+					BodyPos: curly.Pos,
 				},
-				BodyPos: curly.Pos,
 			},
 		}
 	}
-	return &ast.ExternDecl{
-		KeyPos: keyword.Pos,
-		Decl:   nil,
+	if next.Tok == token.EOF {
+		return []ast.Decl{
+			&ast.ExternDecl{
+				KeyPos: keyword.Pos,
+				Decl:   nil,
+			},
+		}
+	}
+
+	var decls []ast.Decl
+	for _, decl := range p.parseDecl(doc) {
+		decls = append(decls, &ast.ExternDecl{
+			KeyPos: keyword.Pos,
+			Decl:   decl,
+		})
+	}
+	return decls
+}
+
+// parseDecl parses a declaration. A declaration with several declarators
+// results in one node per declarator. The documentation doc preceding
+// the declaration is attached to the functions it declares.
+func (p *parser) parseDecl(doc *ast.CommentGroup) []ast.Decl {
+	defer un(trace(p, "Decl"))
+
+	if p.peekNonSpace().Tok == token.TYPEDEF {
+		return p.parseTypeDecl()
+	}
+
+	typ := p.parseSpecifiers("declaration")
+	if p.peekNonSpace().Tok == token.SEMICOLON {
+		semicolon := p.next()
+		return []ast.Decl{
+			&ast.StructDecl{
+				Type:      typ,
+				Semicolon: semicolon.Pos,
+			},
+		}
+	}
+
+	var decls []ast.Decl
+	for {
+		name, t := p.parseDeclarator(typ, "declaration")
+		if name == nil {
+			p.unexpected(p.peekNonSpace(), "declaration")
+		}
+		p.parseAttributes() // attributes of declarations are skipped
+		if f, ok := t.(*ast.FuncType); ok {
+			decl := &ast.FuncDecl{
+				Doc:  doc,
+				Name: name,
+				Type: f,
+			}
+			p.declare(decl, ast.Fun, name)
+			if p.peekNonSpace().Tok == token.LBRACE {
+				decl.Body = p.parseBlockStmt()
+				return append(decls, decl)
+			}
+			decls = append(decls, decl)
+		} else {
+			decl := &ast.VarDecl{
+				Type: t,
+				Name: name,
+			}
+			p.declare(decl, ast.Var, name)
+			decls = append(decls, decl)
+		}
+		if p.peekNonSpace().Tok != token.COMMA {
+			break
+		}
+		p.next()
+	}
+	p.expect(token.SEMICOLON, "declaration")
+	return decls
+}
+
+func (p *parser) parseTypeDecl() []ast.Decl {
+	defer un(trace(p, "TypeDecl"))
+
+	keyword := p.expect(token.TYPEDEF, "type declaration")
+	typ := p.parseSpecifiers("type declaration")
+
+	var decls []ast.Decl
+	for {
+		name, t := p.parseDeclarator(typ, "type declaration")
+		if name == nil {
+			p.unexpected(p.peekNonSpace(), "type declaration")
+		}
+		p.parseAttributes()
+		decl := &ast.TypeDecl{
+			KeyPos: keyword.Pos,
+			Type:   t,
+			Name:   name,
+		}
+		p.declare(decl, ast.Typ, name)
+		decls = append(decls, decl)
+		if p.peekNonSpace().Tok != token.COMMA {
+			break
+		}
+		p.next()
+	}
+	p.expect(token.SEMICOLON, "type declaration")
+	return decls
+}
+
+func (p *parser) parseStructType() *ast.StructType {
+	defer un(trace(p, "StructType"))
+
+	keyword := p.expectOneOf(token.STRUCT, token.UNION, "struct type")
+	typ := &ast.StructType{
+		Struct: keyword.Pos,
+		Kind:   keyword.Tok,
+		Attrs:  p.parseAttributes(),
+	}
+	if t := p.peekNonSpace(); t.Tok == token.IDENT {
+		p.next()
+		typ.Name = &ast.Ident{
+			NamePos: t.Pos,
+			Name:    t.Val,
+		}
+	}
+	if p.peekNonSpace().Tok != token.LBRACE {
+		if typ.Name == nil {
+			p.unexpected(p.next(), "struct type")
+		}
+		p.declareTag(typ, keyword.Val+" ", typ.Name, false)
+		return typ
+	}
+
+	opening := p.next()
+	typ.Fields = &ast.FieldList{Opening: opening.Pos}
+	if typ.Name != nil {
+		p.declareTag(typ, keyword.Val+" ", typ.Name, true)
+	}
+	var list []*ast.Field
+	for t := p.peekNonSpace(); t.Tok != token.RBRACE; t = p.peekNonSpace() {
+		if t.Tok == token.EOF {
+			p.unexpected(t, "struct type")
+		}
+		doc := p.leadComment
+		var fields []*ast.Field
+		if !p.try(func() { fields = p.parseFieldDecl() }) {
+			// A bad member doesn't affect the other members.
+			fields = []*ast.Field{{Type: p.syncField(t)}}
+		}
+		// the line comment is known once the next token is read
+		p.peekNonSpace()
+		for _, f := range fields {
+			f.Doc, f.Comment = doc, p.lineComment
+		}
+		list = append(list, fields...)
+	}
+	typ.Fields.List = list
+	typ.Fields.Closing = p.next().Pos
+	return typ
+}
+
+// parseFieldDecl parses the declaration of one or more struct members.
+func (p *parser) parseFieldDecl() []*ast.Field {
+	defer un(trace(p, "FieldDecl"))
+
+	t := p.parseSpecifiers("struct type")
+	if p.peekNonSpace().Tok == token.SEMICOLON {
+		// anonymous struct or union member
+		p.next()
+		return []*ast.Field{{Type: t}}
+	}
+	var list []*ast.Field
+	for {
+		name, t := p.parseDeclarator(t, "struct type")
+		field := &ast.Field{
+			Type:  t,
+			Name:  name,
+			Attrs: p.parseAttributes(),
+		}
+		if p.peekNonSpace().Tok == token.COLON {
+			p.next()
+			field.Bits = p.parseCondExpr()
+			field.Attrs = append(field.Attrs, p.parseAttributes()...)
+		}
+		list = append(list, field)
+		if p.peekNonSpace().Tok != token.COMMA {
+			break
+		}
+		p.next()
+	}
+	p.expect(token.SEMICOLON, "struct type")
+	return list
+}
+
+// syncField skips the rest of a bad member declaration starting with
+// start, up to and including its ";", and returns a BadExpr for it. The
+// closing brace of the struct is left for the caller.
+func (p *parser) syncField(start lexer.Item) ast.Expr {
+	to := start.Pos
+	depth := 0
+	for {
+		t := p.peekNonSpace()
+		if t.Tok == token.EOF || t.Tok == token.RBRACE && depth == 0 {
+			break
+		}
+		p.next()
+		to = token.Pos(int(t.Pos) + len(t.Val))
+		switch t.Tok {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			depth--
+		case token.SEMICOLON:
+			if depth == 0 {
+				return &ast.BadExpr{From: start.Pos, To: to}
+			}
+		}
+	}
+	return &ast.BadExpr{From: start.Pos, To: to}
+}
+
+func (p *parser) parseEnumDecl() *ast.EnumDecl {
+	defer un(trace(p, "EnumDecl"))
+
+	keyword := p.expect(token.ENUM, "enum type")
+	decl := &ast.EnumDecl{
+		Enum: keyword.Pos,
+	}
+	if t := p.peekNonSpace(); t.Tok == token.IDENT {
+		p.next()
+		decl.Name = &ast.Ident{
+			NamePos: t.Pos,
+			Name:    t.Val,
+		}
+	}
+	if p.peekNonSpace().Tok != token.LBRACE {
+		if decl.Name == nil {
+			p.unexpected(p.next(), "enum type")
+		}
+		p.declareTag(decl, "enum ", decl.Name, false)
+		return decl
+	}
+
+	decl.Opening = p.next().Pos
+	if decl.Name != nil {
+		p.declareTag(decl, "enum ", decl.Name, true)
+	}
+	for p.peekNonSpace().Tok != token.RBRACE {
+		doc := p.leadComment
+		id := p.expect(token.IDENT, "enum type")
+		name := &ast.Ident{
+			NamePos: id.Pos,
+			Name:    id.Val,
+		}
+		var x ast.Expr
+		if p.peekNonSpace().Tok == token.ASSIGN {
+			p.next()
+			x = p.parseCondExpr()
+		}
+		comma := p.peekNonSpace().Tok == token.COMMA
+		if comma {
+			p.next()
+			p.peekNonSpace()
+		}
+		var spec ast.EnumSpec
+		switch lit, ok := x.(*ast.BasicLit); {
+		case x == nil, ok:
+			spec = &ast.EnumValue{Doc: doc, Name: name, Value: lit, Comment: p.lineComment}
+		default:
+			spec = &ast.EnumConstExpr{Doc: doc, Name: name, Expr: x, Comment: p.lineComment}
+		}
+		p.declare(spec, ast.Con, name)
+		decl.Specs = append(decl.Specs, spec)
+		if !comma {
+			break
+		}
+	}
+	decl.Closing = p.expect(token.RBRACE, "enum type").Pos
+	return decl
+}
+
+// parseBlockStmt skips the body of a function definition.
+func (p *parser) parseBlockStmt() *ast.BlockStmt {
+	opening := p.expect(token.LBRACE, "function body")
+	depth := 1
+	for {
+		t := p.next()
+		switch t.Tok {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			depth--
+			if depth == 0 {
+				return &ast.BlockStmt{
+					Opening: opening.Pos,
+					Closing: t.Pos,
+				}
+			}
+		case token.EOF:
+			p.unexpected(t, "function body")
+		case token.ILLEGAL:
+			p.errorf("%s", t.Val)
+		}
 	}
 }
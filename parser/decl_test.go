@@ -3,7 +3,6 @@ package parser
 import (
 	"bytes"
 	"fmt"
-	goast "go/ast"
 	"reflect"
 	"testing"
 
@@ -41,6 +40,298 @@ func TestParser_ParseDecl(t *testing.T) {
 				},
 			},
 		},
+		{
+			"extern \"C\" {\n}",
+			[]ast.Node{
+				&ast.ExternDecl{
+					KeyPos: 0,
+					Decl: &ast.CDecl{
+						Value: &ast.BasicLit{
+							ValuePos: 7,
+							Kind:     token.STRING,
+							Value:    `"C"`,
+						},
+						BodyPos: 11,
+					},
+				},
+				&ast.EndCDecl{
+					Closing: 13,
+				},
+			},
+		},
+		{
+			"extern int errno;",
+			[]ast.Node{
+				&ast.ExternDecl{
+					KeyPos: 0,
+					Decl: &ast.VarDecl{
+						Type: &ast.BasicType{
+							From: 7,
+							To:   10,
+							Name: "int",
+						},
+						Name: &ast.Ident{
+							NamePos: 11,
+							Name:    "errno",
+						},
+					},
+				},
+			},
+		},
+		{
+			"typedef unsigned long size_t, *psize_t;",
+			[]ast.Node{
+				&ast.TypeDecl{
+					KeyPos: 0,
+					Type: &ast.BasicType{
+						From: 8,
+						To:   21,
+						Name: "unsigned long",
+					},
+					Name: &ast.Ident{
+						NamePos: 22,
+						Name:    "size_t",
+					},
+				},
+				&ast.TypeDecl{
+					KeyPos: 0,
+					Type: &ast.PointerType{
+						Star: 30,
+						Elem: &ast.BasicType{
+							From: 8,
+							To:   21,
+							Name: "unsigned long",
+						},
+					},
+					Name: &ast.Ident{
+						NamePos: 31,
+						Name:    "psize_t",
+					},
+				},
+			},
+		},
+		{
+			"struct s { int a; union { char b; }; };",
+			[]ast.Node{
+				&ast.StructDecl{
+					Type: &ast.StructType{
+						Struct: 0,
+						Kind:   token.STRUCT,
+						Name: &ast.Ident{
+							NamePos: 7,
+							Name:    "s",
+						},
+						Fields: &ast.FieldList{
+							Opening: 9,
+							List: []*ast.Field{
+								{
+									Type: &ast.BasicType{
+										From: 11,
+										To:   14,
+										Name: "int",
+									},
+									Name: &ast.Ident{
+										NamePos: 15,
+										Name:    "a",
+									},
+								},
+								{
+									Type: &ast.StructType{
+										Struct: 18,
+										Kind:   token.UNION,
+										Fields: &ast.FieldList{
+											Opening: 24,
+											List: []*ast.Field{
+												{
+													Type: &ast.BasicType{
+														From: 26,
+														To:   30,
+														Name: "char",
+													},
+													Name: &ast.Ident{
+														NamePos: 31,
+														Name:    "b",
+													},
+												},
+											},
+											Closing: 34,
+										},
+									},
+								},
+							},
+							Closing: 37,
+						},
+					},
+					Semicolon: 38,
+				},
+			},
+		},
+		{
+			"enum e { A, B = 1, C = A | 2 };",
+			[]ast.Node{
+				&ast.StructDecl{
+					Type: &ast.EnumDecl{
+						Enum: 0,
+						Name: &ast.Ident{
+							NamePos: 5,
+							Name:    "e",
+						},
+						Opening: 7,
+						Specs: []ast.EnumSpec{
+							&ast.EnumValue{
+								Name: &ast.Ident{
+									NamePos: 9,
+									Name:    "A",
+								},
+							},
+							&ast.EnumValue{
+								Name: &ast.Ident{
+									NamePos: 12,
+									Name:    "B",
+								},
+								Value: &ast.BasicLit{
+									ValuePos: 16,
+									Kind:     token.INT,
+									Value:    "1",
+								},
+							},
+							&ast.EnumConstExpr{
+								Name: &ast.Ident{
+									NamePos: 19,
+									Name:    "C",
+								},
+								Expr: &ast.BinaryExpr{
+									X: &ast.Ident{
+										NamePos: 23,
+										Name:    "A",
+									},
+									OpPos: 25,
+									Op:    token.OR,
+									Y: &ast.BasicLit{
+										ValuePos: 27,
+										Kind:     token.INT,
+										Value:    "2",
+									},
+								},
+							},
+						},
+						Closing: 29,
+					},
+					Semicolon: 30,
+				},
+			},
+		},
+		{
+			"int printf(const char *, ...);",
+			[]ast.Node{
+				&ast.FuncDecl{
+					Name: &ast.Ident{
+						NamePos: 4,
+						Name:    "printf",
+					},
+					Type: &ast.FuncType{
+						Result: &ast.BasicType{
+							From: 0,
+							To:   3,
+							Name: "int",
+						},
+						Params: &ast.FieldList{
+							Opening: 10,
+							List: []*ast.Field{
+								{
+									Type: &ast.PointerType{
+										Star: 22,
+										Elem: &ast.QualType{
+											QualPos: 11,
+											Qual:    ast.CONST,
+											Type: &ast.BasicType{
+												From: 17,
+												To:   21,
+												Name: "char",
+											},
+										},
+									},
+								},
+								{
+									Type: &ast.Ellipsis{
+										Ellipsis: 25,
+									},
+								},
+							},
+							Closing: 28,
+						},
+					},
+				},
+			},
+		},
+		{
+			"struct __attribute__((packed)) s { char c __attribute__((aligned(2))); };",
+			[]ast.Node{
+				&ast.StructDecl{
+					Type: &ast.StructType{
+						Struct: 0,
+						Kind:   token.STRUCT,
+						Name: &ast.Ident{
+							NamePos: 31,
+							Name:    "s",
+						},
+						Fields: &ast.FieldList{
+							Opening: 33,
+							List: []*ast.Field{
+								{
+									Type: &ast.BasicType{
+										From: 35,
+										To:   39,
+										Name: "char",
+									},
+									Name: &ast.Ident{
+										NamePos: 40,
+										Name:    "c",
+									},
+									Attrs: []*ast.Attribute{
+										{
+											Attr: 42,
+											List: []ast.Expr{
+												&ast.CallExpr{
+													Fun: &ast.Ident{
+														NamePos: 57,
+														Name:    "aligned",
+													},
+													Opening: 64,
+													Args: []ast.Expr{
+														&ast.BasicLit{
+															ValuePos: 65,
+															Kind:     token.INT,
+															Value:    "2",
+														},
+													},
+													Closing: 66,
+												},
+											},
+											Closing: 68,
+										},
+									},
+								},
+							},
+							Closing: 71,
+						},
+						Attrs: []*ast.Attribute{
+							{
+								Attr: 7,
+								List: []ast.Expr{
+									&ast.Ident{
+										NamePos: 22,
+										Name:    "packed",
+									},
+								},
+								Closing: 29,
+							},
+						},
+					},
+					Semicolon: 72,
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -50,9 +341,9 @@ func TestParser_ParseDecl(t *testing.T) {
 
 			if !reflect.DeepEqual(actual, test.Value) {
 				bufGot := new(bytes.Buffer)
-				goast.Fprint(bufGot, nil, actual, goast.NotNilFilter)
+				ast.Fprint(bufGot, nil, actual, ast.NotNilFilter)
 				bufWant := new(bytes.Buffer)
-				goast.Fprint(bufWant, nil, test.Value, goast.NotNilFilter)
+				ast.Fprint(bufWant, nil, test.Value, ast.NotNilFilter)
 				t.Errorf("%s:\ngot:\n%swant:\n%s", parser.name, bufGot.String(), bufWant.String())
 			}
 		})
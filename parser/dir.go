@@ -1,9 +1,11 @@
 package parser
 
 import (
+	"strings"
+
 	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/lexer"
 	"github.com/SHyx0rmZ/cgen/token"
-	"strings"
 )
 
 func (p *parser) parseArgList() *ast.ArgList {
@@ -12,64 +14,118 @@ func (p *parser) parseArgList() *ast.ArgList {
 	}
 
 	open := p.next()
-	var list []*ast.Ident
-	if p.peek().Tok == token.IDENT {
-		id := p.next()
-		list = append(list, &ast.Ident{
-			NamePos: id.Pos,
-			Name:    id.Val,
-		})
-
-		for p.peek().Tok == token.COMMA {
-			id = p.expect(token.IDENT, "macro argument list")
-			list = append(list, &ast.Ident{
+	args := &ast.ArgList{
+		Opening: open.Pos,
+	}
+	for p.peekNonSpace().Tok != token.RPAREN {
+		if len(args.List) > 0 || args.Ellipsis != nil {
+			p.expect(token.COMMA, "macro argument list")
+		}
+		if args.Ellipsis != nil {
+			p.unexpected(p.nextNonSpace(), "macro argument list")
+		}
+		id := p.expectOneOf(token.IDENT, token.ELLIPSIS, "macro argument list")
+		if id.Tok == token.IDENT {
+			args.List = append(args.List, &ast.Ident{
 				NamePos: id.Pos,
 				Name:    id.Val,
 			})
+			if p.peekNonSpace().Tok != token.ELLIPSIS {
+				continue
+			}
+			id = p.next()
 		}
+		args.Ellipsis = &ast.Ellipsis{Ellipsis: id.Pos}
 	}
-	closing := p.expect(token.RPAREN, "macro argument list")
+	args.Closing = p.expect(token.RPAREN, "macro argument list").Pos
 
-	return &ast.ArgList{
-		Opening: open.Pos,
-		List:    list,
-		Closing: closing.Pos,
-	}
+	return args
 }
 
+// parseMacroDir parses a macro definition. A replacement list that is
+// not a single expression is recorded as a BadExpr spanning the rest of
+// the line.
 func (p *parser) parseMacroDir() ast.Dir {
+	defer un(trace(p, "MacroDir"))
+
+	doc := p.leadComment
 	keyword := p.expect(token.DEFINE, "macro definition")
+	p.directive = true
+	defer func() { p.directive = false }()
+
 	name := p.expect(token.IDENT, "macro definition")
-	args := p.parseArgList()
-	switch p.peek().Tok {
-	case token.WHITESPACE, token.EOF:
-		if p.peek().Val == "" || strings.Contains(p.peek().Val, "\n") {
-			return &ast.MacroDir{
-				DirPos: keyword.Pos,
-				Name: &ast.Ident{
-					NamePos: name.Pos,
-					Name:    name.Val,
-				},
-				Args:  args,
-				Value: nil,
-			}
+	dir := &ast.MacroDir{
+		Doc:    doc,
+		DirPos: keyword.Pos,
+		Name: &ast.Ident{
+			NamePos: name.Pos,
+			Name:    name.Val,
+		},
+		Args: p.parseArgList(),
+	}
+	if p.atLineEnd(p.peekNonSpace()) {
+		dir.Comment = p.lineComment
+		p.declareMacro(dir)
+		return dir
+	}
+
+	p.macro = dir
+	defer func() { p.macro = nil }()
+	from, errors := p.peekNonSpace().Pos, len(p.errors)
+	if !p.try(func() { dir.Value = p.parseExpr() }) {
+		// Bodies that aren't expressions, like "{" or "STR(a b)", are
+		// valid. Only errors of the lexer are kept.
+		if p.token[0].Tok != token.ILLEGAL {
+			p.errors = p.errors[:errors]
 		}
-		fallthrough
-	default:
+		dir.Value = &ast.BadExpr{From: from, To: p.skipLine()}
+	} else if !p.atLineEnd(p.peekNonSpace()) {
+		dir.Value = &ast.BadExpr{From: from, To: p.skipLine()}
+	}
+	dir.Comment = p.lineComment
+	p.declareMacro(dir)
+	return dir
+}
+
+// skipLine skips the rest of the directive being parsed and returns the
+// end of its last token.
+func (p *parser) skipLine() token.Pos {
+	if p.peekCount == 0 && p.atEOL(p.token[0]) {
+		// the line break was already read
+		return p.token[0].Pos
+	}
+	end := p.token[0].Pos
+	for t := p.peekNonSpace(); !p.atLineEnd(t); t = p.peekNonSpace() {
 		p.next()
-		return &ast.MacroDir{
-			DirPos: keyword.Pos,
-			Name: &ast.Ident{
-				NamePos: name.Pos,
-				Name:    name.Val,
-			},
-			Args:  args,
-			Value: p.parseExpr(),
-		}
+		end = token.Pos(int(t.Pos) + len(t.Val))
+	}
+	return end
+}
+
+// atLineEnd reports whether t ends the directive being parsed.
+func (p *parser) atLineEnd(t lexer.Item) bool {
+	return t.Tok == token.EOF || p.atEOL(t)
+}
+
+func (p *parser) parseUndefDir() ast.Dir {
+	defer un(trace(p, "UndefDir"))
+
+	keyword := p.expect(token.UNDEF, "undef directive")
+	name := p.expect(token.IDENT, "undef directive")
+	dir := &ast.UndefDir{
+		DirPos: keyword.Pos,
+		Name: &ast.Ident{
+			NamePos: name.Pos,
+			Name:    name.Val,
+		},
 	}
+	p.undefine(dir.Name)
+	return dir
 }
 
 func (p *parser) parseIncludeDir() ast.Dir {
+	defer un(trace(p, "IncludeDir"))
+
 	keyword := p.expect(token.INCLUDE, "include directive")
 	path := p.expect(token.INCLUDE_PATH, "include directive")
 	return &ast.IncludeDir{
@@ -79,10 +135,34 @@ func (p *parser) parseIncludeDir() ast.Dir {
 	}
 }
 
+// parsePragmaDir parses a #pragma directive, keeping its arguments as
+// text.
+func (p *parser) parsePragmaDir() ast.Dir {
+	defer un(trace(p, "PragmaDir"))
+
+	keyword := p.expect(token.PRAGMA, "pragma directive")
+	p.directive = true
+	defer func() { p.directive = false }()
+
+	dir := &ast.PragmaDir{DirPos: keyword.Pos}
+	var text []byte
+	for t := p.peekNonSpace(); !p.atLineEnd(t) && t.Tok != token.ILLEGAL; t = p.peek() {
+		p.next()
+		if text == nil {
+			dir.TextPos = t.Pos
+		}
+		text = append(text, t.Val...)
+	}
+	dir.Text = strings.TrimSpace(string(text))
+	return dir
+}
+
 func (p *parser) parseIfDefDir(cond ast.IfDefCond) ast.Dir {
+	defer un(trace(p, "IfDefDir"))
+
 	keyword := p.expectOneOf(token.IFDEF, token.IFNDEF, "conditional directive")
 	identifier := p.expect(token.IDENT, "conditional directive")
-	return &ast.IfDefDir{
+	dir := &ast.IfDefDir{
 		DirPos: keyword.Pos,
 		Cond:   cond,
 		Name: &ast.Ident{
@@ -90,4 +170,51 @@ func (p *parser) parseIfDefDir(cond ast.IfDefCond) ast.Dir {
 			Name:    identifier.Val,
 		},
 	}
+	p.resolveMacro(dir.Name)
+	return dir
+}
+
+// parseIfDir parses an #if or #elif directive.
+func (p *parser) parseIfDir() ast.Dir {
+	defer un(trace(p, "IfDir"))
+
+	keyword := p.expectOneOf(token.IF, token.ELIF, "conditional directive")
+	p.directive = true
+	defer func() { p.directive = false }()
+
+	cond := p.parseExpr()
+	if t := p.peekNonSpace(); !p.atLineEnd(t) {
+		p.unexpected(t, "conditional directive")
+	}
+	if keyword.Tok == token.ELIF {
+		return &ast.ElifDir{
+			DirPos: keyword.Pos,
+			Cond:   cond,
+		}
+	}
+	return &ast.IfDir{
+		DirPos: keyword.Pos,
+		Cond:   cond,
+	}
+}
+
+// parseDefinedExpr parses the operand of the defined operator, with or
+// without parentheses.
+func (p *parser) parseDefinedExpr() ast.Expr {
+	keyword := p.next()
+	x := &ast.DefinedExpr{Defined: keyword.Pos}
+	paren := p.peekNonSpace().Tok == token.LPAREN
+	if paren {
+		p.next()
+	}
+	name := p.expect(token.IDENT, "defined operator")
+	x.Name = &ast.Ident{
+		NamePos: name.Pos,
+		Name:    name.Val,
+	}
+	p.resolveMacro(x.Name)
+	if paren {
+		x.Closing = p.expect(token.RPAREN, "defined operator").Pos
+	}
+	return x
 }
@@ -3,7 +3,6 @@ package parser
 import (
 	"bytes"
 	"fmt"
-	goast "go/ast"
 	"reflect"
 	"testing"
 
@@ -142,6 +141,120 @@ func TestParser_ParseDir(t *testing.T) {
 				},
 			},
 		},
+		{
+			"#define F(a, ...) a",
+			[]ast.Node{
+				&ast.MacroDir{
+					DirPos: 0,
+					Name: &ast.Ident{
+						NamePos: 8,
+						Name:    "F",
+					},
+					Args: &ast.ArgList{
+						Opening: 9,
+						List: []*ast.Ident{
+							{
+								NamePos: 10,
+								Name:    "a",
+							},
+						},
+						Ellipsis: &ast.Ellipsis{
+							Ellipsis: 13,
+						},
+						Closing: 16,
+					},
+					Value: &ast.Ident{
+						NamePos: 18,
+						Name:    "a",
+					},
+				},
+			},
+		},
+		{
+			"#define S(x) #x\n",
+			[]ast.Node{
+				&ast.MacroDir{
+					DirPos: 0,
+					Name: &ast.Ident{
+						NamePos: 8,
+						Name:    "S",
+					},
+					Args: &ast.ArgList{
+						Opening: 9,
+						List: []*ast.Ident{
+							{
+								NamePos: 10,
+								Name:    "x",
+							},
+						},
+						Closing: 11,
+					},
+					Value: &ast.BadExpr{
+						From: 13,
+						To:   15,
+					},
+				},
+			},
+		},
+		{
+			"#define EMPTY ( )",
+			[]ast.Node{
+				&ast.MacroDir{
+					DirPos: 0,
+					Name: &ast.Ident{
+						NamePos: 8,
+						Name:    "EMPTY",
+					},
+					Value: &ast.ParenExpr{
+						Opening: 14,
+						Closing: 16,
+					},
+				},
+			},
+		},
+		{
+			"#define NAME STR(hello world)",
+			[]ast.Node{
+				&ast.MacroDir{
+					DirPos: 0,
+					Name: &ast.Ident{
+						NamePos: 8,
+						Name:    "NAME",
+					},
+					Value: &ast.BadExpr{
+						From: 13,
+						To:   29,
+					},
+				},
+			},
+		},
+		{
+			"#define BEGIN {\nint x;",
+			[]ast.Node{
+				&ast.MacroDir{
+					DirPos: 0,
+					Name: &ast.Ident{
+						NamePos: 8,
+						Name:    "BEGIN",
+					},
+					Value: &ast.BadExpr{
+						From: 14,
+						To:   15,
+					},
+				},
+				&ast.VarDecl{
+					Type: &ast.BasicType{
+						From: 16,
+						To:   19,
+						Name: "int",
+					},
+					Name: &ast.Ident{
+						NamePos: 20,
+						Name:    "x",
+					},
+				},
+			},
+		},
 		{
 			`#include "stddef.h"`,
 			[]ast.Node{
@@ -177,6 +290,56 @@ func TestParser_ParseDir(t *testing.T) {
 					DirPos: 0,
 				},
 			},
+		}, {
+			"#if defined(A) && !defined B\n#elif 1\n",
+			[]ast.Node{
+				&ast.IfDir{
+					DirPos: 0,
+					Cond: &ast.BinaryExpr{
+						X: &ast.DefinedExpr{
+							Defined: 4,
+							Name: &ast.Ident{
+								NamePos: 12,
+								Name:    "A",
+							},
+							Closing: 13,
+						},
+						OpPos: 15,
+						Op:    token.LAND,
+						Y: &ast.UnaryExpr{
+							OpPos: 18,
+							Op:    token.NOT,
+							X: &ast.DefinedExpr{
+								Defined: 19,
+								Name: &ast.Ident{
+									NamePos: 27,
+									Name:    "B",
+								},
+							},
+						},
+					},
+				},
+				&ast.ElifDir{
+					DirPos: 29,
+					Cond: &ast.BasicLit{
+						ValuePos: 35,
+						Kind:     token.INT,
+						Value:    "1",
+					},
+				},
+			},
+		},
+		{
+			"#undef A",
+			[]ast.Node{
+				&ast.UndefDir{
+					DirPos: 0,
+					Name: &ast.Ident{
+						NamePos: 7,
+						Name:    "A",
+					},
+				},
+			},
 		},
 	}
 
@@ -184,12 +347,15 @@ func TestParser_ParseDir(t *testing.T) {
 		t.Run(fmt.Sprintf("%s", test.Input), func(t *testing.T) {
 			parser := NewParser(t.Name(), test.Input)
 			actual := parser.Nodes()
+			if err := parser.Err(); err != nil {
+				t.Error(err)
+			}
 
 			if !reflect.DeepEqual(actual, test.Value) {
 				bufGot := new(bytes.Buffer)
-				goast.Fprint(bufGot, nil, actual, goast.NotNilFilter)
+				ast.Fprint(bufGot, nil, actual, ast.NotNilFilter)
 				bufWant := new(bytes.Buffer)
-				goast.Fprint(bufWant, nil, test.Value, goast.NotNilFilter)
+				ast.Fprint(bufWant, nil, test.Value, ast.NotNilFilter)
 				t.Errorf("%s:\ngot:\n%swant:\n%s", parser.name, bufGot.String(), bufWant.String())
 			}
 		})
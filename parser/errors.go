@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+// An Error describes a syntax error at a position.
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("cgen: %s: %s", e.Pos, e.Msg)
+}
+
+// An ErrorList is a list of *Errors, as reported by a parser.
+type ErrorList []*Error
+
+// Add adds an Error with the given position and message to p.
+func (p *ErrorList) Add(pos token.Position, msg string) {
+	*p = append(*p, &Error{pos, msg})
+}
+
+// Reset resets p to an empty list.
+func (p *ErrorList) Reset() { *p = (*p)[0:0] }
+
+func (p ErrorList) Len() int      { return len(p) }
+func (p ErrorList) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+func (p ErrorList) Less(i, j int) bool {
+	e, f := &p[i].Pos, &p[j].Pos
+	if e.Filename != f.Filename {
+		return e.Filename < f.Filename
+	}
+	if e.Line != f.Line {
+		return e.Line < f.Line
+	}
+	if e.Column != f.Column {
+		return e.Column < f.Column
+	}
+	return p[i].Msg < p[j].Msg
+}
+
+// Sort sorts p by position: by filename, line and column, and by
+// message for errors at the same position.
+func (p ErrorList) Sort() {
+	sort.Sort(p)
+}
+
+// An ErrorList implements the error interface.
+func (p ErrorList) Error() string {
+	switch len(p) {
+	case 0:
+		return "no errors"
+	case 1:
+		return p[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", p[0], len(p)-1)
+}
+
+// Err returns an error equivalent to this error list. If the list is
+// empty, Err returns nil.
+func (p ErrorList) Err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return p
+}
@@ -1,34 +1,118 @@
 package parser
 
 import (
-	"fmt"
+	"strings"
 
 	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/lexer"
 	"github.com/SHyx0rmZ/cgen/token"
 )
 
 func (p *parser) parsePrimaryExpr() ast.Expr {
-	x := p.parseOperand()
-	return x
-}
+	defer un(trace(p, "PrimaryExpr"))
 
-func (p *parser) parseUnaryExpr() ast.Expr {
-	switch p.peekNonSpace().Tok {
-	case token.INT:
-		number := p.next()
+	switch t := p.peekNonSpace(); t.Tok {
+	case token.INT, token.FLOAT, token.CHAR, token.STRING:
+		p.next()
 		return &ast.BasicLit{
-			ValuePos: number.Pos,
-			Kind:     token.INT,
-			Value:    number.Val,
+			ValuePos: t.Pos,
+			Kind:     t.Tok,
+			Value:    t.Val,
+			Suffix:   suffix(t),
 		}
 	case token.IDENT:
-		identifier := p.next()
+		if p.directive && t.Val == "defined" {
+			return p.parseDefinedExpr()
+		}
+		p.next()
+		x := &ast.Ident{
+			NamePos: t.Pos,
+			Name:    t.Val,
+		}
+		p.resolve(x)
+		return x
+	case token.ATTRIBUTE:
+		// An attribute specifier in a macro definition reads like a
+		// call.
+		p.next()
 		return &ast.Ident{
-			NamePos: identifier.Pos,
-			Name:    identifier.Val,
+			NamePos: t.Pos,
+			Name:    t.Val,
+		}
+	case token.ILLEGAL:
+		p.errorf("%s", t.Val)
+	}
+	return p.parseOperand()
+}
+
+// parsePostfixExpr parses the subscripts, calls, member accesses and
+// postfix increments following the operand x.
+func (p *parser) parsePostfixExpr(x ast.Expr) ast.Expr {
+	defer un(trace(p, "PostfixExpr"))
+
+	for {
+		switch t := p.peekNonSpace(); t.Tok {
+		case token.LBRACK:
+			p.next()
+			index := p.parseExpr()
+			closing := p.expect(token.RBRACK, "index expression")
+			x = &ast.IndexExpr{
+				X:       x,
+				Opening: t.Pos,
+				Index:   index,
+				Closing: closing.Pos,
+			}
+		case token.LPAREN:
+			x = p.parseCallExpr(x)
+		case token.PERIOD, token.ARROW:
+			p.next()
+			sel := p.expect(token.IDENT, "selector expression")
+			x = &ast.SelectorExpr{
+				X:     x,
+				OpPos: t.Pos,
+				Op:    t.Tok,
+				Sel: &ast.Ident{
+					NamePos: sel.Pos,
+					Name:    sel.Val,
+				},
+			}
+		case token.INC, token.DEC:
+			p.next()
+			x = &ast.PostfixExpr{
+				X:     x,
+				OpPos: t.Pos,
+				Op:    t.Tok,
+			}
+		default:
+			return x
+		}
+	}
+}
+
+// parseCallExpr parses the argument list of a call of fun.
+func (p *parser) parseCallExpr(fun ast.Expr) *ast.CallExpr {
+	call := &ast.CallExpr{
+		Fun:     fun,
+		Opening: p.expect(token.LPAREN, "argument list").Pos,
+	}
+	if p.peekNonSpace().Tok != token.RPAREN {
+		for {
+			call.Args = append(call.Args, p.parseAssignExpr())
+			if p.peekNonSpace().Tok != token.COMMA {
+				break
+			}
+			p.next()
 		}
-	//case token.ADD, token.SUB, token.AND, token.OR, token.XOR:
-	case token.SUB:
+	}
+	call.Closing = p.expect(token.RPAREN, "argument list").Pos
+	return call
+}
+
+func (p *parser) parseUnaryExpr() ast.Expr {
+	defer un(trace(p, "UnaryExpr"))
+
+	switch t := p.peekNonSpace(); t.Tok {
+	case token.ADD, token.SUB, token.NOT, token.TILDE, token.MUL, token.AND, token.INC, token.DEC:
 		operator := p.next()
 		expr := p.parseUnaryExpr()
 		return &ast.UnaryExpr{
@@ -36,30 +120,52 @@ func (p *parser) parseUnaryExpr() ast.Expr {
 			Op:    operator.Tok,
 			X:     expr,
 		}
+	case token.SIZEOF:
+		return p.parseSizeofExpr()
 	case token.LPAREN:
-		//opening := p.next()
-		//expr := p.parseExpr()
-		//fmt.Printf("%#v\n", expr)
-		//closing := p.expect(token.RPAREN, "parentheses expression")
-		//return &ast.ParenExpr{
-		//	Opening: opening.Pos,
-		//	Expr:    expr,
-		//	Closing: closing.Pos,
-		//}
-		return p.parseParenExpr()
+		// A parenthesized type name starts a cast, anything else a
+		// parenthesized expression.
+		opening := p.next()
+		if !p.isTypeName(p.peekNonSpace()) {
+			return p.parsePostfixExpr(p.parseParenExpr(opening))
+		}
+		typ := p.parseTypeName("cast expression")
+		closing := p.expect(token.RPAREN, "cast expression")
+		return &ast.CastExpr{
+			Opening: opening.Pos,
+			Type:    typ,
+			Closing: closing.Pos,
+			X:       p.parseUnaryExpr(),
+		}
 	}
+	return p.parsePostfixExpr(p.parsePrimaryExpr())
+}
 
-	fmt.Printf("B %s\n", p.peekNonSpace())
-
-	return p.parsePrimaryExpr()
+func (p *parser) parseSizeofExpr() ast.Expr {
+	keyword := p.expect(token.SIZEOF, "sizeof expression")
+	x := &ast.SizeofExpr{Sizeof: keyword.Pos}
+	if p.peekNonSpace().Tok != token.LPAREN {
+		x.X = p.parseUnaryExpr()
+		return x
+	}
+	opening := p.next()
+	if !p.isTypeName(p.peekNonSpace()) {
+		x.X = p.parsePostfixExpr(p.parseParenExpr(opening))
+		return x
+	}
+	x.Opening = opening.Pos
+	x.X = p.parseTypeName("sizeof expression")
+	x.Closing = p.expect(token.RPAREN, "sizeof expression").Pos
+	return x
 }
 
 func (p *parser) parseBinaryExpr(prec1 int) ast.Expr {
+	defer un(trace(p, "BinaryExpr"))
+
 	x := p.parseUnaryExpr()
 	for {
-		op := p.nextNonSpace()
+		op := p.peekNonSpace()
 		oprec := op.Tok.Precedence()
-		p.backup()
 		if oprec < prec1 {
 			return x
 		}
@@ -74,14 +180,72 @@ func (p *parser) parseBinaryExpr(prec1 int) ast.Expr {
 	}
 }
 
+// parseCondExpr parses a conditional expression, which is what C calls a
+// constant expression, e.g. the value of an enumerator.
+func (p *parser) parseCondExpr() ast.Expr {
+	defer un(trace(p, "CondExpr"))
+
+	x := p.parseBinaryExpr(token.LowestPrec + 1)
+	question := p.peekNonSpace()
+	if question.Tok != token.QUESTION {
+		return x
+	}
+	p.next()
+	y := p.parseExpr()
+	colon := p.expect(token.COLON, "conditional expression")
+	return &ast.CondExpr{
+		Cond:     x,
+		Question: question.Pos,
+		X:        y,
+		Colon:    colon.Pos,
+		Y:        p.parseCondExpr(),
+	}
+}
+
+// parseAssignExpr parses an assignment expression. Assignments are right
+// associative.
+func (p *parser) parseAssignExpr() ast.Expr {
+	defer un(trace(p, "AssignExpr"))
+
+	x := p.parseCondExpr()
+	op := p.peekNonSpace()
+	if !op.Tok.IsAssign() {
+		return x
+	}
+	p.next()
+	return &ast.BinaryExpr{
+		X:     x,
+		OpPos: op.Pos,
+		Op:    op.Tok,
+		Y:     p.parseAssignExpr(),
+	}
+}
+
+// parseExpr parses an expression, including the comma operator.
 func (p *parser) parseExpr() ast.Expr {
-	return p.parseBinaryExpr(1)
+	defer un(trace(p, "Expr"))
+
+	x := p.parseAssignExpr()
+	for {
+		op := p.peekNonSpace()
+		if op.Tok != token.COMMA {
+			return x
+		}
+		p.next()
+		x = &ast.BinaryExpr{
+			X:     x,
+			OpPos: op.Pos,
+			Op:    op.Tok,
+			Y:     p.parseAssignExpr(),
+		}
+	}
 }
 
-func (p *parser) parseParenExpr() ast.Expr {
-	opening := p.expect(token.LPAREN, "parentheses expression")
+// parseParenExpr parses a parenthesized expression after its opening
+// parenthesis.
+func (p *parser) parseParenExpr(opening lexer.Item) ast.Expr {
 	var expr ast.Expr
-	if p.peek().Tok != token.RPAREN {
+	if p.peekNonSpace().Tok != token.RPAREN {
 		expr = p.parseExpr()
 	}
 	closing := p.expect(token.RPAREN, "parentheses expression")
@@ -91,3 +255,18 @@ func (p *parser) parseParenExpr() ast.Expr {
 		Closing: closing.Pos,
 	}
 }
+
+// suffix returns the suffix of the integer or floating constant t. The
+// lexer only accepts valid suffixes, so they can be split off the end.
+func suffix(t lexer.Item) string {
+	var cutset string
+	switch t.Tok {
+	case token.INT:
+		cutset = "uUlL"
+	case token.FLOAT:
+		cutset = "fFlL"
+	default:
+		return ""
+	}
+	return t.Val[len(strings.TrimRight(t.Val, cutset)):]
+}
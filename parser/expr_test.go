@@ -3,7 +3,6 @@ package parser
 import (
 	"bytes"
 	"fmt"
-	goast "go/ast"
 	"reflect"
 	"testing"
 
@@ -59,6 +58,149 @@ func TestParser_ParseExpr(t *testing.T) {
 				},
 			},
 		},
+		{
+			"1 + 2 * 3",
+			[]ast.Node{
+				&ast.BinaryExpr{
+					X:     &ast.BasicLit{ValuePos: 0, Kind: token.INT, Value: "1"},
+					OpPos: 2,
+					Op:    token.ADD,
+					Y: &ast.BinaryExpr{
+						X:     &ast.BasicLit{ValuePos: 4, Kind: token.INT, Value: "2"},
+						OpPos: 6,
+						Op:    token.MUL,
+						Y:     &ast.BasicLit{ValuePos: 8, Kind: token.INT, Value: "3"},
+					},
+				},
+			},
+		},
+		{
+			"!1 ? ~2 : 3",
+			[]ast.Node{
+				&ast.CondExpr{
+					Cond: &ast.UnaryExpr{
+						OpPos: 0,
+						Op:    token.NOT,
+						X:     &ast.BasicLit{ValuePos: 1, Kind: token.INT, Value: "1"},
+					},
+					Question: 3,
+					X: &ast.UnaryExpr{
+						OpPos: 5,
+						Op:    token.TILDE,
+						X:     &ast.BasicLit{ValuePos: 6, Kind: token.INT, Value: "2"},
+					},
+					Colon: 8,
+					Y:     &ast.BasicLit{ValuePos: 10, Kind: token.INT, Value: "3"},
+				},
+			},
+		},
+		{
+			"(unsigned long)-1",
+			[]ast.Node{
+				&ast.CastExpr{
+					Opening: 0,
+					Type:    &ast.BasicType{From: 1, To: 14, Name: "unsigned long"},
+					Closing: 14,
+					X: &ast.UnaryExpr{
+						OpPos: 15,
+						Op:    token.SUB,
+						X:     &ast.BasicLit{ValuePos: 16, Kind: token.INT, Value: "1"},
+					},
+				},
+			},
+		},
+		{
+			"*p->a[1]++",
+			[]ast.Node{
+				&ast.UnaryExpr{
+					OpPos: 0,
+					Op:    token.MUL,
+					X: &ast.PostfixExpr{
+						X: &ast.IndexExpr{
+							X: &ast.SelectorExpr{
+								X:     &ast.Ident{NamePos: 1, Name: "p"},
+								OpPos: 2,
+								Op:    token.ARROW,
+								Sel:   &ast.Ident{NamePos: 4, Name: "a"},
+							},
+							Opening: 5,
+							Index:   &ast.BasicLit{ValuePos: 6, Kind: token.INT, Value: "1"},
+							Closing: 7,
+						},
+						OpPos: 8,
+						Op:    token.INC,
+					},
+				},
+			},
+		},
+		{
+			"sizeof(char *) - sizeof x",
+			[]ast.Node{
+				&ast.BinaryExpr{
+					X: &ast.SizeofExpr{
+						Sizeof:  0,
+						Opening: 6,
+						X: &ast.PointerType{
+							Star: 12,
+							Elem: &ast.BasicType{From: 7, To: 11, Name: "char"},
+						},
+						Closing: 13,
+					},
+					OpPos: 15,
+					Op:    token.SUB,
+					Y: &ast.SizeofExpr{
+						Sizeof: 17,
+						X:      &ast.Ident{NamePos: 24, Name: "x"},
+					},
+				},
+			},
+		},
+		{
+			"(f)(1, 2), 3",
+			[]ast.Node{
+				&ast.BinaryExpr{
+					X: &ast.CallExpr{
+						Fun: &ast.ParenExpr{
+							Opening: 0,
+							Expr:    &ast.Ident{NamePos: 1, Name: "f"},
+							Closing: 2,
+						},
+						Opening: 3,
+						Args: []ast.Expr{
+							&ast.BasicLit{ValuePos: 4, Kind: token.INT, Value: "1"},
+							&ast.BasicLit{ValuePos: 7, Kind: token.INT, Value: "2"},
+						},
+						Closing: 8,
+					},
+					OpPos: 9,
+					Op:    token.COMMA,
+					Y:     &ast.BasicLit{ValuePos: 11, Kind: token.INT, Value: "3"},
+				},
+			},
+		},
+		{
+			"1.5f * 'a' + 10UL",
+			[]ast.Node{
+				&ast.BinaryExpr{
+					X: &ast.BinaryExpr{
+						X:     &ast.BasicLit{ValuePos: 0, Kind: token.FLOAT, Value: "1.5f", Suffix: "f"},
+						OpPos: 5,
+						Op:    token.MUL,
+						Y:     &ast.BasicLit{ValuePos: 7, Kind: token.CHAR, Value: "'a'"},
+					},
+					OpPos: 11,
+					Op:    token.ADD,
+					Y:     &ast.BasicLit{ValuePos: 13, Kind: token.INT, Value: "10UL", Suffix: "UL"},
+				},
+			},
+		},
+		{
+			`u8"\"x" L'\''`,
+			[]ast.Node{
+				&ast.BasicLit{ValuePos: 0, Kind: token.STRING, Value: `u8"\"x"`},
+				&ast.BasicLit{ValuePos: 8, Kind: token.CHAR, Value: `L'\''`},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -68,9 +210,9 @@ func TestParser_ParseExpr(t *testing.T) {
 
 			if !reflect.DeepEqual(actual, test.Value) {
 				bufGot := new(bytes.Buffer)
-				goast.Fprint(bufGot, nil, actual, goast.NotNilFilter)
+				ast.Fprint(bufGot, nil, actual, ast.NotNilFilter)
 				bufWant := new(bytes.Buffer)
-				goast.Fprint(bufWant, nil, test.Value, goast.NotNilFilter)
+				ast.Fprint(bufWant, nil, test.Value, ast.NotNilFilter)
 				t.Errorf("%s:\ngot:\n%swant:\n%s", parser.name, bufGot.String(), bufWant.String())
 			}
 		})
@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/lexer"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+// A Mode value is a set of flags (or 0). They control optional parser
+// functionality.
+type Mode uint
+
+const (
+	ParseComments     Mode = 1 << iota // collect comments and attach them to nodes
+	Trace                              // print a trace of parsed productions
+	SkipWhitespace                     // drop whitespace outside of directives while scanning
+	AllErrors                          // report all errors (not just the first 10 on different lines)
+	DeclarationErrors                  // report redefinitions of tags and enumerators
+	ResolveObjects                     // link identifiers to the objects they denote
+)
+
+// readSource converts src into a []byte if possible, or reads the file
+// filename if src is nil.
+func readSource(filename string, src interface{}) ([]byte, error) {
+	if src != nil {
+		switch s := src.(type) {
+		case string:
+			return []byte(s), nil
+		case []byte:
+			return s, nil
+		case *bytes.Buffer:
+			// is io.Reader, but src is already available in []byte form
+			if s != nil {
+				return s.Bytes(), nil
+			}
+		case io.Reader:
+			return ioutil.ReadAll(s)
+		}
+		return nil, errors.New("invalid source")
+	}
+	return ioutil.ReadFile(filename)
+}
+
+// ParseFile parses the source code of a single C header and returns the
+// corresponding ast.File node. The source code may be provided via the
+// filename of the source file, or via the src parameter.
+//
+// If src != nil, ParseFile parses the source from src and the filename
+// is only used when recording position information. The type of the
+// argument for the src parameter must be string, []byte, or io.Reader.
+// If src == nil, ParseFile parses the file specified by filename.
+//
+// The mode parameter controls the amount of functionality of the
+// parser. Position information is recorded in the file set fset, which
+// must not be nil. Directives are parsed, not evaluated; use package
+// preprocessor and NewParserFromLexer to parse preprocessed input.
+//
+// If the source couldn't be read, the returned File is nil and the error
+// indicates the specific failure. If the source was read but syntax
+// errors were found, the result is a partial File with BadDir, BadStmt
+// or BadExpr nodes representing the fragments of erroneous source code,
+// and the error is an ErrorList sorted by source position.
+func ParseFile(fset *token.FileSet, filename string, src interface{}, mode Mode) (*ast.File, error) {
+	if fset == nil {
+		panic("parser.ParseFile: no token.FileSet provided (fset == nil)")
+	}
+	text, err := readSource(filename, src)
+	if err != nil {
+		return nil, err
+	}
+
+	file := fset.AddFile(filename, -1, len(text))
+	p := NewParserFromLexer(filename, lexer.NewFileLexer(file, string(text)))
+	p.fset, p.file = fset, nil
+	p.SetMode(mode)
+	f := &ast.File{
+		Name:      filename,
+		Nodes:     p.Nodes(),
+		FileStart: token.Pos(file.Base()),
+		FileEnd:   token.Pos(file.Base() + file.Size()),
+	}
+	if mode&ParseComments != 0 {
+		f.Comments = p.comments
+	}
+	if mode&ResolveObjects != 0 {
+		f.Scope, f.Unresolved = p.scope, p.unresolved
+	}
+	return f, p.Err()
+}
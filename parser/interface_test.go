@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+func TestParseFile(t *testing.T) {
+	src := "// Size.\n#define SIZE 4 // bytes\nstruct s { int x; };\n"
+	tests := []struct {
+		Mode     Mode
+		Comments int
+		Doc      string
+	}{
+		{0, 0, ""},
+		{ParseComments, 2, "Size.\n"},
+		{ParseComments | SkipWhitespace, 2, "Size.\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d", test.Mode), func(t *testing.T) {
+			fset := token.NewFileSet()
+			f, err := ParseFile(fset, "test.h", src, test.Mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(f.Nodes) != 2 || len(f.Comments) != test.Comments {
+				t.Fatalf("got %d nodes and %d comments, want 2 nodes and %d comments", len(f.Nodes), len(f.Comments), test.Comments)
+			}
+			if doc := f.Nodes[0].(*ast.MacroDir).Doc.Text(); doc != test.Doc {
+				t.Errorf("got doc %q, want %q", doc, test.Doc)
+			}
+			if pos := fset.Position(f.Nodes[1].Pos()); pos.String() != "test.h:3:1" {
+				t.Errorf("got position %s, want test.h:3:1", pos)
+			}
+		})
+	}
+}
+
+func TestParseFile_Errors(t *testing.T) {
+	src := strings.Repeat("int 1;\n", 12) + "enum e { A };\nenum e { A };\n"
+	tests := []struct {
+		Mode   Mode
+		Errors []string
+	}{
+		{0, []string{"test.h:10:5"}},
+		{AllErrors, []string{"test.h:12:5"}},
+		{AllErrors | DeclarationErrors, []string{"test.h:12:5", "test.h:14:6", "test.h:14:10"}},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%d", test.Mode), func(t *testing.T) {
+			fset := token.NewFileSet()
+			f, err := ParseFile(fset, "test.h", src, test.Mode)
+			list, ok := err.(ErrorList)
+			if !ok {
+				t.Fatalf("got error %v, want an ErrorList", err)
+			}
+			// parsing always continues to the end of the file
+			if pos := fset.Position(f.Nodes[len(f.Nodes)-1].Pos()); pos.Line != 14 {
+				t.Errorf("got last node at %s, want test.h:14", pos)
+			}
+			// only the last errors differ between the modes
+			var got []string
+			for _, e := range list[len(list)-len(test.Errors):] {
+				got = append(got, e.Pos.String())
+			}
+			if !reflect.DeepEqual(got, test.Errors) {
+				t.Errorf("got errors at %v, want %v", got, test.Errors)
+			}
+		})
+	}
+}
+
+func TestParseFile_IllegalCharacter(t *testing.T) {
+	f, err := ParseFile(token.NewFileSet(), "test.h", "int @x;\nint y;\n", AllErrors)
+	want := `cgen: test.h:1:5: unexpected unknown character '@' in declaration`
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %s", err, want)
+	}
+	if d, ok := f.Nodes[len(f.Nodes)-1].(*ast.VarDecl); !ok || d.Name.Name != "y" {
+		t.Errorf("got last node %#v, want the declaration of y", f.Nodes[len(f.Nodes)-1])
+	}
+}
+
+func TestParser_Trace(t *testing.T) {
+	p := NewParser("test.h", "int x;")
+	p.SetMode(Trace)
+	buf := new(bytes.Buffer)
+	p.SetTraceOutput(buf)
+	p.Nodes()
+
+	want := "    1:  1: Decl (\n    1:  6: )\n"
+	if buf.String() != want {
+		t.Errorf("got trace:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
@@ -2,7 +2,9 @@ package parser
 
 import (
 	"fmt"
-	//"strings"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/SHyx0rmZ/cgen/ast"
 	"github.com/SHyx0rmZ/cgen/lexer"
@@ -14,26 +16,130 @@ type parser struct {
 	token     [3]lexer.Item
 	peekCount int
 	name      string
-	indent    int
-	trace     bool
+	mode      Mode      // parsing mode
+	traceOut  io.Writer // destination of the trace output
+	indent    int       // indentation used for tracing output
+	directive bool      // whether a newline ends the current construct
+	cblocks   int       // number of open extern "C" blocks
 
-	pos   token.Pos
-	tok   token.Token
-	lit   string
-	error chan error
+	// Declarations
+	scope      *ast.Scope    // objects declared so far
+	macro      *ast.MacroDir // macro definition being parsed; or nil
+	unresolved []*ast.Ident  // identifiers that could not be resolved
+	deferred   []*ast.Ident  // unresolved identifiers in macro definitions
+
+	fset *token.FileSet // or nil, if positions are resolved through file
+	file *token.File    // or nil
+
+	// Comments
+	comments    []*ast.CommentGroup
+	leadComment *ast.CommentGroup // last lead comment
+	lineComment *ast.CommentGroup // last line comment
+	group       *ast.CommentGroup // comment group being collected; or nil
+	groupLine   bool              // whether group trails a token on its line
+	groupEnd    int               // line of the end of group
+	prevLine    int               // line of the end of the previous token
+	lineDone    bool              // whether lineComment trails the previous token
+
+	pos    token.Pos
+	tok    token.Token
+	lit    string
+	errors ErrorList
 }
 
+// NewParser returns a parser for input in ParseComments mode.
 func NewParser(name, input string) *parser {
-	return &parser{lex: lexer.NewLexer(name, input), name: name, trace: true, error: make(chan error, 1)}
+	return NewParserFromLexer(name, lexer.NewLexer(name, input))
+}
+
+// NewParserFromLexer returns a parser reading the items produced by lex,
+// for example the output of a preprocessor. If lex has a FileSet or a
+// File method, like a Preprocessor or the lexers of package lexer, it is
+// used to report positions as file:line:column.
+//
+// The parser starts in ParseComments mode and traces to os.Stderr once
+// Trace is set.
+func NewParserFromLexer(name string, lex lexer.Lexer) *parser {
+	p := &parser{lex: lex, name: name, mode: ParseComments, traceOut: os.Stderr, scope: ast.NewScope()}
+	switch l := lex.(type) {
+	case interface{ FileSet() *token.FileSet }:
+		p.fset = l.FileSet()
+	case interface{ File() *token.File }:
+		p.file = l.File()
+	}
+	return p
+}
+
+// position returns the Position of pos. Without any file information,
+// only the name of the parser is known.
+func (p *parser) position(pos token.Pos) token.Position {
+	switch {
+	case p.fset != nil:
+		if f := p.fset.File(pos); f != nil {
+			return f.Position(pos)
+		}
+	case p.file != nil:
+		if int(pos) >= p.file.Base() && int(pos) <= p.file.Base()+p.file.Size() {
+			return p.file.Position(pos)
+		}
+	}
+	return token.Position{Filename: p.name}
 }
 
+// SetMode sets the mode of p. It must be called before the first node is
+// parsed.
+func (p *parser) SetMode(mode Mode) {
+	p.mode = mode
+}
+
+// SetTraceOutput sets the destination of the trace printed in Trace mode.
+func (p *parser) SetTraceOutput(w io.Writer) {
+	p.traceOut = w
+}
+
+// ParseExpr parses the items produced by lex as a single expression, for
+// example the condition of an #if directive.
+func ParseExpr(name string, lex lexer.Lexer) (x ast.Expr, err error) {
+	p := NewParserFromLexer(name, lex)
+	p.mode = 0
+	p.directive = true
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
+			x, err = nil, p.errors.Err()
+		}
+	}()
+	x = p.parseExpr()
+	if t := p.peekNonSpace(); !p.atLineEnd(t) {
+		p.unexpected(t, "expression")
+	}
+	return x, nil
+}
+
+// Err returns the syntax errors found so far as an ErrorList sorted by
+// position, or nil if there were none. Unless in AllErrors mode, only
+// the first 10 errors are returned. It should be called once all nodes
+// were read.
 func (p *parser) Err() error {
-	select {
-	case err := <-p.error:
-		return err
-	default:
-		return nil
+	p.errors.Sort()
+	if p.mode&AllErrors == 0 && len(p.errors) > 10 {
+		return p.errors[:10]
 	}
+	return p.errors.Err()
+}
+
+// Scope returns the objects declared by the nodes read so far.
+func (p *parser) Scope() *ast.Scope {
+	return p.scope
+}
+
+// Unresolved returns the identifiers that could not be resolved in
+// ResolveObjects mode, sorted by position. It should be called once all
+// nodes were read.
+func (p *parser) Unresolved() []*ast.Ident {
+	return p.unresolved
 }
 
 func (p *parser) Nodes() []ast.Node {
@@ -49,63 +155,147 @@ func (p *parser) Parse() chan ast.Node {
 		token.ENDIF:   func() ast.Node { return &ast.EndIfDir{DirPos: p.next().Pos} },
 		token.ELSE:    func() ast.Node { return &ast.ElseDir{DirPos: p.next().Pos} },
 		token.DEFINE:  func() ast.Node { return p.parseMacroDir() },
+		token.UNDEF:   func() ast.Node { return p.parseUndefDir() },
+		token.IF:      func() ast.Node { return p.parseIfDir() },
+		token.ELIF:    func() ast.Node { return p.parseIfDir() },
 		token.INCLUDE: func() ast.Node { return p.parseIncludeDir() },
+		token.PRAGMA:  func() ast.Node { return p.parsePragmaDir() },
 		token.IFDEF:   func() ast.Node { return p.parseIfDefDir(ast.DEFINED) },
 		token.IFNDEF:  func() ast.Node { return p.parseIfDefDir(ast.NOT_DEFINED) },
-		token.COMMENT: func() ast.Node {
-			comment := p.next()
-			return &ast.Comment{
-				Slash: comment.Pos,
-				Text:  comment.Val,
-			}
-		},
-		token.EXTERN: func() ast.Node { return p.parseExternDecl() },
 	}
 	c := make(chan ast.Node)
 	go func() {
 		defer close(c)
-		for {
-			i := p.peek()
-			f, ok := m[i.Tok]
-			if ok {
-				c <- f()
-				continue
-			}
-			switch i.Tok {
-			case token.EOF:
-				return
-			case token.ILLEGAL:
-				p.errorf(i.Val)
-			//case lexer.ItemIdentifier:
-			//if i.Val == "typedef" {
-			//case lexer.ItemSpace:
-			//	if strings.Contains(i.Val, "\n") {
-			//		continue
-			//	}
-			//	fallthrough
-			default:
-				c <- p.parseExpr()
+		for p.peekNonSpace().Tok != token.EOF {
+			for _, node := range p.parseNode(m) {
+				c <- node
 			}
 		}
+		p.resolveDeferred()
 	}()
 	return c
 }
 
+// bailout is the panic value unwinding the parser after a syntax error.
+type bailout struct{}
+
+// parseNode parses the next directive, declaration or expression. After
+// a syntax error, the construct is skipped and replaced by a BadDir,
+// BadStmt or BadExpr node.
+func (p *parser) parseNode(m map[token.Token]func() ast.Node) (nodes []ast.Node) {
+	start := p.peekNonSpace()
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(bailout); !ok {
+				panic(r)
+			}
+			nodes = []ast.Node{p.sync(start)}
+		}
+	}()
+
+	if f, ok := m[start.Tok]; ok {
+		return []ast.Node{f()}
+	}
+	switch start.Tok {
+	case token.ILLEGAL:
+		p.errorf("%s", start.Val)
+	case token.RBRACE:
+		if p.cblocks == 0 {
+			nodes = append(nodes, p.parseExpr())
+			break
+		}
+		p.cblocks--
+		nodes = append(nodes, &ast.EndCDecl{Closing: p.next().Pos})
+	case token.EXTERN:
+		for _, decl := range p.parseExternDecl() {
+			nodes = append(nodes, decl)
+		}
+	case token.TYPEDEF, token.STRUCT, token.UNION, token.ENUM, token.STATIC, token.INLINE, token.CONST, token.VOLATILE, token.ATTRIBUTE, token.IDENT:
+		for _, decl := range p.parseDecl(p.leadComment) {
+			nodes = append(nodes, decl)
+		}
+	default:
+		nodes = append(nodes, p.parseExpr())
+	}
+	return nodes
+}
+
+// sync skips the rest of a construct starting with start that contains
+// a syntax error, and returns a placeholder node for it. A directive
+// extends to the end of its line. Anything else extends to the next
+// ";", to the "}" closing a block, or up to the next directive.
+func (p *parser) sync(start lexer.Item) ast.Node {
+	to := start.Pos
+	if isDirective(start.Tok) {
+		p.directive = true
+		defer func() { p.directive = false }()
+		if p.peekCount == 0 && p.atEOL(p.token[0]) {
+			// the line break was the unexpected token
+			return &ast.BadDir{From: start.Pos, To: p.token[0].Pos}
+		}
+		for t := p.peekNonSpace(); !p.atLineEnd(t); t = p.peekNonSpace() {
+			p.next()
+			to = token.Pos(int(t.Pos) + len(t.Val))
+		}
+		return &ast.BadDir{From: start.Pos, To: to}
+	}
+
+	depth := 0
+loop:
+	for {
+		t := p.peekNonSpace()
+		if t.Tok == token.EOF || isDirective(t.Tok) {
+			break
+		}
+		p.next()
+		to = token.Pos(int(t.Pos) + len(t.Val))
+		switch t.Tok {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			if depth > 0 {
+				if depth--; depth == 0 {
+					break loop
+				}
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				break loop
+			}
+		}
+	}
+	switch start.Tok {
+	case token.EXTERN, token.TYPEDEF, token.STRUCT, token.UNION, token.ENUM, token.STATIC, token.INLINE, token.CONST, token.VOLATILE, token.ATTRIBUTE, token.IDENT:
+		return &ast.BadStmt{From: start.Pos, To: to}
+	}
+	return &ast.BadExpr{From: start.Pos, To: to}
+}
+
+func isDirective(t token.Token) bool {
+	switch t {
+	case token.DEFINE, token.UNDEF, token.INCLUDE, token.PRAGMA, token.IF, token.ELIF, token.IFDEF, token.IFNDEF, token.ELSE, token.ENDIF:
+		return true
+	}
+	return false
+}
+
 func (p *parser) printTrace(a ...interface{}) {
 	const dots = ". . . . . . . . . . . . . . . . . . . . . . . . . . . . . . . . "
 	const n = len(dots)
-	fmt.Printf("%5d:???: ", p.pos)
+	pos := p.position(p.pos)
+	fmt.Fprintf(p.traceOut, "%5d:%3d: ", pos.Line, pos.Column)
 	i := 2 * p.indent
 	for i > n {
-		fmt.Print(dots)
+		fmt.Fprint(p.traceOut, dots)
 		i -= n
 	}
-	fmt.Print(dots[0:i])
-	fmt.Println(a...)
+	fmt.Fprint(p.traceOut, dots[0:i])
+	fmt.Fprintln(p.traceOut, a...)
 }
 
+// Usage pattern: defer un(trace(p, "..."))
 func trace(p *parser, msg string) *parser {
-	if p.trace {
+	if p.mode&Trace != 0 {
 		p.printTrace(msg, "(")
 		p.indent++
 	}
@@ -113,12 +303,13 @@ func trace(p *parser, msg string) *parser {
 }
 
 func un(p *parser) {
-	if p.trace {
+	if p.mode&Trace != 0 {
 		p.indent--
 		p.printTrace(")")
 	}
 }
 
+// parseOperand consumes a token that cannot start an expression.
 func (p *parser) parseOperand() ast.Expr {
 	/*switch p.tok {
 	case token.INT:
@@ -130,48 +321,97 @@ func (p *parser) parseOperand() ast.Expr {
 		p.next()
 		return x
 	}*/
+	t := p.peekNonSpace()
+	if t.Tok != token.EOF && !p.atEOL(t) {
+		p.next()
+	}
 	return &ast.BadExpr{
-		From: p.pos,
-		To:   p.pos,
+		From: t.Pos,
+		To:   token.Pos(int(t.Pos) + len(t.Val)),
 	}
 }
 
-/*
+// scan returns the next item of the lexer, with comments replaced by
+// spaces.
+//
+// Comments are collected into groups. A group on the line of the
+// preceding token, up to the end of that line, becomes the line comment
+// of that token. A group ending on the line of the following token or
+// on the line before becomes the lead comment of that token.
+func (p *parser) scan() lexer.Item {
+	for {
+		t := p.lex.NextItem()
+		switch t.Tok {
+		case token.COMMENT:
+			if p.mode&ParseComments != 0 {
+				p.addComment(t)
+			}
+			if p.skipWhitespace() {
+				continue
+			}
+			// a comment separates tokens like a space
+			return lexer.Item{Pos: t.Pos, Val: " ", Tok: token.WHITESPACE}
+		case token.WHITESPACE:
+			if p.group != nil && p.groupLine && strings.Contains(t.Val, "\n") {
+				p.lineComment, p.lineDone = p.group, true
+				p.group = nil
+			}
+			if p.skipWhitespace() {
+				continue
+			}
+			return t
+		}
 
-func (op Token) Precedence() int {
-	switch op {
-	case LOR:
-		return 1
-	case LAND:
-		return 2
-	case EQL, NEQ, LSS, LEQ, GTR, GEQ:
-		return 3
-	case ADD, SUB, OR, XOR:
-		return 4
-	case MUL, QUO, REM, SHL, SHR, AND, AND_NOT:
-		return 5
+		if !p.lineDone {
+			p.lineComment = nil
+		}
+		p.leadComment, p.lineDone = nil, false
+		if p.group != nil {
+			switch {
+			case p.groupLine && t.Tok == token.EOF:
+				p.lineComment = p.group
+			case !p.groupLine && p.groupEnd+1 >= p.line(t.Pos):
+				p.leadComment = p.group
+			}
+			p.group = nil
+		}
+		p.prevLine = p.line(token.Pos(int(t.Pos) + len(t.Val)))
+		return t
 	}
-	return LowestPrec
 }
-*/
 
-func (p *parser) tokPrec() (lexer.Item, token.Token, int) {
-	tok := p.nextNonSpace()
-	switch tok.Tok {
-	case token.SUB:
-		return tok, token.SUB, 4
-	case token.QUO:
-		return tok, token.QUO, 5
+// skipWhitespace reports whether scan drops whitespace. Whitespace is
+// significant in directives only.
+func (p *parser) skipWhitespace() bool {
+	return p.mode&SkipWhitespace != 0 && !p.directive
+}
+
+// addComment adds the comment t to the current comment group, or starts
+// a new group.
+func (p *parser) addComment(t lexer.Item) {
+	line, end := p.line(t.Pos), p.line(token.Pos(int(t.Pos)+len(t.Val)))
+	c := &ast.Comment{Slash: t.Pos, Text: t.Val}
+	switch {
+	case p.group != nil && p.groupLine && line == p.groupEnd,
+		p.group != nil && !p.groupLine && line <= p.groupEnd+1:
+		p.group.List = append(p.group.List, c)
+	default:
+		p.group = &ast.CommentGroup{List: []*ast.Comment{c}}
+		p.groupLine = p.prevLine > 0 && line == p.prevLine
+		p.comments = append(p.comments, p.group)
 	}
-	p.backup()
-	return tok, token.ILLEGAL, 0
+	p.groupEnd = end
+}
+
+func (p *parser) line(pos token.Pos) int {
+	return p.position(pos).Line
 }
 
 func (p *parser) next() lexer.Item {
 	if p.peekCount > 0 {
 		p.peekCount--
 	} else {
-		p.token[0] = p.lex.NextItem()
+		p.token[0] = p.scan()
 	}
 	p.pos, p.tok, p.lit = p.token[p.peekCount].Pos, token.INT, p.token[p.peekCount].Val
 	return p.token[p.peekCount]
@@ -186,7 +426,7 @@ func (p *parser) peek() lexer.Item {
 		return p.token[p.peekCount-1]
 	}
 	p.peekCount = 1
-	p.token[0] = p.lex.NextItem()
+	p.token[0] = p.scan()
 	return p.token[0]
 }
 
@@ -194,7 +434,7 @@ func (p *parser) nextNonSpace() lexer.Item {
 	var t lexer.Item
 	for {
 		t = p.next()
-		if t.Tok != token.WHITESPACE {
+		if t.Tok != token.WHITESPACE || p.atEOL(t) {
 			break
 		}
 	}
@@ -204,7 +444,7 @@ func (p *parser) nextNonSpace() lexer.Item {
 func (p *parser) peekNonSpace() (t lexer.Item) {
 	for {
 		t = p.next()
-		if t.Tok != token.WHITESPACE {
+		if t.Tok != token.WHITESPACE || p.atEOL(t) {
 			break
 		}
 	}
@@ -212,14 +452,43 @@ func (p *parser) peekNonSpace() (t lexer.Item) {
 	return t
 }
 
+// atEOL reports whether t is a line break terminating a directive.
+func (p *parser) atEOL(t lexer.Item) bool {
+	return p.directive && t.Tok == token.WHITESPACE && strings.Contains(t.Val, "\n")
+}
+
+// errorf records a syntax error at the current token and unwinds the
+// parser to the innermost construct that can recover from it.
 func (p *parser) errorf(format string, args ...interface{}) {
-	format = fmt.Sprintf("cgen: %s:%d: %s", p.name, p.token[0].Line, format)
-	go func() {
-		for {
-			p.error <- fmt.Errorf(format, args...)
+	p.error(p.token[0].Pos, fmt.Sprintf(format, args...))
+	panic(bailout{})
+}
+
+// error records an error at pos. Unless in AllErrors mode, an error on
+// the line of the previous error is likely spurious and dropped.
+func (p *parser) error(pos token.Pos, msg string) {
+	epos := p.position(pos)
+	if p.mode&AllErrors == 0 {
+		n := len(p.errors)
+		if n > 0 && p.errors[n-1].Pos.Filename == epos.Filename && p.errors[n-1].Pos.Line == epos.Line {
+			return
+		}
+	}
+	p.errors.Add(epos, msg)
+}
+
+// try calls f and reports whether it completed without a syntax error.
+func (p *parser) try(f func()) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, isBailout := r.(bailout); !isBailout {
+				panic(r)
+			}
+			ok = false
 		}
 	}()
-	panic(fmt.Errorf(format, args...))
+	f()
+	return true
 }
 
 func (p *parser) expect(expected token.Token, context string) lexer.Item {
@@ -3,11 +3,12 @@ package parser
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"bytes"
 	"github.com/SHyx0rmZ/cgen/ast"
-	goast "go/ast"
+	"github.com/SHyx0rmZ/cgen/lexer"
 )
 
 func TestParser_Parse(t *testing.T) {
@@ -23,11 +24,153 @@ func TestParser_Parse(t *testing.T) {
 
 			if !reflect.DeepEqual(actual, test.Value) {
 				bufGot := new(bytes.Buffer)
-				goast.Fprint(bufGot, nil, actual, goast.NotNilFilter)
+				ast.Fprint(bufGot, nil, actual, ast.NotNilFilter)
 				bufWant := new(bytes.Buffer)
-				goast.Fprint(bufWant, nil, test.Value, goast.NotNilFilter)
+				ast.Fprint(bufWant, nil, test.Value, ast.NotNilFilter)
 				t.Errorf("%s:\ngot:\n%swant:\n%s", parser.name, bufGot.String(), bufWant.String())
 			}
 		})
 	}
 }
+
+func TestParseExpr_Error(t *testing.T) {
+	tests := []struct {
+		Input string
+		Error string
+	}{
+		{
+			"(1 2)",
+			`cgen: test.h:1:4: unexpected INT("2") in parentheses expression`,
+		},
+		{
+			"(1 \\\r\n\\\n  2)",
+			`cgen: test.h:3:3: unexpected INT("2") in parentheses expression`,
+		},
+		{
+			"1 \\\n )",
+			`cgen: test.h:2:2: unexpected )(")") in expression`,
+		},
+		{
+			"1 + 08",
+			`cgen: test.h:1:5: invalid digit in octal constant "08"`,
+		},
+		{
+			"0x1.8 + 1",
+			`cgen: test.h:1:1: hexadecimal floating constant requires an exponent`,
+		},
+		{
+			"10lu + 1uu",
+			`cgen: test.h:1:8: invalid suffix on constant "1uu"`,
+		},
+		{
+			"'a",
+			`cgen: test.h:1:1: unterminated character constant`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s", test.Input), func(t *testing.T) {
+			_, err := ParseExpr("test.h", lexer.NewLexer("test.h", test.Input))
+			if err == nil || err.Error() != test.Error {
+				t.Errorf("got error %v, want %s", err, test.Error)
+			}
+		})
+	}
+}
+
+func TestParser_ParseErrors(t *testing.T) {
+	input := "int a;\nint b c;\n#define X (\n#undef 1\nstruct s { int x; int 3; int y; };\nint d;\n"
+	parser := NewParser("test.h", input)
+	nodes := parser.Nodes()
+
+	var types []string
+	for _, node := range nodes {
+		types = append(types, fmt.Sprintf("%T", node))
+	}
+	wantTypes := []string{"*ast.VarDecl", "*ast.BadStmt", "*ast.MacroDir", "*ast.BadDir", "*ast.StructDecl", "*ast.VarDecl"}
+	if !reflect.DeepEqual(types, wantTypes) {
+		t.Fatalf("got nodes %v, want %v", types, wantTypes)
+	}
+	// a body that isn't an expression is no error
+	if bad, ok := nodes[2].(*ast.MacroDir).Value.(*ast.BadExpr); !ok || bad.From != 26 || bad.To != 27 {
+		t.Errorf("got macro value %#v, want BadExpr from 26 to 27", nodes[2].(*ast.MacroDir).Value)
+	}
+	fields := nodes[4].(*ast.StructDecl).Type.(*ast.StructType).Fields.List
+	if len(fields) != 3 || fields[2].Name.Name != "y" {
+		t.Errorf("got %d fields, want x, a bad field and y", len(fields))
+	}
+	if bad, ok := fields[1].Type.(*ast.BadExpr); !ok || bad.From != 55 || bad.To != 61 {
+		t.Errorf("got field %#v, want BadExpr from 55 to 61", fields[1].Type)
+	}
+
+	err, ok := parser.Err().(ErrorList)
+	if !ok {
+		t.Fatalf("got error %v, want an ErrorList", parser.Err())
+	}
+	want := []string{
+		`cgen: test.h:2:7: unexpected IDENT("c") in declaration`,
+		`cgen: test.h:4:8: unexpected INT("1") in undef directive`,
+		`cgen: test.h:5:23: unexpected INT("3") in struct type`,
+	}
+	var got []string
+	for _, e := range err {
+		got = append(got, e.Error())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got errors\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestParser_ParseComments(t *testing.T) {
+	input := `// Size of a buffer.
+#define SIZE 16 /* bytes */
+
+struct buf {
+	/* Number of bytes used. */
+	int len; // <= SIZE
+	// unused
+
+	char data[SIZE];
+};
+
+enum mode {
+	// Reading.
+	READ = 1, // r
+	WRITE /* w */
+};
+
+/// Frees b.
+void free_buf(struct buf *b);
+`
+	parser := NewParser("test.h", input)
+	nodes := parser.Nodes()
+	if err := parser.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	text := func(g *ast.CommentGroup) string { return g.Text() }
+	macro := nodes[0].(*ast.MacroDir)
+	fields := nodes[1].(*ast.StructDecl).Type.(*ast.StructType).Fields.List
+	specs := nodes[2].(*ast.StructDecl).Type.(*ast.EnumDecl).Specs
+	fun := nodes[3].(*ast.FuncDecl)
+	tests := []struct {
+		Name  string
+		Got   string
+		Value string
+	}{
+		{"macro doc", text(macro.Doc), "Size of a buffer.\n"},
+		{"macro comment", text(macro.Comment), "bytes\n"},
+		{"field doc", text(fields[0].Doc), "Number of bytes used.\n"},
+		{"field comment", text(fields[0].Comment), "<= SIZE\n"},
+		{"detached comment", text(fields[1].Doc), ""},
+		{"enum doc", text(specs[0].(*ast.EnumValue).Doc), "Reading.\n"},
+		{"enum comment", text(specs[0].(*ast.EnumValue).Comment), "r\n"},
+		{"last enum comment", text(specs[1].(*ast.EnumValue).Comment), "w\n"},
+		{"func doc", text(fun.Doc), "Frees b.\n"},
+	}
+	for _, test := range tests {
+		if test.Got != test.Value {
+			t.Errorf("%s: got %q, want %q", test.Name, test.Got, test.Value)
+		}
+	}
+}
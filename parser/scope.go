@@ -0,0 +1,181 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+)
+
+// declare declares name as an object of kind, declared by decl, in the
+// scope of p. A later declaration replaces an earlier one, except that
+// the redefinition of an enumerator is an error.
+func (p *parser) declare(decl interface{}, kind ast.ObjKind, name *ast.Ident) {
+	obj := ast.NewObj(kind, name.Name)
+	obj.Decl = decl
+	if alt := p.scope.Insert(obj); alt != nil {
+		if kind == ast.Con && alt.Kind == ast.Con {
+			p.redefinition("enumerator ", name, alt)
+		} else {
+			p.scope.Replace(obj)
+		}
+	}
+	if p.mode&ResolveObjects != 0 {
+		name.Obj = obj
+	}
+}
+
+// declareTag declares the tag name of the struct, union or enum type
+// typ. A reference to a tag that is not declared yet declares it as an
+// incomplete type; its definition completes the object, so all
+// references to the tag share the object.
+func (p *parser) declareTag(typ interface{}, keyword string, name *ast.Ident, def bool) {
+	obj := p.scope.LookupTag(name.Name)
+	switch {
+	case obj == nil:
+		obj = ast.NewObj(ast.Tag, name.Name)
+		obj.Decl = typ
+		p.scope.Insert(obj)
+	case def && isDefinition(obj.Decl):
+		p.redefinition(keyword, name, obj)
+	case def:
+		obj.Decl = typ
+	}
+	if p.mode&ResolveObjects != 0 {
+		name.Obj = obj
+	}
+}
+
+// isDefinition reports whether the struct, union or enum type typ has
+// a body.
+func isDefinition(typ interface{}) bool {
+	switch typ := typ.(type) {
+	case *ast.StructType:
+		return typ.Fields != nil
+	case *ast.EnumDecl:
+		return typ.Opening.IsValid()
+	}
+	return false
+}
+
+// declareMacro declares the macro defined by dir, replacing an earlier
+// definition.
+func (p *parser) declareMacro(dir *ast.MacroDir) {
+	obj := ast.NewObj(ast.Mac, dir.Name.Name)
+	obj.Decl = dir
+	p.scope.Replace(obj)
+	if p.mode&ResolveObjects != 0 {
+		dir.Name.Obj = obj
+	}
+}
+
+// undefine removes the macro name from the scope of p.
+func (p *parser) undefine(name *ast.Ident) {
+	p.resolveMacro(name)
+	delete(p.scope.Macros, name.Name)
+}
+
+// redefinition reports the redefinition of name in DeclarationErrors
+// mode.
+func (p *parser) redefinition(kind string, name *ast.Ident, prev *ast.Object) {
+	if p.mode&DeclarationErrors == 0 {
+		return
+	}
+	p.error(name.Pos(), fmt.Sprintf("redefinition of %s%s\n\tprevious definition at %s", kind, name.Name, p.position(prev.Pos())))
+}
+
+// resolve links the identifier x to the macro or the ordinary object it
+// refers to in ResolveObjects mode. Identifiers in a macro definition
+// may refer to objects declared later and are resolved again once the
+// input is read; the parameters of the macro are not resolved.
+func (p *parser) resolve(x *ast.Ident) {
+	if p.mode&ResolveObjects == 0 || p.lookup(x) {
+		return
+	}
+	if p.macro != nil {
+		if p.isMacroParam(x.Name) {
+			return
+		}
+		p.deferred = append(p.deferred, x)
+		return
+	}
+	p.unresolved = append(p.unresolved, x)
+}
+
+// resolveMacro links the identifier x to the macro it names in
+// ResolveObjects mode.
+func (p *parser) resolveMacro(x *ast.Ident) {
+	if p.mode&ResolveObjects == 0 {
+		return
+	}
+	if x.Obj = p.scope.LookupMacro(x.Name); x.Obj == nil {
+		p.unresolved = append(p.unresolved, x)
+	}
+}
+
+// lookup links x to the macro named x, or else to the ordinary object
+// named x, and reports whether there is one.
+func (p *parser) lookup(x *ast.Ident) bool {
+	if x.Obj = p.scope.LookupMacro(x.Name); x.Obj == nil {
+		x.Obj = p.scope.Lookup(x.Name)
+	}
+	return x.Obj != nil
+}
+
+// isMacroParam reports whether name is a parameter of the macro being
+// defined.
+func (p *parser) isMacroParam(name string) bool {
+	args := p.macro.Args
+	if args == nil {
+		return false
+	}
+	if args.Ellipsis != nil && name == "__VA_ARGS__" {
+		return true
+	}
+	for _, arg := range args.List {
+		if arg.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDeferred resolves the identifiers in macro definitions that
+// could not be resolved where they occurred.
+func (p *parser) resolveDeferred() {
+	for _, x := range p.deferred {
+		if !p.lookup(x) {
+			p.unresolved = append(p.unresolved, x)
+		}
+	}
+	p.deferred = nil
+	sort.Slice(p.unresolved, func(i, j int) bool {
+		return p.unresolved[i].Pos() < p.unresolved[j].Pos()
+	})
+}
+
+// isTypedefName reports whether the identifier name denotes a type: a
+// typedef name, an object-like macro expanding to a type name, or one
+// of the knownTypes that is not declared otherwise.
+func (p *parser) isTypedefName(name string) bool {
+	// a macro may expand to another macro, but not to itself
+	for i := 0; i <= len(p.scope.Macros); i++ {
+		if obj := p.scope.LookupMacro(name); obj != nil {
+			dir := obj.Decl.(*ast.MacroDir)
+			x, ok := dir.Value.(*ast.Ident)
+			if !ok || dir.Args != nil {
+				return false
+			}
+			if builtinTypes[x.Name] {
+				return true
+			}
+			name = x.Name
+			continue
+		}
+		if obj := p.scope.Lookup(name); obj != nil {
+			return obj.Kind == ast.Typ
+		}
+		return knownTypes[name]
+	}
+	return false
+}
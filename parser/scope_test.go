@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+func TestParseFile_ResolveObjects(t *testing.T) {
+	src := `typedef struct foo foo_t;
+struct foo { foo_t *next; };
+#define N (M + 1)
+#define M 2
+#ifdef DEBUG
+#endif
+int f(foo_t x);
+#undef N
+`
+	f, err := ParseFile(token.NewFileSet(), "test.h", src, ResolveObjects)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typedef := f.Nodes[0].(*ast.TypeDecl)
+	def := f.Nodes[1].(*ast.StructDecl).Type.(*ast.StructType)
+	if obj := typedef.Name.Obj; obj == nil || obj.Kind != ast.Typ || obj.Decl != typedef {
+		t.Errorf("got object %v for foo_t, want the typedef", obj)
+	}
+	tag := typedef.Type.(*ast.StructType).Name.Obj
+	if tag == nil || tag != def.Name.Obj || tag.Decl != def {
+		t.Errorf("got object %v for struct foo, want its definition", tag)
+	}
+	field := def.Fields.List[0].Type.(*ast.PointerType).Elem.(*ast.Ident)
+	if field.Obj != typedef.Name.Obj {
+		t.Errorf("got object %v for the member type, want foo_t", field.Obj)
+	}
+	m := f.Nodes[2].(*ast.MacroDir).Value.(*ast.ParenExpr).Expr.(*ast.BinaryExpr).X.(*ast.Ident)
+	if m.Obj == nil || m.Obj.Decl != f.Nodes[3] {
+		t.Errorf("got object %v for M, want the macro defined later", m.Obj)
+	}
+	if undef := f.Nodes[7].(*ast.UndefDir).Name; undef.Obj == nil || undef.Obj.Decl != f.Nodes[2] {
+		t.Errorf("got object %v for #undef N, want the macro", undef.Obj)
+	}
+
+	var names []string
+	for _, objects := range []map[string]*ast.Object{f.Scope.Objects, f.Scope.Tags, f.Scope.Macros} {
+		for name, obj := range objects {
+			names = append(names, fmt.Sprintf("%s %s", obj.Kind, name))
+		}
+	}
+	sort.Strings(names)
+	want := []string{"func f", "macro M", "tag foo", "type foo_t"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got scope %v, want %v", names, want)
+	}
+	if len(f.Unresolved) != 1 || f.Unresolved[0].Name != "DEBUG" {
+		t.Errorf("got unresolved %v, want DEBUG", f.Unresolved)
+	}
+}
+
+func TestParser_TypedefNames(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value string
+	}{
+		{"typedef int T;\n#define X (T)-1", "*ast.CastExpr"},
+		{"int T;\n#define X (T)-1", "*ast.BinaryExpr"},
+		{"#define BOOL int\n#define X (BOOL)-1", "*ast.CastExpr"},
+		{"#define BYTE u8\ntypedef char u8;\n#define X (BYTE)-1", "*ast.CastExpr"},
+		{"#define BOOL int\n#undef BOOL\n#define X (BOOL)-1", "*ast.BinaryExpr"},
+		{"#define F(x) int\n#define X (F)-1", "*ast.BinaryExpr"},
+		{"#define X (size_t)-1", "*ast.CastExpr"},
+		{"enum { size_t };\n#define X (size_t)-1", "*ast.BinaryExpr"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			parser := NewParser("test.h", test.Input)
+			nodes := parser.Nodes()
+			if err := parser.Err(); err != nil {
+				t.Fatal(err)
+			}
+			x := nodes[len(nodes)-1].(*ast.MacroDir).Value
+			if got := fmt.Sprintf("%T", x); got != test.Value {
+				t.Errorf("got %s, want %s", got, test.Value)
+			}
+		})
+	}
+}
@@ -0,0 +1,446 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/lexer"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+// builtinTypes contains the identifiers that make up the names of the
+// builtin C types.
+var builtinTypes = map[string]bool{
+	"void":     true,
+	"char":     true,
+	"short":    true,
+	"int":      true,
+	"long":     true,
+	"float":    true,
+	"double":   true,
+	"signed":   true,
+	"unsigned": true,
+	"_Bool":    true,
+	"_Complex": true,
+}
+
+// knownTypes contains the names of common typedefs of the standard
+// headers. They are recognized as types even if the header declaring
+// them is not parsed, so casts in macro definitions can be told apart
+// from parenthesized expressions.
+var knownTypes = map[string]bool{
+	"int8_t":    true,
+	"int16_t":   true,
+	"int32_t":   true,
+	"int64_t":   true,
+	"uint8_t":   true,
+	"uint16_t":  true,
+	"uint32_t":  true,
+	"uint64_t":  true,
+	"intptr_t":  true,
+	"uintptr_t": true,
+	"intmax_t":  true,
+	"uintmax_t": true,
+	"size_t":    true,
+	"ssize_t":   true,
+	"ptrdiff_t": true,
+	"wchar_t":   true,
+	"bool":      true,
+}
+
+// basicTypes maps every valid combination of type specifiers, sorted
+// alphabetically, to the canonical name of the type.
+var basicTypes = make(map[string]string)
+
+func init() {
+	for name, spellings := range map[string][]string{
+		"void":                 {"void"},
+		"_Bool":                {"_Bool"},
+		"char":                 {"char"},
+		"signed char":          {"signed char"},
+		"unsigned char":        {"unsigned char"},
+		"short":                {"short", "short int", "signed short", "signed short int"},
+		"unsigned short":       {"unsigned short", "unsigned short int"},
+		"int":                  {"int", "signed", "signed int"},
+		"unsigned int":         {"unsigned", "unsigned int"},
+		"long":                 {"long", "long int", "signed long", "signed long int"},
+		"unsigned long":        {"unsigned long", "unsigned long int"},
+		"long long":            {"long long", "long long int", "signed long long", "signed long long int"},
+		"unsigned long long":   {"unsigned long long", "unsigned long long int"},
+		"float":                {"float"},
+		"double":               {"double"},
+		"long double":          {"long double"},
+		"float _Complex":       {"float _Complex"},
+		"double _Complex":      {"double _Complex"},
+		"long double _Complex": {"long double _Complex"},
+	} {
+		for _, spelling := range spellings {
+			words := strings.Fields(spelling)
+			sort.Strings(words)
+			basicTypes[strings.Join(words, " ")] = name
+		}
+	}
+}
+
+// parseSpecifiers parses the declaration specifiers preceding a list of
+// declarators and returns the type they denote. Storage class specifiers
+// are skipped, and so are attributes, unless they follow a struct or
+// union type.
+func (p *parser) parseSpecifiers(context string) ast.Expr {
+	var typ ast.Expr
+	var words []lexer.Item
+	var qual ast.Qualifier
+	var qualPos token.Pos
+	for {
+		t := p.peekNonSpace()
+		switch t.Tok {
+		case token.EXTERN, token.STATIC, token.INLINE:
+			p.next()
+			continue
+		case token.ATTRIBUTE:
+			attr := p.parseAttribute()
+			if st, ok := typ.(*ast.StructType); ok {
+				st.Attrs = append(st.Attrs, attr)
+			}
+			continue
+		case token.CONST, token.VOLATILE, token.RESTRICT:
+			p.next()
+			if qual == 0 {
+				qualPos = t.Pos
+			}
+			qual |= qualifier(t.Tok)
+			continue
+		case token.STRUCT, token.UNION:
+			if typ != nil || words != nil {
+				p.unexpected(p.next(), context)
+			}
+			typ = p.parseStructType()
+			continue
+		case token.ENUM:
+			if typ != nil || words != nil {
+				p.unexpected(p.next(), context)
+			}
+			typ = p.parseEnumDecl()
+			continue
+		case token.IDENT:
+			switch {
+			case builtinTypes[t.Val]:
+				if typ != nil {
+					p.unexpected(p.next(), context)
+				}
+				words = append(words, p.next())
+				continue
+			case typ == nil && words == nil:
+				p.next()
+				name := &ast.Ident{
+					NamePos: t.Pos,
+					Name:    t.Val,
+				}
+				p.resolve(name)
+				typ = name
+				continue
+			}
+		}
+		break
+	}
+
+	if words != nil {
+		typ = p.parseBasicType(words)
+	}
+	if typ == nil {
+		p.unexpected(p.next(), context)
+	}
+	if qual != 0 {
+		typ = &ast.QualType{
+			QualPos: qualPos,
+			Qual:    qual,
+			Type:    typ,
+		}
+	}
+	return typ
+}
+
+// parseAttributes parses a possibly empty list of attribute specifiers.
+func (p *parser) parseAttributes() []*ast.Attribute {
+	var list []*ast.Attribute
+	for p.peekNonSpace().Tok == token.ATTRIBUTE {
+		list = append(list, p.parseAttribute())
+	}
+	return list
+}
+
+// parseAttribute parses an attribute specifier. The names of attributes
+// may be keywords, like const.
+func (p *parser) parseAttribute() *ast.Attribute {
+	defer un(trace(p, "Attribute"))
+
+	keyword := p.expect(token.ATTRIBUTE, "attribute")
+	p.expect(token.LPAREN, "attribute")
+	p.expect(token.LPAREN, "attribute")
+	attr := &ast.Attribute{Attr: keyword.Pos}
+	for p.peekNonSpace().Tok != token.RPAREN {
+		if len(attr.List) > 0 {
+			p.expect(token.COMMA, "attribute")
+		}
+		t := p.nextNonSpace()
+		if t.Tok != token.IDENT && token.Lookup(t.Val) != t.Tok {
+			p.unexpected(t, "attribute")
+		}
+		var x ast.Expr = &ast.Ident{
+			NamePos: t.Pos,
+			Name:    t.Val,
+		}
+		if p.peekNonSpace().Tok == token.LPAREN {
+			x = p.parseCallExpr(x)
+		}
+		attr.List = append(attr.List, x)
+	}
+	p.expect(token.RPAREN, "attribute")
+	attr.Closing = p.expect(token.RPAREN, "attribute").Pos
+	return attr
+}
+
+// isTypeName reports whether t starts a type name, as opposed to an
+// expression.
+func (p *parser) isTypeName(t lexer.Item) bool {
+	switch t.Tok {
+	case token.CONST, token.VOLATILE, token.RESTRICT, token.STRUCT, token.UNION, token.ENUM:
+		return true
+	case token.IDENT:
+		return builtinTypes[t.Val] || p.isTypedefName(t.Val)
+	}
+	return false
+}
+
+// parseTypeName parses a type name, as used in casts and sizeof
+// expressions: declaration specifiers followed by an abstract
+// declarator.
+func (p *parser) parseTypeName(context string) ast.Expr {
+	typ := p.parseSpecifiers(context)
+	name, typ := p.parseDeclarator(typ, context)
+	if name != nil {
+		p.unexpected(lexer.Item{Pos: name.NamePos, Val: name.Name, Tok: token.IDENT}, context)
+	}
+	return typ
+}
+
+func (p *parser) parseBasicType(words []lexer.Item) *ast.BasicType {
+	spelling := make([]string, len(words))
+	for i, w := range words {
+		spelling[i] = w.Val
+	}
+	sort.Strings(spelling)
+	name, ok := basicTypes[strings.Join(spelling, " ")]
+	if !ok {
+		p.errorf("invalid type specifiers: %s", strings.Join(spelling, " "))
+	}
+
+	last := words[len(words)-1]
+	return &ast.BasicType{
+		From: words[0].Pos,
+		To:   token.Pos(int(last.Pos) + len(last.Val)),
+		Name: name,
+	}
+}
+
+func qualifier(tok token.Token) ast.Qualifier {
+	switch tok {
+	case token.CONST:
+		return ast.CONST
+	case token.VOLATILE:
+		return ast.VOLATILE
+	}
+	return ast.RESTRICT
+}
+
+// parseQualifiers parses a possibly empty list of type qualifiers.
+func (p *parser) parseQualifiers() (ast.Qualifier, token.Pos) {
+	var qual ast.Qualifier
+	var pos token.Pos
+	for {
+		t := p.peekNonSpace()
+		switch t.Tok {
+		case token.CONST, token.VOLATILE, token.RESTRICT:
+			p.next()
+			if qual == 0 {
+				pos = t.Pos
+			}
+			qual |= qualifier(t.Tok)
+			continue
+		}
+		return qual, pos
+	}
+}
+
+// parseDeclarator parses a declarator for a declaration of type typ and
+// returns the declared name and type. The name is nil for abstract
+// declarators.
+func (p *parser) parseDeclarator(typ ast.Expr, context string) (*ast.Ident, ast.Expr) {
+	name, apply := p.parseDeclaratorFunc(context)
+	return name, apply(typ)
+}
+
+// A typeFunc derives a type from its base type.
+type typeFunc func(ast.Expr) ast.Expr
+
+// parseDeclaratorFunc parses a declarator without knowing its base type.
+// The returned function builds the declared type from the inside out:
+// pointers bind to the base type first, then array and function suffixes
+// from right to left, and finally any parenthesized inner declarator.
+func (p *parser) parseDeclaratorFunc(context string) (*ast.Ident, typeFunc) {
+	var pointers []typeFunc
+	for p.peekNonSpace().Tok == token.MUL {
+		star := p.next()
+		qual, qualPos := p.parseQualifiers()
+		pointers = append(pointers, func(x ast.Expr) ast.Expr {
+			x = &ast.PointerType{
+				Star: star.Pos,
+				Elem: x,
+			}
+			if qual != 0 {
+				x = &ast.QualType{
+					QualPos: qualPos,
+					Qual:    qual,
+					Type:    x,
+				}
+			}
+			return x
+		})
+	}
+
+	var name *ast.Ident
+	var inner typeFunc
+	var suffixes []typeFunc
+	switch t := p.peekNonSpace(); t.Tok {
+	case token.IDENT:
+		if builtinTypes[t.Val] {
+			break
+		}
+		p.next()
+		name = &ast.Ident{
+			NamePos: t.Pos,
+			Name:    t.Val,
+		}
+	case token.LPAREN:
+		opening := p.next()
+		if p.isNestedDeclarator() {
+			name, inner = p.parseDeclaratorFunc(context)
+			p.expect(token.RPAREN, context)
+			break
+		}
+		params := p.parseParamList(opening)
+		suffixes = append(suffixes, funcType(params))
+	}
+
+suffixes:
+	for {
+		switch p.peekNonSpace().Tok {
+		case token.LBRACK:
+			suffixes = append(suffixes, p.parseArraySuffix())
+		case token.LPAREN:
+			suffixes = append(suffixes, funcType(p.parseParams()))
+		default:
+			break suffixes
+		}
+	}
+
+	return name, func(typ ast.Expr) ast.Expr {
+		for _, f := range pointers {
+			typ = f(typ)
+		}
+		for i := len(suffixes) - 1; i >= 0; i-- {
+			typ = suffixes[i](typ)
+		}
+		if inner != nil {
+			typ = inner(typ)
+		}
+		return typ
+	}
+}
+
+// isNestedDeclarator reports whether the tokens following an opening
+// parenthesis start a nested declarator rather than a parameter list.
+func (p *parser) isNestedDeclarator() bool {
+	switch t := p.peekNonSpace(); t.Tok {
+	case token.MUL, token.LPAREN, token.LBRACK:
+		return true
+	case token.IDENT:
+		return !builtinTypes[t.Val] && !p.isTypedefName(t.Val)
+	}
+	return false
+}
+
+func funcType(params *ast.FieldList) typeFunc {
+	return func(result ast.Expr) ast.Expr {
+		return &ast.FuncType{
+			Result: result,
+			Params: params,
+		}
+	}
+}
+
+func (p *parser) parseArraySuffix() typeFunc {
+	opening := p.expect(token.LBRACK, "array type")
+	// qualifiers and "static" are only allowed in parameter
+	// declarations and don't change the type
+	for {
+		switch p.peekNonSpace().Tok {
+		case token.STATIC, token.CONST, token.VOLATILE, token.RESTRICT:
+			p.next()
+			continue
+		}
+		break
+	}
+	var length ast.Expr
+	if p.peekNonSpace().Tok != token.RBRACK {
+		length = p.parseAssignExpr()
+	}
+	closing := p.expect(token.RBRACK, "array type")
+
+	return func(elem ast.Expr) ast.Expr {
+		return &ast.ArrayType{
+			Elem:    elem,
+			Opening: opening.Pos,
+			Len:     length,
+			Closing: closing.Pos,
+		}
+	}
+}
+
+func (p *parser) parseParams() *ast.FieldList {
+	return p.parseParamList(p.expect(token.LPAREN, "parameter list"))
+}
+
+// parseParamList parses a parameter list after its opening parenthesis.
+func (p *parser) parseParamList(opening lexer.Item) *ast.FieldList {
+	var list []*ast.Field
+	if p.peekNonSpace().Tok != token.RPAREN {
+		for {
+			if t := p.peekNonSpace(); t.Tok == token.ELLIPSIS {
+				p.next()
+				list = append(list, &ast.Field{
+					Type: &ast.Ellipsis{Ellipsis: t.Pos},
+				})
+				break
+			}
+			typ := p.parseSpecifiers("parameter list")
+			name, typ := p.parseDeclarator(typ, "parameter list")
+			list = append(list, &ast.Field{
+				Type: typ,
+				Name: name,
+			})
+			if p.peekNonSpace().Tok != token.COMMA {
+				break
+			}
+			p.next()
+		}
+	}
+	closing := p.expect(token.RPAREN, "parameter list")
+
+	return &ast.FieldList{
+		Opening: opening.Pos,
+		List:    list,
+		Closing: closing.Pos,
+	}
+}
@@ -0,0 +1,201 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+func TestParser_ParseType(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value []ast.Node
+	}{
+		{
+			"long unsigned int x;",
+			[]ast.Node{
+				&ast.VarDecl{
+					Type: &ast.BasicType{
+						From: 0,
+						To:   17,
+						Name: "unsigned long",
+					},
+					Name: &ast.Ident{
+						NamePos: 18,
+						Name:    "x",
+					},
+				},
+			},
+		},
+		{
+			"int (*cb)(void *, size_t);",
+			[]ast.Node{
+				&ast.VarDecl{
+					Type: &ast.PointerType{
+						Star: 5,
+						Elem: &ast.FuncType{
+							Result: &ast.BasicType{
+								From: 0,
+								To:   3,
+								Name: "int",
+							},
+							Params: &ast.FieldList{
+								Opening: 9,
+								List: []*ast.Field{
+									{
+										Type: &ast.PointerType{
+											Star: 15,
+											Elem: &ast.BasicType{
+												From: 10,
+												To:   14,
+												Name: "void",
+											},
+										},
+									},
+									{
+										Type: &ast.Ident{
+											NamePos: 18,
+											Name:    "size_t",
+										},
+									},
+								},
+								Closing: 24,
+							},
+						},
+					},
+					Name: &ast.Ident{
+						NamePos: 6,
+						Name:    "cb",
+					},
+				},
+			},
+		},
+		{
+			"char buf[16], flex[];",
+			[]ast.Node{
+				&ast.VarDecl{
+					Type: &ast.ArrayType{
+						Elem: &ast.BasicType{
+							From: 0,
+							To:   4,
+							Name: "char",
+						},
+						Opening: 8,
+						Len: &ast.BasicLit{
+							ValuePos: 9,
+							Kind:     token.INT,
+							Value:    "16",
+						},
+						Closing: 11,
+					},
+					Name: &ast.Ident{
+						NamePos: 5,
+						Name:    "buf",
+					},
+				},
+				&ast.VarDecl{
+					Type: &ast.ArrayType{
+						Elem: &ast.BasicType{
+							From: 0,
+							To:   4,
+							Name: "char",
+						},
+						Opening: 18,
+						Closing: 19,
+					},
+					Name: &ast.Ident{
+						NamePos: 14,
+						Name:    "flex",
+					},
+				},
+			},
+		},
+		{
+			"char *const *restrict p;",
+			[]ast.Node{
+				&ast.VarDecl{
+					Type: &ast.QualType{
+						QualPos: 13,
+						Qual:    ast.RESTRICT,
+						Type: &ast.PointerType{
+							Star: 12,
+							Elem: &ast.QualType{
+								QualPos: 6,
+								Qual:    ast.CONST,
+								Type: &ast.PointerType{
+									Star: 5,
+									Elem: &ast.BasicType{
+										From: 0,
+										To:   4,
+										Name: "char",
+									},
+								},
+							},
+						},
+					},
+					Name: &ast.Ident{
+						NamePos: 22,
+						Name:    "p",
+					},
+				},
+			},
+		},
+		{
+			"struct s { unsigned a : 3; };",
+			[]ast.Node{
+				&ast.StructDecl{
+					Type: &ast.StructType{
+						Struct: 0,
+						Kind:   token.STRUCT,
+						Name: &ast.Ident{
+							NamePos: 7,
+							Name:    "s",
+						},
+						Fields: &ast.FieldList{
+							Opening: 9,
+							List: []*ast.Field{
+								{
+									Type: &ast.BasicType{
+										From: 11,
+										To:   19,
+										Name: "unsigned int",
+									},
+									Name: &ast.Ident{
+										NamePos: 20,
+										Name:    "a",
+									},
+									Bits: &ast.BasicLit{
+										ValuePos: 24,
+										Kind:     token.INT,
+										Value:    "3",
+									},
+								},
+							},
+							Closing: 27,
+						},
+					},
+					Semicolon: 28,
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s", test.Input), func(t *testing.T) {
+			parser := NewParser(t.Name(), test.Input)
+			actual := parser.Nodes()
+
+			if !reflect.DeepEqual(actual, test.Value) {
+				bufGot := new(bytes.Buffer)
+				ast.Fprint(bufGot, nil, actual, ast.NotNilFilter)
+				bufWant := new(bytes.Buffer)
+				ast.Fprint(bufWant, nil, test.Value, ast.NotNilFilter)
+				t.Errorf("%s:\ngot:\n%swant:\n%s", parser.name, bufGot.String(), bufWant.String())
+			}
+		})
+	}
+}
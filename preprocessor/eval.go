@@ -0,0 +1,133 @@
+package preprocessor
+
+import (
+	"fmt"
+
+	"github.com/SHyx0rmZ/cgen/constant"
+	"github.com/SHyx0rmZ/cgen/lexer"
+	"github.com/SHyx0rmZ/cgen/parser"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+// An itemList is a lexer.Lexer returning the items of a slice.
+type itemList []lexer.Item
+
+func (l *itemList) NextItem() lexer.Item {
+	if len(*l) == 0 {
+		return lexer.Item{Tok: token.EOF}
+	}
+	item := (*l)[0]
+	*l = (*l)[1:]
+	return item
+}
+
+// condition evaluates the controlling expression of an #if or #elif
+// directive, given as the items following the directive keyword.
+func (pp *Preprocessor) condition(line []lexer.Item) (bool, error) {
+	// The operands of defined and __has_include must not be macro
+	// expanded, so they are replaced first.
+	var ts []tok
+	for i := 0; i < len(line); i++ {
+		item := line[i]
+		if item.Tok == token.COMMENT {
+			item.Tok, item.Val = token.WHITESPACE, " "
+		}
+		if item.Tok == token.IDENT {
+			switch item.Val {
+			case "defined":
+				name, n, err := definedOperand(line[i+1:])
+				if err != nil {
+					return false, err
+				}
+				_, ok := pp.macros[name]
+				ts = append(ts, tok{Item: boolItem(item, ok)})
+				i += n
+				continue
+			case "__has_include", "__has_include_next":
+				path, n, err := hasIncludeOperand(line[i+1:])
+				if err != nil {
+					return false, err
+				}
+				ok := pp.hasInclude(path)
+				ts = append(ts, tok{Item: boolItem(item, ok)})
+				i += n
+				continue
+			}
+		}
+		ts = append(ts, tok{Item: item})
+	}
+
+	var items itemList
+	for _, t := range pp.expandList(ts) {
+		if t.Tok == token.ILLEGAL {
+			return false, fmt.Errorf("%s", t.Val)
+		}
+		// Identifiers remaining after macro expansion evaluate to 0.
+		if isIdent(t.Item) {
+			t.Item = boolItem(t.Item, false)
+		}
+		items = append(items, t.Item)
+	}
+	if len(trimItems(items)) == 0 {
+		return false, fmt.Errorf("#if with no expression")
+	}
+
+	x, err := parser.ParseExpr("", &items)
+	if err != nil {
+		return false, err
+	}
+	e := &constant.Evaluator{Preprocessor: true}
+	v, err := e.Eval(x)
+	if err != nil {
+		return false, err
+	}
+	return v.Sign() != 0, nil
+}
+
+func boolItem(at lexer.Item, b bool) lexer.Item {
+	at.Tok, at.Val = token.INT, "0"
+	if b {
+		at.Val = "1"
+	}
+	return at
+}
+
+// definedOperand returns the name following the defined operator and the
+// number of items it takes up.
+func definedOperand(items []lexer.Item) (string, int, error) {
+	i := skipSpace(items, 0)
+	paren := i < len(items) && items[i].Tok == token.LPAREN
+	if paren {
+		i = skipSpace(items, i+1)
+	}
+	if i == len(items) || !isIdent(items[i]) {
+		return "", 0, fmt.Errorf("operator \"defined\" requires an identifier")
+	}
+	name := items[i].Val
+	if paren {
+		i = skipSpace(items, i+1)
+		if i == len(items) || items[i].Tok != token.RPAREN {
+			return "", 0, fmt.Errorf("missing ) after \"defined\"")
+		}
+	}
+	return name, i + 1, nil
+}
+
+// hasIncludeOperand returns the header name following __has_include and
+// the number of items it takes up.
+func hasIncludeOperand(items []lexer.Item) (string, int, error) {
+	i := skipSpace(items, 0)
+	if i == len(items) || items[i].Tok != token.LPAREN {
+		return "", 0, fmt.Errorf("missing ( after \"__has_include\"")
+	}
+	i = skipSpace(items, i+1)
+	if i == len(items) || (items[i].Tok != token.INCLUDE_PATH && items[i].Tok != token.STRING) {
+		return "", 0, fmt.Errorf("operator \"__has_include\" requires a header name")
+	}
+	path := items[i].Val
+	i = skipSpace(items, i+1)
+	if i == len(items) || items[i].Tok != token.RPAREN {
+		return "", 0, fmt.Errorf("missing ) after \"__has_include\"")
+	}
+	return path, i + 1, nil
+}
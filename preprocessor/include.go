@@ -0,0 +1,197 @@
+package preprocessor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/SHyx0rmZ/cgen/lexer"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+// maxIncludeDepth limits the nesting of included files.
+const maxIncludeDepth = 200
+
+// A source is a file being read by the preprocessor.
+type source struct {
+	lex     lexer.Lexer
+	path    string // cleaned path, identifying the file
+	depth   int    // number of open conditionals when the file was entered
+	pending []tok  // tokens to be read once an included file ends
+
+	guard string // name of the candidate include guard macro
+	state guardState
+}
+
+// A guardState describes how far the contents of a file match the
+// pattern of an include guard:
+//
+//	#ifndef GUARD
+//	...
+//	#endif
+type guardState int
+
+const (
+	guardStart  guardState = iota // nothing but space read so far
+	guardOpen                     // inside the #ifndef of the guard
+	guardClosed                   // after the matching #endif
+	guardNone                     // the file has no include guard
+)
+
+// track advances the include guard detection of the current file by the
+// token t. If t is a directive, line contains the whole directive line.
+func (pp *Preprocessor) track(t tok, line []lexer.Item) {
+	if len(pp.files) == 0 || t.Tok == token.EOF {
+		return
+	}
+	f := pp.files[len(pp.files)-1]
+	switch f.state {
+	case guardStart:
+		f.state = guardNone
+		if t.Tok == token.IFNDEF && len(line) > 0 {
+			if line = trimItems(line[1:]); len(line) > 0 && isIdent(line[0]) {
+				f.guard, f.state = line[0].Val, guardOpen
+			}
+		}
+	case guardOpen:
+		if len(pp.conds) != f.depth+1 {
+			break
+		}
+		switch t.Tok {
+		case token.ELIF, token.ELSE:
+			f.state = guardNone
+		case token.ENDIF:
+			f.state = guardClosed
+		}
+	case guardClosed:
+		f.state = guardNone
+	}
+}
+
+// depth returns the number of conditionals opened before the current
+// file was entered.
+func (pp *Preprocessor) depth() int {
+	if len(pp.files) == 0 {
+		return 0
+	}
+	return pp.files[len(pp.files)-1].depth
+}
+
+// include reads the file named by an #include directive line.
+func (pp *Preprocessor) include(line []lexer.Item) error {
+	i := skipSpace(line, 1)
+	if i == len(line) || line[i].Tok != token.INCLUDE_PATH {
+		return fmt.Errorf(`expects "FILENAME" or <FILENAME>`)
+	}
+	spec := line[i].Val
+	path, ok := pp.lookup(spec)
+	if !ok {
+		// Headers of the system are usually not on the search path,
+		// so they are left to the parser.
+		if spec[0] == '<' {
+			return nil
+		}
+		return fmt.Errorf("%s: no such file", spec)
+	}
+
+	if pp.once[path] {
+		return nil
+	}
+	if guard, ok := pp.guards[path]; ok && pp.macros[guard] != nil {
+		return nil
+	}
+	for _, f := range pp.files {
+		if f.path != path {
+			continue
+		}
+		// The contents of a file including itself are skipped if
+		// its include guard is defined already.
+		if f.state == guardOpen && pp.macros[f.guard] != nil {
+			return nil
+		}
+		return fmt.Errorf("%s includes itself", path)
+	}
+	if len(pp.files) >= maxIncludeDepth {
+		return fmt.Errorf("nested too deeply")
+	}
+	return pp.enter(path)
+}
+
+// lookup returns the path of the file named by the header name spec,
+// including its quotes or angle brackets.
+func (pp *Preprocessor) lookup(spec string) (string, bool) {
+	if len(spec) < 2 {
+		return "", false
+	}
+	name := spec[1 : len(spec)-1]
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name), isFile(name)
+	}
+
+	var dirs []string
+	if spec[0] == '"' && len(pp.files) > 0 {
+		dirs = append(dirs, filepath.Dir(pp.files[len(pp.files)-1].path))
+	}
+	dirs = append(dirs, pp.IncludeDirs...)
+	dirs = append(dirs, pp.SystemDirs...)
+	for _, dir := range dirs {
+		if path := filepath.Join(dir, name); isFile(path) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func isFile(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsRegular()
+}
+
+// hasInclude implements __has_include.
+func (pp *Preprocessor) hasInclude(spec string) bool {
+	if pp.HasInclude != nil {
+		return pp.HasInclude(spec)
+	}
+	if pp.fset == nil {
+		return false
+	}
+	_, ok := pp.lookup(spec)
+	return ok
+}
+
+// enter continues reading at the start of the file path. The file is
+// added to the file set.
+func (pp *Preprocessor) enter(path string) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	file := pp.fset.AddFile(path, -1, len(src))
+	f := &source{
+		lex:   lexer.NewFileLexer(file, string(src)),
+		path:  path,
+		depth: len(pp.conds),
+	}
+	if len(pp.files) > 0 {
+		outer := pp.files[len(pp.files)-1]
+		outer.pending, pp.pending = pp.pending, nil
+	}
+	pp.files = append(pp.files, f)
+	pp.lex = f.lex
+	return nil
+}
+
+// leave continues reading the including file at the end of the current
+// file.
+func (pp *Preprocessor) leave() {
+	f := pp.files[len(pp.files)-1]
+	if f.state == guardClosed {
+		pp.guards[f.path] = f.guard
+	}
+	pp.files = pp.files[:len(pp.files)-1]
+
+	outer := pp.files[len(pp.files)-1]
+	pp.lex, pp.pending = outer.lex, outer.pending
+	outer.pending = nil
+}
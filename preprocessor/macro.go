@@ -0,0 +1,424 @@
+package preprocessor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SHyx0rmZ/cgen/lexer"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+// A Macro is a macro definition.
+type Macro struct {
+	Name     string
+	Params   []string     // parameter names; "__VA_ARGS__" for "..."
+	FuncLike bool         // whether the macro takes arguments
+	Variadic bool         // whether the last parameter collects the remaining arguments
+	Body     []lexer.Item // replacement list
+}
+
+func (m *Macro) param(i lexer.Item) int {
+	if !m.FuncLike || !isIdent(i) {
+		return -1
+	}
+	for n, p := range m.Params {
+		if p == i.Val {
+			return n
+		}
+	}
+	return -1
+}
+
+// parseDefine parses the items of a #define line.
+func parseDefine(line []lexer.Item) (*Macro, error) {
+	i := skipSpace(line, 1)
+	if i == len(line) || !isIdent(line[i]) {
+		return nil, fmt.Errorf("macro name missing")
+	}
+	m := &Macro{Name: line[i].Val}
+	i++
+
+	// Only an opening parenthesis directly following the name starts
+	// a parameter list.
+	if i < len(line) && line[i].Tok == token.LPAREN {
+		m.FuncLike = true
+		for i = skipSpace(line, i+1); i < len(line) && line[i].Tok != token.RPAREN; i = skipSpace(line, i+1) {
+			if len(m.Params) > 0 {
+				if line[i].Tok != token.COMMA || m.Variadic {
+					return nil, fmt.Errorf("unexpected %s in macro parameter list", line[i])
+				}
+				i = skipSpace(line, i+1)
+				if i == len(line) {
+					break
+				}
+			}
+			switch {
+			case line[i].Tok == token.ELLIPSIS:
+				m.Params = append(m.Params, "__VA_ARGS__")
+				m.Variadic = true
+			case isIdent(line[i]):
+				m.Params = append(m.Params, line[i].Val)
+				if j := skipSpace(line, i+1); j < len(line) && line[j].Tok == token.ELLIPSIS {
+					m.Variadic = true
+					i = j
+				}
+			default:
+				return nil, fmt.Errorf("unexpected %s in macro parameter list", line[i])
+			}
+		}
+		if i == len(line) {
+			return nil, fmt.Errorf("missing ) in macro parameter list")
+		}
+		i++
+	}
+
+	for _, item := range line[i:] {
+		if item.Tok == token.COMMENT {
+			item.Tok, item.Val = token.WHITESPACE, " "
+		}
+		m.Body = append(m.Body, item)
+	}
+	m.Body = trimItems(m.Body)
+	return m, nil
+}
+
+// isIdent reports whether i is an identifier. Keywords are identifiers
+// as far as the preprocessor is concerned.
+func isIdent(i lexer.Item) bool {
+	return token.Lookup(i.Val) == i.Tok
+}
+
+func isSpace(t token.Token) bool {
+	return t == token.WHITESPACE || t == token.COMMENT
+}
+
+func skipSpace(items []lexer.Item, i int) int {
+	for i < len(items) && isSpace(items[i].Tok) {
+		i++
+	}
+	return i
+}
+
+func trimItems(items []lexer.Item) []lexer.Item {
+	for len(items) > 0 && isSpace(items[0].Tok) {
+		items = items[1:]
+	}
+	for len(items) > 0 && isSpace(items[len(items)-1].Tok) {
+		items = items[:len(items)-1]
+	}
+	return items
+}
+
+// A hideset contains the names of the macros a token must not be
+// expanded by. Hidesets are never modified once created.
+type hideset map[string]bool
+
+func (h hideset) with(name string) hideset {
+	n := make(hideset, len(h)+1)
+	for k := range h {
+		n[k] = true
+	}
+	n[name] = true
+	return n
+}
+
+func (h hideset) union(o hideset) hideset {
+	if len(o) == 0 {
+		return h
+	}
+	if len(h) == 0 {
+		return o
+	}
+	n := make(hideset, len(h)+len(o))
+	for k := range h {
+		n[k] = true
+	}
+	for k := range o {
+		n[k] = true
+	}
+	return n
+}
+
+func (h hideset) intersect(o hideset) hideset {
+	n := make(hideset)
+	for k := range h {
+		if o[k] {
+			n[k] = true
+		}
+	}
+	return n
+}
+
+// A tok is an item on its way through macro expansion.
+type tok struct {
+	lexer.Item
+	hide   hideset
+	src    bool // read from the source, so it may start a directive
+	marker bool // placemarker for an empty argument of ##
+}
+
+func (t tok) isSpace() bool { return isSpace(t.Tok) }
+
+// An expander performs macro expansion using the algorithm by Dave
+// Prosser, which attaches a hideset to every token to stop a macro from
+// expanding itself recursively.
+type expander struct {
+	macros  map[string]*Macro
+	lex     lexer.Lexer // or nil, when expanding a list of tokens
+	pending []tok       // tokens to be rescanned before reading from lex
+}
+
+func (e *expander) read() (tok, bool) {
+	if len(e.pending) > 0 {
+		t := e.pending[0]
+		e.pending = e.pending[1:]
+		return t, true
+	}
+	if e.lex == nil {
+		return tok{}, false
+	}
+	return tok{Item: e.lex.NextItem(), src: true}, true
+}
+
+func (e *expander) unread(ts ...tok) {
+	e.pending = append(append([]tok(nil), ts...), e.pending...)
+}
+
+// expand replaces t if it is the name of a macro and reports whether it
+// did. The replacement is pushed back to be rescanned.
+func (e *expander) expand(t tok) bool {
+	if !isIdent(t.Item) || t.hide[t.Val] {
+		return false
+	}
+	m, ok := e.macros[t.Val]
+	if !ok {
+		return false
+	}
+	if !m.FuncLike {
+		e.unread(e.subst(m, nil, t.hide.with(m.Name), t.Pos)...)
+		return true
+	}
+
+	// A function-like macro is only invoked if its name is followed
+	// by an opening parenthesis.
+	var skipped []tok
+	for {
+		n, ok := e.read()
+		if !ok {
+			e.unread(skipped...)
+			return false
+		}
+		if n.isSpace() {
+			skipped = append(skipped, n)
+			continue
+		}
+		if n.Tok != token.LPAREN {
+			e.unread(append(skipped, n)...)
+			return false
+		}
+		break
+	}
+
+	args, rparen, err := e.readArgs(m)
+	if err != nil {
+		e.unread(tok{Item: lexer.Item{Pos: t.Pos, Val: err.Error(), Tok: token.ILLEGAL}})
+		return true
+	}
+	hs := t.hide.intersect(rparen.hide).with(m.Name)
+	e.unread(e.subst(m, args, hs, t.Pos)...)
+	return true
+}
+
+// readArgs reads the arguments of an invocation of m up to and including
+// the closing parenthesis.
+func (e *expander) readArgs(m *Macro) ([][]tok, tok, error) {
+	var args [][]tok
+	var arg []tok
+	depth := 0
+	for {
+		t, ok := e.read()
+		if !ok || t.Tok == token.EOF || t.Tok == token.ILLEGAL {
+			if ok {
+				e.unread(t)
+			}
+			return nil, t, fmt.Errorf("unterminated argument list invoking macro %q", m.Name)
+		}
+		switch t.Tok {
+		case token.LPAREN:
+			depth++
+		case token.RPAREN:
+			if depth > 0 {
+				depth--
+				break
+			}
+			args = append(args, trimToks(arg))
+			if len(m.Params) == 0 && len(args) == 1 && len(args[0]) == 0 {
+				args = nil
+			}
+			if m.Variadic && len(args) == len(m.Params)-1 {
+				args = append(args, nil)
+			}
+			if len(args) != len(m.Params) {
+				return nil, t, fmt.Errorf("macro %q requires %d arguments, but %d given", m.Name, len(m.Params), len(args))
+			}
+			return args, t, nil
+		case token.COMMA:
+			if depth == 0 && !(m.Variadic && len(args) == len(m.Params)-1) {
+				args = append(args, trimToks(arg))
+				arg = nil
+				continue
+			}
+		}
+		arg = append(arg, t)
+	}
+}
+
+// subst substitutes args into the replacement list of m. The resulting
+// tokens get the hideset hs and the position of the macro invocation.
+func (e *expander) subst(m *Macro, args [][]tok, hs hideset, pos token.Pos) []tok {
+	var out []tok
+	body := m.Body
+	for i := 0; i < len(body); i++ {
+		t := body[i]
+		switch {
+		case t.Tok == token.HASH && m.FuncLike:
+			j := skipSpace(body, i+1)
+			if j < len(body) && m.param(body[j]) >= 0 {
+				out = append(out, tok{Item: stringize(args[m.param(body[j])])})
+				i = j
+				continue
+			}
+		case t.Tok == token.HASHHASH && len(out) > 0:
+			j := skipSpace(body, i+1)
+			if j == len(body) {
+				break
+			}
+			i = j
+			rhs := []tok{{Item: body[j]}}
+			k := m.param(body[j])
+			if k >= 0 {
+				rhs = args[k]
+			}
+			out = trimToks(out)
+			// GNU extension: ", ## __VA_ARGS__" drops the comma if no
+			// variable arguments are given, and doesn't paste otherwise.
+			if m.Variadic && k == len(m.Params)-1 && len(out) > 0 && out[len(out)-1].Tok == token.COMMA {
+				if len(rhs) == 0 {
+					out = out[:len(out)-1]
+				}
+				out = append(out, e.expandList(rhs)...)
+				continue
+			}
+			if len(rhs) == 0 {
+				continue
+			}
+			lhs := out[len(out)-1]
+			out = append(out[:len(out)-1], paste(lhs, rhs[0])...)
+			out = append(out, rhs[1:]...)
+			continue
+		}
+
+		if k := m.param(t); k >= 0 {
+			// Arguments are macro expanded before substitution,
+			// unless they are an operand of ##.
+			if j := skipSpace(body, i+1); j < len(body) && body[j].Tok == token.HASHHASH {
+				if len(args[k]) == 0 {
+					out = append(out, tok{marker: true})
+				}
+				out = append(out, args[k]...)
+			} else {
+				out = append(out, e.expandList(args[k])...)
+			}
+			continue
+		}
+		out = append(out, tok{Item: t})
+	}
+
+	result := out[:0]
+	for _, t := range out {
+		if t.marker {
+			continue
+		}
+		t.hide = t.hide.union(hs)
+		t.Pos = pos
+		t.src = false
+		result = append(result, t)
+	}
+	return result
+}
+
+// expandList fully expands a list of tokens.
+func (e *expander) expandList(ts []tok) []tok {
+	sub := &expander{
+		macros:  e.macros,
+		pending: append([]tok(nil), ts...),
+	}
+	var out []tok
+	for {
+		t, ok := sub.read()
+		if !ok {
+			return out
+		}
+		if !sub.expand(t) {
+			out = append(out, t)
+		}
+	}
+}
+
+func trimToks(ts []tok) []tok {
+	for len(ts) > 0 && ts[0].isSpace() {
+		ts = ts[1:]
+	}
+	for len(ts) > 0 && ts[len(ts)-1].isSpace() {
+		ts = ts[:len(ts)-1]
+	}
+	return ts
+}
+
+// stringize implements the # operator.
+func stringize(arg []tok) lexer.Item {
+	var b strings.Builder
+	b.WriteByte('"')
+	space := false
+	for _, t := range arg {
+		if t.isSpace() {
+			space = b.Len() > 1
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		if t.Tok == token.STRING || t.Tok == token.CHAR {
+			b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(t.Val))
+			continue
+		}
+		b.WriteString(t.Val)
+	}
+	b.WriteByte('"')
+	return lexer.Item{Val: b.String(), Tok: token.STRING}
+}
+
+// paste implements the ## operator.
+func paste(lhs, rhs tok) []tok {
+	if lhs.marker {
+		return []tok{rhs}
+	}
+	if rhs.marker {
+		return []tok{lhs}
+	}
+	l := lexer.NewLexer("", lhs.Val+rhs.Val)
+	item := l.NextItem()
+	if next := l.NextItem(); next.Tok != token.EOF || item.Tok == token.ILLEGAL {
+		for next.Tok != token.EOF && next.Tok != token.ILLEGAL {
+			next = l.NextItem()
+		}
+		return []tok{{Item: lexer.Item{
+			Pos: lhs.Pos,
+			Val: fmt.Sprintf("pasting %q and %q does not give a valid preprocessing token", lhs.Val, rhs.Val),
+			Tok: token.ILLEGAL,
+		}}}
+	}
+	item.Pos = lhs.Pos
+	return []tok{{Item: item, hide: lhs.hide.intersect(rhs.hide)}}
+}
@@ -0,0 +1,307 @@
+// Package preprocessor implements the C preprocessor on top of the items
+// produced by a lexer.
+//
+// Conditional directives are evaluated and the lines of inactive groups
+// are dropped. The remaining directive lines are passed through
+// unchanged, so that a parser reading from a Preprocessor still sees
+// every macro definition. All other items are subject to macro expansion.
+//
+// A Preprocessor created by NewFile also resolves #include directives
+// and reads the included files in place of the directive.
+package preprocessor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/SHyx0rmZ/cgen/lexer"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+// A Preprocessor expands macros in the items of a lexer. It implements
+// lexer.Lexer itself.
+type Preprocessor struct {
+	expander
+	out   []lexer.Item // directive lines waiting to be passed through
+	conds []cond       // open conditional directives, innermost last
+
+	fset   *token.FileSet    // or nil, if includes are not resolved
+	files  []*source         // files being read, innermost last
+	once   map[string]bool   // files containing #pragma once
+	guards map[string]string // include guard macros of files read before
+
+	// IncludeDirs and SystemDirs are searched in order for included
+	// files, like the -I and -isystem options of C compilers. Files
+	// included with quotes are looked up in the directory of the
+	// including file first.
+	IncludeDirs []string
+	SystemDirs  []string
+
+	// HasInclude reports whether the header path, including its quotes
+	// or angle brackets, can be included. It is used to evaluate
+	// __has_include; if it is nil, the include search path is used.
+	HasInclude func(path string) bool
+}
+
+// A cond is the state of an open conditional directive.
+type cond struct {
+	pos    token.Pos
+	active bool // whether the current group is active
+	taken  bool // whether a group of the conditional has been active
+	inElse bool // whether #else has been seen
+	skip   bool // whether the enclosing group is inactive
+}
+
+// New returns a Preprocessor reading from lex.
+func New(lex lexer.Lexer) *Preprocessor {
+	return &Preprocessor{
+		expander: expander{
+			macros: make(map[string]*Macro),
+			lex:    lex,
+		},
+		once:   make(map[string]bool),
+		guards: make(map[string]string),
+	}
+}
+
+// NewFile returns a Preprocessor reading the file filename. The file and
+// all files it includes are added to fset.
+func NewFile(fset *token.FileSet, filename string) (*Preprocessor, error) {
+	pp := New(nil)
+	pp.fset = fset
+	if err := pp.enter(filepath.Clean(filename)); err != nil {
+		return nil, err
+	}
+	return pp, nil
+}
+
+// FileSet returns the file set the files read by pp are added to, or nil
+// if pp was not created by NewFile.
+func (pp *Preprocessor) FileSet() *token.FileSet {
+	return pp.fset
+}
+
+// Define adds the macro m, replacing any previous definition.
+func (pp *Preprocessor) Define(m *Macro) {
+	pp.macros[m.Name] = m
+}
+
+// Predefine defines a macro given in the syntax of the -D command line
+// option of C compilers: "NAME" defines NAME as 1, "NAME=VALUE" defines
+// NAME as VALUE, and NAME may include a parameter list.
+func (pp *Preprocessor) Predefine(def string) error {
+	name, value := def, "1"
+	if i := strings.IndexByte(def, '='); i >= 0 {
+		name, value = def[:i], def[i+1:]
+	}
+	lex := lexer.NewLexer("<command-line>", "#define "+name+" "+value+"\n")
+	var line []lexer.Item
+	for {
+		item := lex.NextItem()
+		if item.Tok == token.EOF {
+			break
+		}
+		if item.Tok == token.ILLEGAL {
+			return fmt.Errorf("invalid macro definition %q: %s", def, item.Val)
+		}
+		line = append(line, item)
+	}
+	m, err := parseDefine(line)
+	if err != nil {
+		return fmt.Errorf("invalid macro definition %q: %s", def, err)
+	}
+	pp.Define(m)
+	return nil
+}
+
+// Undefine removes the definition of the macro name, if any.
+func (pp *Preprocessor) Undefine(name string) {
+	delete(pp.macros, name)
+}
+
+// Macro returns the definition of the macro name, or nil.
+func (pp *Preprocessor) Macro(name string) *Macro {
+	return pp.macros[name]
+}
+
+func (pp *Preprocessor) NextItem() lexer.Item {
+	for {
+		if len(pp.out) > 0 {
+			item := pp.out[0]
+			pp.out = pp.out[1:]
+			return item
+		}
+
+		t, _ := pp.read()
+		if t.src && !t.isSpace() && !isDirective(t.Tok) {
+			pp.track(t, nil)
+		}
+		switch {
+		case t.Tok == token.EOF:
+			// Conditionals must be terminated in the file they
+			// were opened in.
+			if n := pp.depth(); len(pp.conds) > n {
+				c := pp.conds[n]
+				pp.conds = pp.conds[:n]
+				pp.unread(t)
+				return pp.errorItem(c.pos, "unterminated conditional directive")
+			}
+			if len(pp.files) > 1 {
+				pp.leave()
+				continue
+			}
+			pp.unread(t)
+			return t.Item
+		case t.Tok == token.ILLEGAL && !pp.skipping():
+			// lexer errors in skipped groups are ignored
+			return t.Item
+		case t.src && isDirective(t.Tok):
+			pp.directive(t)
+			continue
+		case pp.skipping():
+			continue
+		case pp.expand(t):
+			continue
+		}
+		return t.Item
+	}
+}
+
+func isDirective(t token.Token) bool {
+	switch t {
+	case token.DEFINE, token.UNDEF, token.INCLUDE, token.PRAGMA, token.IF, token.ELIF, token.IFDEF, token.IFNDEF, token.ELSE, token.ENDIF:
+		return true
+	}
+	return false
+}
+
+// skipping reports whether the current group is inactive.
+func (pp *Preprocessor) skipping() bool {
+	return len(pp.conds) > 0 && !pp.conds[len(pp.conds)-1].active
+}
+
+func (pp *Preprocessor) errorItem(pos token.Pos, format string, args ...interface{}) lexer.Item {
+	return lexer.Item{Pos: pos, Val: fmt.Sprintf(format, args...), Tok: token.ILLEGAL}
+}
+
+// directive reads the remainder of a directive line and acts on it.
+// Conditional directives are consumed, other directives in active groups
+// are queued to be passed through.
+func (pp *Preprocessor) directive(t tok) {
+	line := []lexer.Item{t.Item}
+	for {
+		n, _ := pp.read()
+		if n.Tok == token.EOF || n.Tok == token.ILLEGAL {
+			pp.unread(n)
+			break
+		}
+		line = append(line, n.Item)
+		if n.Tok == token.WHITESPACE && strings.Contains(n.Val, "\n") {
+			break
+		}
+	}
+	pp.track(t, line)
+
+	switch t.Tok {
+	case token.IF, token.IFDEF, token.IFNDEF, token.ELIF, token.ELSE, token.ENDIF:
+		if err := pp.conditional(t, line[1:]); err != nil {
+			pp.out = append(pp.out, pp.errorItem(t.Pos, "%s: %s", t.Tok, err))
+		}
+		return
+	}
+	if pp.skipping() {
+		return
+	}
+	pp.out = append(pp.out, line...)
+
+	switch t.Tok {
+	case token.DEFINE:
+		// Malformed definitions are reported by the parser.
+		if m, err := parseDefine(line); err == nil {
+			pp.Define(m)
+		}
+	case token.UNDEF:
+		if i := skipSpace(line, 1); i < len(line) && isIdent(line[i]) {
+			pp.Undefine(line[i].Val)
+		}
+	case token.INCLUDE:
+		if pp.fset == nil {
+			break
+		}
+		if err := pp.include(line); err != nil {
+			pp.out = append(pp.out, pp.errorItem(t.Pos, "%s: %s", t.Tok, err))
+		}
+	case token.PRAGMA:
+		if i := skipSpace(line, 1); i < len(line) && line[i].Val == "once" && len(pp.files) > 0 {
+			pp.once[pp.files[len(pp.files)-1].path] = true
+		}
+	}
+}
+
+// conditional updates the stack of conditional directives for the
+// directive t followed by the items in line.
+func (pp *Preprocessor) conditional(t tok, line []lexer.Item) error {
+	switch t.Tok {
+	case token.IF, token.IFDEF, token.IFNDEF:
+		c := cond{pos: t.Pos, skip: pp.skipping()}
+		if !c.skip {
+			ok, err := pp.test(t, line)
+			if err != nil {
+				// Skip the whole conditional after reporting the error.
+				c.taken = true
+				pp.conds = append(pp.conds, c)
+				return err
+			}
+			c.active, c.taken = ok, ok
+		}
+		pp.conds = append(pp.conds, c)
+		return nil
+	}
+
+	if len(pp.conds) == 0 {
+		return fmt.Errorf("without #if")
+	}
+	c := &pp.conds[len(pp.conds)-1]
+	switch t.Tok {
+	case token.ELIF:
+		if c.inElse {
+			return fmt.Errorf("after #else")
+		}
+		c.active = false
+		if c.skip || c.taken {
+			return nil
+		}
+		ok, err := pp.test(t, line)
+		if err != nil {
+			c.taken = true
+			return err
+		}
+		c.active, c.taken = ok, ok
+	case token.ELSE:
+		if c.inElse {
+			return fmt.Errorf("after #else")
+		}
+		c.inElse = true
+		c.active = !c.skip && !c.taken
+		c.taken = true
+	case token.ENDIF:
+		pp.conds = pp.conds[:len(pp.conds)-1]
+	}
+	return nil
+}
+
+// test evaluates the condition of an #if, #elif, #ifdef or #ifndef
+// directive.
+func (pp *Preprocessor) test(t tok, line []lexer.Item) (bool, error) {
+	switch t.Tok {
+	case token.IFDEF, token.IFNDEF:
+		line = trimItems(line)
+		if len(line) == 0 || !isIdent(line[0]) {
+			return false, fmt.Errorf("no macro name given")
+		}
+		_, ok := pp.macros[line[0].Val]
+		return ok == (t.Tok == token.IFDEF), nil
+	}
+	return pp.condition(line)
+}
@@ -0,0 +1,268 @@
+package preprocessor
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/SHyx0rmZ/cgen/lexer"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+// expand preprocesses input and returns the resulting items, separated by
+// single spaces.
+func expand(name, input string) string {
+	pp := New(lexer.NewLexer(name, input))
+	var s []string
+	for {
+		item := pp.NextItem()
+		switch item.Tok {
+		case token.EOF:
+			return strings.Join(s, " ")
+		case token.ILLEGAL:
+			return strings.Join(append(s, item.Val), " ")
+		case token.WHITESPACE:
+			continue
+		}
+		s = append(s, item.Val)
+	}
+}
+
+func TestPreprocessor_NextItem(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value string
+	}{
+		{
+			"#define A 1\nA",
+			"#define A 1 1",
+		},
+		{
+			"#define F(x, y) x - y\nF(1, (2, 3))",
+			"#define F ( x , y ) x - y 1 - ( 2 , 3 )",
+		},
+		{
+			"#define F(x) x\nF;",
+			"#define F ( x ) x F ;",
+		},
+		{
+			"#define A A B\n#define B A\nA",
+			"#define A A B #define B A A A",
+		},
+		{
+			"#define S(x) #x\nS(a  \"b\\n\"   c)",
+			`#define S ( x ) # x "a \"b\\n\" c"`,
+		},
+		{
+			"#define S(x) #x\nS('\"' '\\\\')",
+			`#define S ( x ) # x "'\"' '\\\\'"`,
+		},
+		{
+			"#define CAT(a, b) a ## b\nCAT(foo, bar) CAT(, bar) CAT(foo, )",
+			"#define CAT ( a , b ) a ## b foobar bar foo",
+		},
+		{
+			"#define A 2\n#define F(x) x\n#define S(x) #x\nF(A) S(A)",
+			`#define A 2 #define F ( x ) x #define S ( x ) # x 2 "A"`,
+		},
+		{
+			"#define P(fmt, ...) f(fmt, ## __VA_ARGS__)\nP(a) P(a, b, c)",
+			"#define P ( fmt , ... ) f ( fmt , ## __VA_ARGS__ ) f ( a ) f ( a , b , c )",
+		},
+		{
+			"#define f(a) a*g\n#define g(a) f(a)\nf(2)(9)",
+			"#define f ( a ) a * g #define g ( a ) f ( a ) 2 * 9 * g",
+		},
+		{
+			"#define API(x) extern x;\nAPI(int\nfoo)",
+			"#define API ( x ) extern x ; extern int foo ;",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s", test.Input), func(t *testing.T) {
+			actual := expand(t.Name(), test.Input)
+			if actual != test.Value {
+				t.Errorf("got:\n%s\nwant:\n%s", actual, test.Value)
+			}
+		})
+	}
+}
+
+func TestPreprocessor_Conditional(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value string
+	}{
+		{
+			"#ifndef H\n#define H\nint a;\n#endif\n#ifndef H\nint b;\n#endif",
+			"#define H int a ;",
+		},
+		{
+			"#if 1\na\n#else\nb\n#endif",
+			"a",
+		},
+		{
+			"#if 0\na\n#elif 2 > 1\nb\n#elif 1\nc\n#else\nd\n#endif",
+			"b",
+		},
+		{
+			"#define A\n#if defined A && !defined(B)\na\n#endif",
+			"#define A a",
+		},
+		{
+			"#define V 3\n#if V * 2 == 6 && UNDEFINED == 0\na\n#endif",
+			"#define V 3 a",
+		},
+		{
+			"#define F(x) (x - 1)\n#if F(1) != 0\na\n#else\nb\n#endif",
+			"#define F ( x ) ( x - 1 ) b",
+		},
+		{
+			"#if 0\n#if 1\na\n#else\nb\n#endif\n#define A\n#else\nc\n#endif\n#ifdef A\nd\n#endif",
+			"c",
+		},
+		{
+			"#if -1 < 0u\na\n#endif\n#if 0 && 1 / 0\nb\n#endif",
+			"",
+		},
+		{
+			"#if 18446744073709551615 == -1 && 9223372036854775808 > 0\na\n#endif",
+			"a",
+		},
+		{
+			"#define A 1\n#undef A\n#ifdef A\na\n#endif",
+			"#define A 1 #undef A",
+		},
+		{
+			"#if 0\nfoo 32.h\n#endif\nint z;",
+			"int z ;",
+		},
+		{
+			"#ifndef A\n#else\n1.2.3 0x1e+2\n#endif\nint z;",
+			"int z ;",
+		},
+		{
+			"#if __has_include(<stdio.h>)\na\n#endif",
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s", test.Input), func(t *testing.T) {
+			actual := expand(t.Name(), test.Input)
+			if actual != test.Value {
+				t.Errorf("got:\n%s\nwant:\n%s", actual, test.Value)
+			}
+		})
+	}
+}
+
+func TestPreprocessor_Predefine(t *testing.T) {
+	pp := New(lexer.NewLexer("predefine", "#if A == 1 && B == 2 && !defined C\nF(B)\n#endif"))
+	for _, def := range []string{"A", "B=2", "F(x)=-x", "C"} {
+		if err := pp.Predefine(def); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pp.Undefine("C")
+
+	var s []string
+	for item := pp.NextItem(); item.Tok != token.EOF; item = pp.NextItem() {
+		if item.Tok == token.ILLEGAL {
+			t.Fatal(item.Val)
+		}
+		if item.Tok != token.WHITESPACE {
+			s = append(s, item.Val)
+		}
+	}
+	if actual := strings.Join(s, " "); actual != "- 2" {
+		t.Errorf("got %q, want %q", actual, "- 2")
+	}
+}
+
+func TestPreprocessor_ConditionalError(t *testing.T) {
+	tests := []string{
+		"#endif",
+		"#else\n",
+		"#if 1\n#else\n#else\n#endif",
+		"#if 1\n#else\n#elif 1\n#endif",
+		"#if 1\n",
+		"#if\n#endif",
+		"#if 1 / 0\n#endif",
+		"#if defined(\n#endif",
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s", test), func(t *testing.T) {
+			pp := New(lexer.NewLexer(t.Name(), test))
+			for item := pp.NextItem(); item.Tok != token.EOF; item = pp.NextItem() {
+				if item.Tok == token.ILLEGAL {
+					return
+				}
+			}
+			t.Errorf("expected an error")
+		})
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	fset := token.NewFileSet()
+	pp, err := NewFile(fset, "testdata/include/main.h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pp.SystemDirs = []string{"testdata/include/sys"}
+
+	var s []string
+	directive := false
+	for item := pp.NextItem(); item.Tok != token.EOF; item = pp.NextItem() {
+		switch {
+		case item.Tok == token.ILLEGAL:
+			t.Fatal(item.Val)
+		case isDirective(item.Tok):
+			directive = true
+		case item.Tok == token.WHITESPACE && strings.Contains(item.Val, "\n"):
+			directive = false
+		case item.Tok == token.IDENT && !directive:
+			s = append(s, fmt.Sprintf("%s %s", fset.Position(item.Pos), item.Val))
+		}
+	}
+
+	expected := []string{
+		"testdata/include/a.h:4:1 a",
+		"testdata/include/sys/b.h:1:1 b",
+		"testdata/include/sys/c.h:1:3 c",
+		"testdata/include/once.h:2:1 once",
+		"testdata/include/self.h:3:1 self",
+		"testdata/include/main.h:9:1 main",
+	}
+	if actual := strings.Join(s, "\n"); actual != strings.Join(expected, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", actual, strings.Join(expected, "\n"))
+	}
+	if guard := pp.guards["testdata/include/a.h"]; guard != "A_H" {
+		t.Errorf("got include guard %q, want %q", guard, "A_H")
+	}
+}
+
+func TestNewFile_Error(t *testing.T) {
+	tests := []string{
+		"testdata/include/cycle.h",
+		"testdata/include/unterminated_outer.h",
+	}
+
+	for _, test := range tests {
+		t.Run(test, func(t *testing.T) {
+			pp, err := NewFile(token.NewFileSet(), test)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for item := pp.NextItem(); item.Tok != token.EOF; item = pp.NextItem() {
+				if item.Tok == token.ILLEGAL {
+					return
+				}
+			}
+			t.Errorf("expected an error")
+		})
+	}
+}
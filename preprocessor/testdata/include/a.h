@@ -0,0 +1,5 @@
+/* guarded */
+#ifndef A_H
+#define A_H
+a
+#endif
@@ -0,0 +1,10 @@
+#include "a.h"
+#include "a.h"
+#include <b.h>
+#include <stdio.h>
+#include "once.h"
+#include "once.h"
+#include "self.h"
+#if __has_include("a.h") && !__has_include(<missing.h>)
+main
+#endif
@@ -0,0 +1,5 @@
+#ifndef SELF_H
+#define SELF_H
+self
+#include "self.h"
+#endif
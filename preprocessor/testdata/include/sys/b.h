@@ -0,0 +1,2 @@
+b
+#include "c.h"
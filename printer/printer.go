@@ -0,0 +1,843 @@
+// Package printer implements printing of C syntax trees, for example to
+// write a trimmed or patched copy of a header.
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+// Fprint writes the C source of node to output. The node may be an
+// *ast.File, a []ast.Node or a single node. Directives and declarations
+// are printed with their line breaks, expressions and types without.
+//
+// If fset is not nil, the lines of the positions of the nodes are used
+// to keep blank lines between nodes, and to place the comments of an
+// *ast.File that aren't attached to a node where they were. Declarators
+// declared together, as in "int a, *b;", are printed together again.
+//
+// Function bodies are not parsed, so function definitions are printed
+// with empty bodies, and bad nodes as BadDir, BadStmt or BadExpr.
+func Fprint(output io.Writer, fset *token.FileSet, node interface{}) error {
+	p := &printer{fset: fset, printed: make(map[*ast.CommentGroup]bool)}
+	switch n := node.(type) {
+	case *ast.File:
+		p.comments = n.Comments
+		p.nodes(n.Nodes)
+		p.flush(token.NoPos)
+	case []ast.Node:
+		p.nodes(n)
+	case ast.Dir, ast.Decl, ast.Stmt:
+		p.nodes([]ast.Node{n.(ast.Node)})
+	case *ast.CommentGroup:
+		p.comment(n)
+	case *ast.ArgList:
+		p.print(p.args(n))
+	case *ast.FieldList:
+		p.print(p.params(n))
+	case *ast.Field:
+		p.member([]*ast.Field{n})
+	case *ast.Attribute:
+		p.print(p.attr(n))
+	case ast.EnumSpec:
+		p.enumerator(n)
+	case ast.Expr:
+		if isType(n) {
+			p.declare(n, "")
+		} else {
+			p.print(p.expr(n, commaPrec))
+		}
+	default:
+		return fmt.Errorf("printer: unsupported node type %T", node)
+	}
+	_, err := output.Write(p.buf.Bytes())
+	return err
+}
+
+type printer struct {
+	fset    *token.FileSet
+	buf     bytes.Buffer
+	indent  int  // number of tabs lines are indented by
+	inline  bool // whether struct and enum bodies are printed on one line
+	line    int  // line of the end of the node printed last; or 0
+	printed map[*ast.CommentGroup]bool
+
+	// Comments of the file that haven't been printed yet. Comments
+	// attached to a node are printed with the node.
+	comments []*ast.CommentGroup
+}
+
+// print writes s, indenting it if it starts a line.
+func (p *printer) print(s ...string) {
+	for _, s := range s {
+		if s == "" {
+			continue
+		}
+		if n := p.buf.Len(); !p.inline && (n == 0 || p.buf.Bytes()[n-1] == '\n') {
+			p.buf.WriteString(strings.Repeat("\t", p.indent))
+		}
+		p.buf.WriteString(s)
+	}
+}
+
+func (p *printer) newline() {
+	p.buf.WriteByte('\n')
+}
+
+// lineOf returns the line of pos, or 0 if it is unknown.
+func (p *printer) lineOf(pos token.Pos) int {
+	if p.fset == nil || !pos.IsValid() {
+		return 0
+	}
+	return p.fset.Position(pos).Line
+}
+
+// ----------------------------------------------------------------------------
+// Comments
+
+// comment prints the comment group g. The lines of block comments are
+// printed as they are.
+func (p *printer) comment(g *ast.CommentGroup) {
+	p.printed[g] = true
+	for i, c := range g.List {
+		if i > 0 {
+			prev := g.List[i-1]
+			if strings.HasPrefix(prev.Text, "//") || p.lineOf(c.Pos()) == 0 || p.lineOf(c.Pos()) != p.lineOf(prev.End()) {
+				p.newline()
+			} else {
+				p.print(" ")
+			}
+		}
+		p.print(c.Text)
+	}
+}
+
+// doc prints the documentation g on the lines before a node.
+func (p *printer) doc(g *ast.CommentGroup) {
+	if g == nil || p.inline || p.printed[g] {
+		return
+	}
+	p.comment(g)
+	p.newline()
+}
+
+// lineComment prints the line comment g after a node.
+func (p *printer) lineComment(g *ast.CommentGroup) {
+	if g == nil || p.inline || p.printed[g] {
+		return
+	}
+	p.print(" ")
+	p.comment(g)
+}
+
+// flush prints the comments of the file preceding pos on lines of their
+// own, or all of them if pos is NoPos.
+func (p *printer) flush(pos token.Pos) {
+	for len(p.comments) > 0 && (pos == token.NoPos || p.comments[0].Pos() < pos) {
+		g := p.comments[0]
+		p.comments = p.comments[1:]
+		if p.printed[g] {
+			continue
+		}
+		p.space(g.Pos())
+		p.comment(g)
+		p.newline()
+		p.line = p.lineOf(g.End())
+	}
+}
+
+// trailing prints the comments of the file preceding next that start on
+// the line of the node printed last.
+func (p *printer) trailing(next token.Pos) {
+	for len(p.comments) > 0 {
+		g := p.comments[0]
+		if p.printed[g] {
+			p.comments = p.comments[1:]
+			continue
+		}
+		if p.line == 0 || p.lineOf(g.Pos()) != p.line || next.IsValid() && g.Pos() >= next {
+			return
+		}
+		p.comments = p.comments[1:]
+		p.print(" ")
+		p.comment(g)
+		p.line = p.lineOf(g.End())
+	}
+}
+
+// begin prepares printing a node starting at pos on a new line: the
+// comments preceding it are printed, and a blank line if the source has
+// one.
+func (p *printer) begin(pos token.Pos) {
+	if pos.IsValid() {
+		p.flush(pos)
+	}
+	p.space(pos)
+}
+
+// space prints a blank line if there is one between the node printed
+// last and pos.
+func (p *printer) space(pos token.Pos) {
+	if line := p.lineOf(pos); p.line > 0 && line > p.line+1 {
+		p.newline()
+	}
+}
+
+// start returns the start of n, including its documentation.
+func start(n ast.Node) token.Pos {
+	var doc *ast.CommentGroup
+	switch n := n.(type) {
+	case *ast.MacroDir:
+		doc = n.Doc
+		if doc == nil {
+			return n.DirPos
+		}
+	case *ast.FuncDecl:
+		doc = n.Doc
+	case *ast.ExternDecl:
+		if f, ok := n.Decl.(*ast.FuncDecl); ok {
+			doc = f.Doc
+		}
+		if doc == nil {
+			return n.KeyPos
+		}
+	case *ast.Field:
+		doc = n.Doc
+	case *ast.EnumValue:
+		doc = n.Doc
+	case *ast.EnumConstExpr:
+		doc = n.Doc
+	}
+	if doc != nil {
+		return doc.Pos()
+	}
+	return n.Pos()
+}
+
+// end returns the end of n, including its line comment.
+func end(n ast.Node) token.Pos {
+	var comment *ast.CommentGroup
+	switch n := n.(type) {
+	case *ast.MacroDir:
+		comment = n.Comment
+	case *ast.Field:
+		comment = n.Comment
+	case *ast.EnumValue:
+		comment = n.Comment
+	case *ast.EnumConstExpr:
+		comment = n.Comment
+	}
+	if comment != nil {
+		return comment.End()
+	}
+	return n.End()
+}
+
+// ----------------------------------------------------------------------------
+// Directives and declarations
+
+// nodes prints the top-level nodes in list, one per line. Declarations
+// with the same specifiers are printed as one.
+func (p *printer) nodes(list []ast.Node) {
+	for i := 0; i < len(list); {
+		j := i + 1
+		for j < len(list) && sameSpecifiers(list[j-1], list[j]) {
+			j++
+		}
+		p.begin(start(list[i]))
+		p.node(list[i:j])
+		p.line = p.lineOf(end(list[j-1]))
+		var next token.Pos
+		if j < len(list) {
+			next = start(list[j])
+		}
+		p.trailing(next)
+		p.newline()
+		i = j
+	}
+}
+
+// node prints the directive, statement or expression list[0], or the
+// declarations in list.
+func (p *printer) node(list []ast.Node) {
+	switch n := list[0].(type) {
+	case *ast.BadDir:
+		p.print("BadDir")
+	case *ast.MacroDir:
+		p.doc(n.Doc)
+		p.print("#define ", n.Name.Name)
+		if n.Args != nil {
+			p.print(p.args(n.Args))
+		}
+		if n.Value != nil {
+			p.print(" ", p.expr(n.Value, commaPrec))
+		}
+		p.lineComment(n.Comment)
+	case *ast.UndefDir:
+		p.print("#undef ", n.Name.Name)
+	case *ast.IncludeDir:
+		p.print("#include ", n.Path)
+	case *ast.PragmaDir:
+		p.print("#pragma")
+		if n.Text != "" {
+			p.print(" ", n.Text)
+		}
+	case *ast.IfDefDir:
+		if n.Cond == ast.NOT_DEFINED {
+			p.print("#ifndef ", n.Name.Name)
+		} else {
+			p.print("#ifdef ", n.Name.Name)
+		}
+	case *ast.IfDir:
+		p.print("#if ", p.expr(n.Cond, commaPrec))
+	case *ast.ElifDir:
+		p.print("#elif ", p.expr(n.Cond, commaPrec))
+	case *ast.ElseDir:
+		p.print("#else")
+	case *ast.EndIfDir:
+		p.print("#endif")
+
+	case *ast.BadStmt:
+		p.print("BadStmt")
+	case *ast.BlockStmt:
+		p.print("{}")
+
+	case *ast.ExternDecl:
+		switch d := n.Decl.(type) {
+		case nil:
+			p.print("extern")
+			return
+		case *ast.CDecl:
+			p.print("extern ", d.Value.Value, " {")
+			return
+		}
+		decls := make([]ast.Decl, len(list))
+		for i, n := range list {
+			decls[i] = n.(*ast.ExternDecl).Decl
+		}
+		p.doc(funcDoc(decls))
+		p.print("extern ")
+		p.decls(decls)
+	case *ast.CDecl:
+		p.print("extern ", n.Value.Value, " {")
+	case *ast.EndCDecl:
+		p.print("}")
+	case ast.Decl:
+		decls := make([]ast.Decl, len(list))
+		for i, n := range list {
+			decls[i] = n.(ast.Decl)
+		}
+		p.doc(funcDoc(decls))
+		p.decls(decls)
+
+	case ast.Expr:
+		p.print(p.expr(n, commaPrec))
+	}
+}
+
+// funcDoc returns the documentation of the first function declared in
+// decls.
+func funcDoc(decls []ast.Decl) *ast.CommentGroup {
+	for _, d := range decls {
+		if f, ok := d.(*ast.FuncDecl); ok && f.Doc != nil {
+			return f.Doc
+		}
+	}
+	return nil
+}
+
+// decls prints the declarations in decls, which have the same
+// specifiers, as one declaration.
+func (p *printer) decls(decls []ast.Decl) {
+	if d, ok := decls[0].(*ast.StructDecl); ok {
+		p.spec(d.Type)
+		p.print(";")
+		return
+	}
+	if _, ok := decls[0].(*ast.TypeDecl); ok {
+		p.print("typedef ")
+	}
+	for i, d := range decls {
+		typ, name := declType(d)
+		if i == 0 {
+			p.spec(specifier(typ))
+		} else {
+			p.print(",")
+		}
+		if d := p.declarator(typ, name.Name); d != "" {
+			p.print(" ", d)
+		}
+		if f, ok := d.(*ast.FuncDecl); ok && f.Body != nil {
+			p.print(" {}")
+			return
+		}
+	}
+	p.print(";")
+}
+
+// declType returns the type and name declared by d, or nil if d isn't a
+// typedef, variable or function declaration.
+func declType(d ast.Decl) (ast.Expr, *ast.Ident) {
+	switch d := d.(type) {
+	case *ast.TypeDecl:
+		return d.Type, d.Name
+	case *ast.VarDecl:
+		return d.Type, d.Name
+	case *ast.FuncDecl:
+		return d.Type, d.Name
+	}
+	return nil, nil
+}
+
+// sameSpecifiers reports whether the declaration b was declared along
+// with a, as in "int a, *b;".
+func sameSpecifiers(a, b ast.Node) bool {
+	if x, ok := a.(*ast.ExternDecl); ok {
+		y, ok := b.(*ast.ExternDecl)
+		if !ok || x.KeyPos != y.KeyPos {
+			return false
+		}
+		a, b = x.Decl, y.Decl
+	}
+	x, ok := a.(ast.Decl)
+	y, ok2 := b.(ast.Decl)
+	if !ok || !ok2 {
+		return false
+	}
+	if f, ok := x.(*ast.FuncDecl); ok && f.Body != nil {
+		return false
+	}
+	_, typedef := x.(*ast.TypeDecl)
+	_, typedef2 := y.(*ast.TypeDecl)
+	s, _ := declType(x)
+	t, _ := declType(y)
+	return typedef == typedef2 && s != nil && t != nil && specifier(s) == specifier(t)
+}
+
+// specifier returns the type of the declaration specifiers of a
+// declaration of type t.
+func specifier(t ast.Expr) ast.Expr {
+	for {
+		switch x := t.(type) {
+		case *ast.PointerType:
+			t = x.Elem
+		case *ast.ArrayType:
+			t = x.Elem
+		case *ast.FuncType:
+			t = x.Result
+		case *ast.QualType:
+			if _, ok := x.Type.(*ast.PointerType); !ok {
+				return t
+			}
+			t = x.Type
+		default:
+			return t
+		}
+	}
+}
+
+// declarator returns the declarator declaring name as type t, which is
+// derived from the type of the declaration specifiers. The name is empty
+// for abstract declarators.
+func (p *printer) declarator(t ast.Expr, name string) string {
+	d := name
+	pointer := false // whether d starts with a pointer declarator
+	for {
+		switch x := t.(type) {
+		case *ast.PointerType:
+			d, pointer, t = "*"+d, true, x.Elem
+			continue
+		case *ast.QualType:
+			if ptr, ok := x.Type.(*ast.PointerType); ok {
+				q := "*" + x.Qual.String()
+				if d != "" {
+					q += " "
+				}
+				d, pointer, t = q+d, true, ptr.Elem
+				continue
+			}
+		case *ast.ArrayType:
+			if pointer {
+				d = "(" + d + ")"
+			}
+			n := ""
+			if x.Len != nil {
+				n = p.expr(x.Len, assignPrec)
+			}
+			d, pointer, t = d+"["+n+"]", false, x.Elem
+			continue
+		case *ast.FuncType:
+			if pointer {
+				d = "(" + d + ")"
+			}
+			d, pointer, t = d+p.params(x.Params), false, x.Result
+			continue
+		}
+		return d
+	}
+}
+
+// declare prints the declaration of name as type t, without a semicolon.
+func (p *printer) declare(t ast.Expr, name string) {
+	p.spec(specifier(t))
+	switch d := p.declarator(t, name); {
+	case strings.HasPrefix(d, "["):
+		p.print(d)
+	case d != "":
+		p.print(" ", d)
+	}
+}
+
+// spec prints the type t of declaration specifiers.
+func (p *printer) spec(t ast.Expr) {
+	switch x := t.(type) {
+	case *ast.BasicType:
+		p.print(x.Name)
+	case *ast.Ident:
+		p.print(x.Name)
+	case *ast.QualType:
+		p.print(x.Qual.String(), " ")
+		p.spec(x.Type)
+	case *ast.StructType:
+		p.print(x.Kind.String())
+		if x.Name != nil {
+			p.print(" ", x.Name.Name)
+		}
+		if x.Fields != nil {
+			p.print(" ")
+			p.fields(x.Fields)
+		}
+		p.attrs(x.Attrs)
+	case *ast.EnumDecl:
+		p.print("enum")
+		if x.Name != nil {
+			p.print(" ", x.Name.Name)
+		}
+		if x.Specs != nil || x.Opening.IsValid() {
+			p.print(" ")
+			p.enumerators(x)
+		}
+	case *ast.Ellipsis:
+		p.print("...")
+	case *ast.BadExpr:
+		p.print("BadExpr")
+	default:
+		p.print(p.expr(t, commaPrec))
+	}
+}
+
+// fields prints the body of a struct type.
+func (p *printer) fields(list *ast.FieldList) {
+	if p.inline {
+		p.print("{")
+		forEachMember(list.List, func(fields []*ast.Field) {
+			p.print(" ")
+			p.member(fields)
+			p.print(";")
+		})
+		p.print(" }")
+		return
+	}
+
+	p.print("{")
+	p.line = p.lineOf(list.Opening)
+	if len(list.List) > 0 {
+		p.trailing(start(list.List[0]))
+	}
+	p.newline()
+	p.indent++
+	forEachMember(list.List, func(fields []*ast.Field) {
+		last := fields[len(fields)-1]
+		p.begin(start(fields[0]))
+		p.doc(fields[0].Doc)
+		p.member(fields)
+		p.print(";")
+		p.lineComment(last.Comment)
+		p.line = p.lineOf(end(last))
+		p.trailing(list.Closing)
+		p.newline()
+	})
+	if list.Closing.IsValid() {
+		p.flush(list.Closing)
+	}
+	p.indent--
+	p.print("}")
+}
+
+// forEachMember calls f with the fields of each member declaration in
+// list, as in "int a, *b;".
+func forEachMember(list []*ast.Field, f func([]*ast.Field)) {
+	for i := 0; i < len(list); {
+		j := i + 1
+		for j < len(list) && list[j].Name != nil && list[j-1].Name != nil &&
+			specifier(list[j].Type) == specifier(list[j-1].Type) && list[j].Doc == list[j-1].Doc {
+			j++
+		}
+		f(list[i:j])
+		i = j
+	}
+}
+
+// member prints the declaration of the struct members fields, which have
+// the same specifiers, without a semicolon.
+func (p *printer) member(fields []*ast.Field) {
+	for i, f := range fields {
+		name := ""
+		if f.Name != nil {
+			name = f.Name.Name
+		}
+		if i == 0 {
+			p.spec(specifier(f.Type))
+		} else {
+			p.print(",")
+		}
+		if d := p.declarator(f.Type, name); d != "" {
+			p.print(" ", d)
+		}
+		if f.Bits != nil {
+			p.print(" : ", p.expr(f.Bits, condPrec))
+		}
+		p.attrs(f.Attrs)
+	}
+}
+
+// attrs prints the attribute specifiers list, each preceded by a blank.
+func (p *printer) attrs(list []*ast.Attribute) {
+	for _, a := range list {
+		p.print(" ", p.attr(a))
+	}
+}
+
+// attr returns the source of the attribute specifier a.
+func (p *printer) attr(a *ast.Attribute) string {
+	list := make([]string, len(a.List))
+	for i, x := range a.List {
+		list[i] = p.expr(x, assignPrec)
+	}
+	return "__attribute__((" + strings.Join(list, ", ") + "))"
+}
+
+// enumerators prints the body of an enum type.
+func (p *printer) enumerators(e *ast.EnumDecl) {
+	if p.inline {
+		p.print("{")
+		for i, spec := range e.Specs {
+			if i > 0 {
+				p.print(",")
+			}
+			p.print(" ")
+			p.enumerator(spec)
+		}
+		p.print(" }")
+		return
+	}
+
+	p.print("{")
+	p.line = p.lineOf(e.Opening)
+	if len(e.Specs) > 0 {
+		p.trailing(start(e.Specs[0]))
+	}
+	p.newline()
+	p.indent++
+	for i, spec := range e.Specs {
+		p.begin(start(spec))
+		switch s := spec.(type) {
+		case *ast.EnumValue:
+			p.doc(s.Doc)
+		case *ast.EnumConstExpr:
+			p.doc(s.Doc)
+		}
+		p.enumerator(spec)
+		if i < len(e.Specs)-1 {
+			p.print(",")
+		}
+		switch s := spec.(type) {
+		case *ast.EnumValue:
+			p.lineComment(s.Comment)
+		case *ast.EnumConstExpr:
+			p.lineComment(s.Comment)
+		}
+		p.line = p.lineOf(end(spec))
+		p.trailing(e.Closing)
+		p.newline()
+	}
+	if e.Closing.IsValid() {
+		p.flush(e.Closing)
+	}
+	p.indent--
+	p.print("}")
+}
+
+// enumerator prints an enumerator without its comments.
+func (p *printer) enumerator(spec ast.EnumSpec) {
+	switch s := spec.(type) {
+	case *ast.EnumValue:
+		p.print(s.Name.Name)
+		if s.Value != nil {
+			p.print(" = ", s.Value.Value)
+		}
+	case *ast.EnumConstExpr:
+		p.print(s.Name.Name, " = ", p.expr(s.Expr, condPrec))
+	}
+}
+
+// args returns the argument list of a function-like macro.
+func (p *printer) args(l *ast.ArgList) string {
+	var list []string
+	for _, id := range l.List {
+		list = append(list, id.Name)
+	}
+	if l.Ellipsis != nil {
+		n := len(list)
+		if n > 0 && l.Ellipsis.Pos().IsValid() && l.Ellipsis.Pos() == l.List[n-1].End() {
+			// a named variable argument, as in "args..."
+			list[n-1] += "..."
+		} else {
+			list = append(list, "...")
+		}
+	}
+	return "(" + strings.Join(list, ", ") + ")"
+}
+
+// params returns the parameter list of a function type.
+func (p *printer) params(l *ast.FieldList) string {
+	if l == nil {
+		return "()"
+	}
+	var list []string
+	for _, f := range l.List {
+		list = append(list, p.inlineString(func(q *printer) { q.member([]*ast.Field{f}) }))
+	}
+	return "(" + strings.Join(list, ", ") + ")"
+}
+
+// inlineString returns the output of f, which prints on a single line.
+func (p *printer) inlineString(f func(q *printer)) string {
+	q := &printer{inline: true}
+	f(q)
+	return q.buf.String()
+}
+
+// ----------------------------------------------------------------------------
+// Expressions
+
+// Precedences of the operators that aren't binary operators, below the
+// precedences of the binary operators. Unary operators have precedence
+// token.UnaryPrec and postfix operators token.HighestPrec.
+const (
+	commaPrec  = -3
+	assignPrec = -2
+	condPrec   = -1
+)
+
+// precedence returns the precedence of the operator of x.
+func precedence(x ast.Expr) int {
+	switch x := x.(type) {
+	case *ast.BinaryExpr:
+		switch {
+		case x.Op == token.COMMA:
+			return commaPrec
+		case x.Op.IsAssign():
+			return assignPrec
+		}
+		return x.Op.Precedence()
+	case *ast.CondExpr:
+		return condPrec
+	case *ast.UnaryExpr, *ast.CastExpr, *ast.SizeofExpr, *ast.DefinedExpr:
+		return token.UnaryPrec
+	}
+	return token.HighestPrec
+}
+
+// isType reports whether x is a type, rather than an expression.
+func isType(x ast.Expr) bool {
+	switch x.(type) {
+	case *ast.BasicType, *ast.PointerType, *ast.ArrayType, *ast.QualType, *ast.StructType, *ast.FuncType, *ast.EnumDecl:
+		return true
+	}
+	return false
+}
+
+// expr returns the source of x as an operand of an operator with
+// precedence prec, in parentheses if x binds less tightly.
+func (p *printer) expr(x ast.Expr, prec int) string {
+	s := p.expr1(x)
+	if precedence(x) < prec {
+		s = "(" + s + ")"
+	}
+	return s
+}
+
+func (p *printer) expr1(x ast.Expr) string {
+	switch x := x.(type) {
+	case *ast.BadExpr:
+		return "BadExpr"
+	case *ast.Ident:
+		return x.Name
+	case *ast.BasicLit:
+		return x.Value
+	case *ast.UnaryExpr:
+		op, y := x.Op.String(), p.expr(x.X, token.UnaryPrec)
+		if (x.Op == token.ADD || x.Op == token.SUB || x.Op == token.AND) && strings.HasPrefix(y, op) {
+			// keep "- -x" from becoming "--x"
+			op += " "
+		}
+		return op + y
+	case *ast.BinaryExpr:
+		switch prec := precedence(x); {
+		case x.Op == token.COMMA:
+			return p.expr(x.X, commaPrec) + ", " + p.expr(x.Y, assignPrec)
+		case prec == assignPrec:
+			// assignments are right associative
+			return p.expr(x.X, condPrec) + " " + x.Op.String() + " " + p.expr(x.Y, assignPrec)
+		default:
+			return p.expr(x.X, prec) + " " + x.Op.String() + " " + p.expr(x.Y, prec+1)
+		}
+	case *ast.ParenExpr:
+		if x.Expr == nil {
+			return "()"
+		}
+		return "(" + p.expr(x.Expr, commaPrec) + ")"
+	case *ast.CondExpr:
+		return p.expr(x.Cond, token.LowestPrec+1) + " ? " + p.expr(x.X, commaPrec) + " : " + p.expr(x.Y, condPrec)
+	case *ast.CastExpr:
+		return "(" + p.typeString(x.Type) + ")" + p.expr(x.X, token.UnaryPrec)
+	case *ast.IndexExpr:
+		return p.expr(x.X, token.HighestPrec) + "[" + p.expr(x.Index, commaPrec) + "]"
+	case *ast.CallExpr:
+		var args []string
+		for _, arg := range x.Args {
+			args = append(args, p.expr(arg, assignPrec))
+		}
+		return p.expr(x.Fun, token.HighestPrec) + "(" + strings.Join(args, ", ") + ")"
+	case *ast.SelectorExpr:
+		return p.expr(x.X, token.HighestPrec) + x.Op.String() + x.Sel.Name
+	case *ast.PostfixExpr:
+		return p.expr(x.X, token.HighestPrec) + x.Op.String()
+	case *ast.SizeofExpr:
+		if x.Opening.IsValid() || isType(x.X) {
+			return "sizeof(" + p.typeString(x.X) + ")"
+		}
+		y := p.expr(x.X, token.UnaryPrec)
+		if strings.HasPrefix(y, "(") {
+			return "sizeof" + y
+		}
+		return "sizeof " + y
+	case *ast.DefinedExpr:
+		if x.Closing.IsValid() {
+			return "defined(" + x.Name.Name + ")"
+		}
+		return "defined " + x.Name.Name
+	}
+	return p.typeString(x)
+}
+
+// typeString returns the type name of t, as used in casts.
+func (p *printer) typeString(t ast.Expr) string {
+	return p.inlineString(func(q *printer) { q.declare(t, "") })
+}
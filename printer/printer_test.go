@@ -0,0 +1,271 @@
+package printer
+
+import (
+	"bytes"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/parser"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+var posType = reflect.TypeOf(token.NoPos)
+
+// clearPos sets the positions in the tree of v to NoPos.
+func clearPos(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if !v.IsNil() {
+			clearPos(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Field(i); f.Type() == posType {
+				f.SetInt(0)
+			} else if f.CanSet() {
+				clearPos(f)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			clearPos(v.Index(i))
+		}
+	}
+}
+
+func parse(t *testing.T, filename string, src []byte) (*token.FileSet, *ast.File) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fset, f
+}
+
+// roundTrip prints the file parsed from src, parses the output and
+// compares the nodes and comments of both files. It returns the output.
+func roundTrip(t *testing.T, filename string, src []byte) []byte {
+	fset, f := parse(t, filename, src)
+	var buf bytes.Buffer
+	if err := Fprint(&buf, fset, f); err != nil {
+		t.Fatal(err)
+	}
+
+	_, g := parse(t, filename, buf.Bytes())
+	for _, x := range []interface{}{&f.Nodes, &f.Comments, &g.Nodes, &g.Comments} {
+		clearPos(reflect.ValueOf(x))
+	}
+	if !reflect.DeepEqual(g.Nodes, f.Nodes) {
+		t.Errorf("nodes differ after printing:\n%s", buf.Bytes())
+	}
+	if !reflect.DeepEqual(g.Comments, f.Comments) {
+		t.Errorf("comments differ after printing:\n%s", buf.Bytes())
+	}
+	return buf.Bytes()
+}
+
+// TestFprint_RoundTrip parses, prints and parses the files of the
+// testdata corpus, which is formatted like the printer formats it.
+func TestFprint_RoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.h"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range files {
+		t.Run(filepath.Base(filename), func(t *testing.T) {
+			src, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := roundTrip(t, filename, src); string(got) != string(src) {
+				t.Errorf("got:\n%s\nwant:\n%s", got, src)
+			}
+		})
+	}
+}
+
+// TestFprint_RoundTripCorpora parses, prints and parses the headers of
+// the preprocessor tests and the inputs of the parser tests. They aren't
+// formatted, so only the trees are compared.
+func TestFprint_RoundTripCorpora(t *testing.T) {
+	var files []string
+	err := filepath.Walk(filepath.Join("..", "preprocessor", "testdata"), func(path string, info os.FileInfo, err error) error {
+		if err == nil && filepath.Ext(path) == ".h" {
+			files = append(files, path)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, filename := range files {
+		t.Run(filename, func(t *testing.T) {
+			src, err := ioutil.ReadFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			skipBad(t, filename, src)
+			roundTrip(t, filename, src)
+		})
+	}
+
+	for _, input := range parserInputs(t) {
+		t.Run(input, func(t *testing.T) {
+			skipBad(t, "test.h", []byte(input))
+			roundTrip(t, "test.h", []byte(input))
+		})
+	}
+}
+
+// skipBad skips the test if src has syntax errors or bad nodes, which
+// are printed as placeholders.
+func skipBad(t *testing.T, filename string, src []byte) {
+	f, err := parser.ParseFile(token.NewFileSet(), filename, src, parser.ParseComments)
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, node := range f.Nodes {
+		ast.Inspect(node, func(n ast.Node) bool {
+			switch n.(type) {
+			case *ast.BadDir, *ast.BadStmt, *ast.BadExpr:
+				t.Skipf("%T", n)
+			}
+			return true
+		})
+	}
+}
+
+// parserInputs returns the Input strings of the table-driven tests of
+// the parser package.
+func parserInputs(t *testing.T) []string {
+	fset := gotoken.NewFileSet()
+	pkgs, err := goparser.ParseDir(fset, filepath.Join("..", "parser"), func(info os.FileInfo) bool {
+		return strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inputs []string
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			goast.Inspect(f, func(n goast.Node) bool {
+				table, ok := n.(*goast.CompositeLit)
+				if !ok {
+					return true
+				}
+				typ, ok := table.Type.(*goast.ArrayType)
+				if !ok {
+					return true
+				}
+				st, ok := typ.Elt.(*goast.StructType)
+				if !ok {
+					return true
+				}
+				field := -1
+				for i, f := range st.Fields.List {
+					if len(f.Names) == 1 && f.Names[0].Name == "Input" {
+						field = i
+					}
+				}
+				if field < 0 {
+					return true
+				}
+				for _, elt := range table.Elts {
+					test, ok := elt.(*goast.CompositeLit)
+					if !ok || len(test.Elts) <= field {
+						continue
+					}
+					lit, ok := test.Elts[field].(*goast.BasicLit)
+					if !ok || lit.Kind != gotoken.STRING {
+						continue
+					}
+					if input, err := strconv.Unquote(lit.Value); err == nil {
+						inputs = append(inputs, input)
+					}
+				}
+				return false
+			})
+		}
+	}
+	return inputs
+}
+
+func TestFprint(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value string
+	}{
+		{"#define  X  1", "#define X 1\n"},
+		{"#define F( a,b , ... ) a", "#define F(a, b, ...) a\n"},
+		{"#if  !defined   X\n#endif", "#if !defined X\n#endif\n"},
+		{"#define X \\\n\t(1 + \\\n\t 2)", "#define X (1 + 2)\n"},
+		{"long int const x;", "const long x;\n"},
+		{"int\n*a ,b[2];", "int *a, b[2];\n"},
+		{"struct s { int a; } x, *y;", "struct s {\n\tint a;\n} x, *y;\n"},
+		{"struct s { struct { int a; } in; };", "struct s {\n\tstruct {\n\t\tint a;\n\t} in;\n};\n"},
+		{"void f(struct { int a; } x);", "void f(struct { int a; } x);\n"},
+		{"enum e { A, B, };", "enum e {\n\tA,\n\tB\n};\n"},
+		{"int f();\n\n\n\nint g();", "int f();\n\nint g();\n"},
+		{"extern \"C\" {\nint x;\n}", "extern \"C\" {\nint x;\n}\n"},
+		{"int x;;", "int x;\nBadExpr\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			fset := token.NewFileSet()
+			f, _ := parser.ParseFile(fset, "test.h", test.Input, parser.ParseComments)
+			var buf bytes.Buffer
+			if err := Fprint(&buf, fset, f); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != test.Value {
+				t.Errorf("got:\n%s\nwant:\n%s", got, test.Value)
+			}
+		})
+	}
+}
+
+func TestFprint_Expr(t *testing.T) {
+	a, b, c := &ast.Ident{Name: "a"}, &ast.Ident{Name: "b"}, &ast.Ident{Name: "c"}
+	binary := func(x ast.Expr, op token.Token, y ast.Expr) ast.Expr {
+		return &ast.BinaryExpr{X: x, Op: op, Y: y}
+	}
+	tests := []struct {
+		Input ast.Node
+		Value string
+	}{
+		{binary(binary(a, token.ADD, b), token.MUL, c), "(a + b) * c"},
+		{binary(a, token.ADD, binary(b, token.MUL, c)), "a + b * c"},
+		{binary(binary(a, token.SUB, b), token.SUB, c), "a - b - c"},
+		{binary(a, token.SUB, binary(b, token.SUB, c)), "a - (b - c)"},
+		{binary(a, token.ASSIGN, binary(b, token.ASSIGN, c)), "a = b = c"},
+		{binary(binary(a, token.COMMA, b), token.COMMA, c), "a, b, c"},
+		{&ast.CallExpr{Fun: a, Args: []ast.Expr{binary(b, token.COMMA, c)}}, "a((b, c))"},
+		{&ast.UnaryExpr{Op: token.SUB, X: &ast.UnaryExpr{Op: token.SUB, X: a}}, "- -a"},
+		{&ast.UnaryExpr{Op: token.NOT, X: binary(a, token.LAND, b)}, "!(a && b)"},
+		{&ast.IndexExpr{X: &ast.UnaryExpr{Op: token.MUL, X: a}, Index: b}, "(*a)[b]"},
+		{&ast.CastExpr{Type: &ast.PointerType{Elem: &ast.BasicType{Name: "char"}}, X: binary(a, token.ADD, b)}, "(char *)(a + b)"},
+		{&ast.CondExpr{Cond: &ast.CondExpr{Cond: a, X: b, Y: c}, X: b, Y: &ast.CondExpr{Cond: a, X: b, Y: c}}, "(a ? b : c) ? b : a ? b : c"},
+		{&ast.SizeofExpr{X: &ast.ArrayType{Elem: &ast.BasicType{Name: "int"}, Len: &ast.BasicLit{Value: "4"}}}, "sizeof(int[4])"},
+		{&ast.PointerType{Elem: &ast.FuncType{Result: &ast.BasicType{Name: "void"}, Params: &ast.FieldList{}}}, "void (*)()"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := Fprint(&buf, nil, test.Input); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != test.Value {
+			t.Errorf("got %q, want %q", got, test.Value)
+		}
+	}
+}
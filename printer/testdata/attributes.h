@@ -0,0 +1,9 @@
+struct packed {
+	char c;
+	int i;
+} __attribute__((packed));
+
+typedef struct {
+	char c __attribute__((aligned(8)));
+	unsigned int flag : 1 __attribute__((packed));
+} __attribute__((aligned(16), may_alias)) aligned_t;
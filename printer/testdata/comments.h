@@ -0,0 +1,32 @@
+// Copyright notice.
+// Second line.
+
+/*
+ * A block comment
+ * spanning lines.
+ */
+
+// Size of a buffer.
+#define SIZE 16 /* bytes */
+
+// A buffer.
+struct buf {
+	/* Number of bytes used. */
+	int len; // <= SIZE
+	// unused
+
+	char data[SIZE];
+};
+
+enum mode {
+	// Reading.
+	READ = 1, // r
+	WRITE /* w */
+};
+
+/// Frees b.
+void free_buf(struct buf *b);
+
+typedef int handle; // an index
+
+/* trailing */
@@ -0,0 +1,10 @@
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+int init(void);
+void shutdown(void);
+
+#ifdef __cplusplus
+}
+#endif
@@ -0,0 +1,43 @@
+typedef unsigned int uint;
+typedef long long int64, *int64_ptr;
+typedef const char *string;
+typedef char *const const_ptr;
+typedef void (*callback)(void *data, int status);
+typedef int (*table)[4];
+typedef int (*(*factory)(void))(int);
+typedef int matrix[3][4];
+
+struct point {
+	int x, y;
+};
+
+typedef struct list {
+	struct list *next;
+	void *value;
+	unsigned int flags : 3;
+	unsigned int : 5;
+	union {
+		int i;
+		double d;
+	};
+	char name[];
+} list;
+
+typedef union {
+	float f;
+	unsigned char bytes[sizeof(float)];
+} bits;
+
+enum color;
+struct opaque;
+
+extern int errno_value;
+extern const char *const names[];
+int count, *counts[16];
+
+void free(void *ptr);
+char *strdup(const char *s);
+int printf(const char *format, ...);
+void (*signal(int sig, void (*handler)(int)))(int);
+int max(int a, int b) {}
+unsigned long size(const struct point *p, volatile int *restrict q);
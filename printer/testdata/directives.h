@@ -0,0 +1,37 @@
+/* Directives of a typical library header. */
+
+#ifndef DIRECTIVES_H
+#define DIRECTIVES_H
+
+#include <stddef.h>
+#include "config.h"
+
+#pragma once
+#pragma pack(push, 4)
+
+#if defined(_WIN32) && !defined(__MINGW32__)
+#define API __declspec(dllexport)
+#elif __GNUC__ >= 4
+#define API __attribute__((visibility("default")))
+#else
+#define API
+#endif
+
+#ifdef API_DEBUG
+#undef NDEBUG
+#endif
+
+#define VERSION_MAJOR 2
+#define VERSION_MINOR 10
+#define VERSION (VERSION_MAJOR << 16 | VERSION_MINOR)
+#define MAX(a, b) ((a) > (b) ? (a) : (b))
+#define LOG(fmt, ...) log_printf(__FILE__, __LINE__, fmt, __VA_ARGS__)
+#define TRACE(args...) trace(args)
+#define EMPTY()
+#define NAME "library"
+#define PI 3.14159265358979f
+#define MASK (~0UL >> 1)
+
+#pragma pack(pop)
+
+#endif
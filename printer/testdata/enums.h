@@ -0,0 +1,14 @@
+enum level {
+	LEVEL_DEBUG,
+	LEVEL_INFO = 10,
+	LEVEL_WARN = LEVEL_INFO + 10,
+	LEVEL_ERROR = 1 << 5
+};
+
+typedef enum {
+	FLAG_A = 0x1,
+	FLAG_B = 0x2,
+	FLAG_AB = FLAG_A | FLAG_B
+} flags;
+
+enum level current_level(void);
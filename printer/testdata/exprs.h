@@ -0,0 +1,15 @@
+#define A (1 + 2 * 3)
+#define B ((1 + 2) * 3)
+#define C (a - (b - c))
+#define D (-x + -(-y) - - -z)
+#define E (!a && (b || c) ? d : e ? f : g)
+#define F ((int)x + (unsigned char)(y >> 2))
+#define G (sizeof(int) + sizeof x + sizeof(y) + sizeof(struct point *))
+#define H (p->next->value.field[i + 1](x, y))
+#define I (x++ + ++y - z-- & ~mask)
+#define J (a = b += c, d)
+#define K ((const char *)s)
+#define M 'c'
+#define N 1.5e-3L
+#if defined FOO || defined(BAR) && FOO_VERSION >= 0x0100
+#endif
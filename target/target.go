@@ -0,0 +1,219 @@
+// Package target describes the platforms headers are read for: the
+// macros a C compiler predefines for a platform, which select the
+// branches of conditional directives, and the layout of the C types.
+//
+// A Target is named by a Go port and, optionally, a compiler:
+//
+//	linux/amd64        GCC for linux/amd64
+//	darwin/arm64       Clang for darwin/arm64
+//	windows/amd64/gcc  MinGW-w64 for windows/amd64
+package target
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SHyx0rmZ/cgen/constant"
+	"github.com/SHyx0rmZ/cgen/types"
+)
+
+// A Target is a platform and the C compiler used for it.
+type Target struct {
+	GOOS     string
+	GOARCH   string
+	Compiler string      // "gcc", "clang" or "msvc"
+	Sizes    types.Sizes // layout of the C types
+}
+
+// ports lists the architectures supported for each operating system.
+var ports = map[string][]string{
+	"darwin":  {"amd64", "arm64"},
+	"freebsd": {"386", "amd64", "arm", "arm64", "riscv64"},
+	"linux":   {"386", "amd64", "arm", "arm64", "ppc64", "ppc64le", "riscv64", "s390x"},
+	"windows": {"386", "amd64", "arm64"},
+}
+
+// defaultCompilers are the compilers of the operating systems that
+// don't use GCC by default.
+var defaultCompilers = map[string]string{
+	"darwin":  "clang",
+	"windows": "msvc",
+}
+
+// Parse returns the target named "goos/goarch" or
+// "goos/goarch/compiler". The compiler defaults to msvc on windows,
+// clang on darwin and gcc elsewhere.
+func Parse(name string) (*Target, error) {
+	parts := strings.Split(name, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return nil, fmt.Errorf("invalid target %q: want goos/goarch[/compiler]", name)
+	}
+	t := &Target{GOOS: parts[0], GOARCH: parts[1], Compiler: "gcc"}
+	if c, ok := defaultCompilers[t.GOOS]; ok {
+		t.Compiler = c
+	}
+	if len(parts) == 3 {
+		t.Compiler = parts[2]
+	}
+
+	supported := false
+	for _, arch := range ports[t.GOOS] {
+		supported = supported || arch == t.GOARCH
+	}
+	if !supported {
+		return nil, fmt.Errorf("unknown target %s/%s", t.GOOS, t.GOARCH)
+	}
+	switch t.Compiler {
+	case "gcc", "clang":
+	case "msvc":
+		if t.GOOS != "windows" {
+			return nil, fmt.Errorf("unknown target %s: msvc only targets windows", name)
+		}
+	default:
+		return nil, fmt.Errorf("unknown compiler %q", t.Compiler)
+	}
+	t.Sizes = types.SizesFor(t.GOOS, t.GOARCH)
+	if t.GOOS == "windows" && t.GOARCH == "amd64" && t.Compiler != "msvc" {
+		// MinGW-w64 keeps the 80-bit long double of x87.
+		sizes := *types.LLP64
+		sizes.LongDoubleSize, sizes.MaxAlign = 16, 16
+		t.Sizes = &sizes
+	}
+	return t, nil
+}
+
+// List returns the names of the supported Go ports, sorted.
+func List() []string {
+	var names []string
+	for goos, archs := range ports {
+		for _, goarch := range archs {
+			names = append(names, goos+"/"+goarch)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String returns the name of t, "goos/goarch/compiler".
+func (t *Target) String() string {
+	return t.GOOS + "/" + t.GOARCH + "/" + t.Compiler
+}
+
+// Model returns the model of the integer types of t, for constant
+// expressions.
+func (t *Target) Model() constant.Model {
+	return constant.Model{Long: int(8 * t.sizeof("long")), CharUnsigned: t.charUnsigned()}
+}
+
+// charUnsigned reports whether plain char is unsigned on t. It is on the
+// ARM, POWER, RISC-V and Z architectures, except on darwin and windows.
+func (t *Target) charUnsigned() bool {
+	if t.GOOS == "darwin" || t.GOOS == "windows" {
+		return false
+	}
+	switch t.GOARCH {
+	case "arm", "arm64", "ppc64", "ppc64le", "riscv64", "s390x":
+		return true
+	}
+	return false
+}
+
+// sizeof returns the size of the builtin type name on t, or of pointers
+// for "void *".
+func (t *Target) sizeof(name string) int64 {
+	if name == "void *" {
+		return t.Sizes.Sizeof(&types.Pointer{})
+	}
+	return t.Sizes.Sizeof(&types.Basic{Name: name})
+}
+
+// Macros returns the macros predefined by the compiler of t, in the
+// syntax of the -D option of C compilers: "NAME" or "NAME=VALUE".
+func (t *Target) Macros() []string {
+	if t.Compiler == "msvc" {
+		macros := []string{"_MSC_VER=1938", "_MSC_FULL_VER=193833130", "_WIN32=1", "_INTEGRAL_MAX_BITS=64"}
+		if t.sizeof("void *") == 8 {
+			macros = append(macros, "_WIN64=1")
+		}
+		return append(macros, msvcArchs[t.GOARCH]...)
+	}
+
+	var macros []string
+	if t.Compiler == "clang" {
+		macros = append(macros, "__clang__=1", "__clang_major__=17", "__clang_minor__=0", "__clang_patchlevel__=0",
+			"__GNUC__=4", "__GNUC_MINOR__=2", "__GNUC_PATCHLEVEL__=1")
+	} else {
+		macros = append(macros, "__GNUC__=13", "__GNUC_MINOR__=2", "__GNUC_PATCHLEVEL__=0")
+	}
+	macros = append(macros,
+		"__STDC__=1",
+		"__STDC_VERSION__=201710L",
+		"__STDC_HOSTED__=1",
+		"__CHAR_BIT__=8",
+		"__ORDER_LITTLE_ENDIAN__=1234",
+		"__ORDER_BIG_ENDIAN__=4321",
+		"__ORDER_PDP_ENDIAN__=3412",
+	)
+	if t.GOARCH == "ppc64" || t.GOARCH == "s390x" {
+		macros = append(macros, "__BYTE_ORDER__=__ORDER_BIG_ENDIAN__")
+	} else {
+		macros = append(macros, "__BYTE_ORDER__=__ORDER_LITTLE_ENDIAN__")
+	}
+	for _, s := range []struct{ macro, typ string }{
+		{"__SIZEOF_SHORT__", "short"},
+		{"__SIZEOF_INT__", "int"},
+		{"__SIZEOF_LONG__", "long"},
+		{"__SIZEOF_LONG_LONG__", "long long"},
+		{"__SIZEOF_FLOAT__", "float"},
+		{"__SIZEOF_DOUBLE__", "double"},
+		{"__SIZEOF_LONG_DOUBLE__", "long double"},
+		{"__SIZEOF_POINTER__", "void *"},
+		{"__SIZEOF_SIZE_T__", "void *"},
+	} {
+		macros = append(macros, fmt.Sprintf("%s=%d", s.macro, t.sizeof(s.typ)))
+	}
+	if t.sizeof("long") == 8 {
+		macros = append(macros, "_LP64=1", "__LP64__=1")
+	}
+	if t.charUnsigned() {
+		macros = append(macros, "__CHAR_UNSIGNED__=1")
+	}
+	macros = append(macros, osMacros[t.GOOS]...)
+	if t.GOOS == "windows" && t.sizeof("void *") == 8 {
+		macros = append(macros, "_WIN64=1", "__MINGW64__=1")
+	}
+	macros = append(macros, archMacros[t.GOARCH]...)
+	if t.GOOS == "darwin" && t.GOARCH == "arm64" {
+		macros = append(macros, "__arm64__=1")
+	}
+	return macros
+}
+
+// osMacros are the macros GCC and Clang predefine for an operating
+// system.
+var osMacros = map[string][]string{
+	"darwin":  {"__APPLE__=1", "__MACH__=1"},
+	"freebsd": {"__FreeBSD__=14", "__unix__=1", "__unix=1", "__ELF__=1"},
+	"linux":   {"__linux__=1", "__linux=1", "__gnu_linux__=1", "__unix__=1", "__unix=1", "__ELF__=1"},
+	"windows": {"_WIN32=1", "__WIN32__=1", "__MINGW32__=1"},
+}
+
+// archMacros are the macros GCC and Clang predefine for an architecture.
+var archMacros = map[string][]string{
+	"386":     {"__i386__=1", "__i386=1"},
+	"amd64":   {"__x86_64__=1", "__x86_64=1", "__amd64__=1", "__amd64=1"},
+	"arm":     {"__arm__=1", "__ARMEL__=1", "__ARM_ARCH=7"},
+	"arm64":   {"__aarch64__=1", "__ARM_ARCH=8"},
+	"ppc64":   {"__powerpc__=1", "__powerpc64__=1", "__PPC__=1", "__PPC64__=1", "_ARCH_PPC64=1", "__BIG_ENDIAN__=1", "_BIG_ENDIAN=1"},
+	"ppc64le": {"__powerpc__=1", "__powerpc64__=1", "__PPC__=1", "__PPC64__=1", "_ARCH_PPC64=1", "__LITTLE_ENDIAN__=1", "_LITTLE_ENDIAN=1", "_CALL_ELF=2"},
+	"riscv64": {"__riscv=1", "__riscv_xlen=64"},
+	"s390x":   {"__s390__=1", "__s390x__=1", "__zarch__=1"},
+}
+
+// msvcArchs are the macros MSVC predefines for an architecture.
+var msvcArchs = map[string][]string{
+	"386":   {"_M_IX86=600"},
+	"amd64": {"_M_X64=100", "_M_AMD64=100"},
+	"arm64": {"_M_ARM64=1"},
+}
@@ -0,0 +1,145 @@
+package target
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SHyx0rmZ/cgen/constant"
+	"github.com/SHyx0rmZ/cgen/lexer"
+	"github.com/SHyx0rmZ/cgen/preprocessor"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value string
+	}{
+		{"linux/amd64", "linux/amd64/gcc"},
+		{"linux/arm64/clang", "linux/arm64/clang"},
+		{"darwin/arm64", "darwin/arm64/clang"},
+		{"windows/amd64", "windows/amd64/msvc"},
+		{"windows/386/gcc", "windows/386/gcc"},
+		{"linux", `invalid target "linux": want goos/goarch[/compiler]`},
+		{"plan9/amd64", "unknown target plan9/amd64"},
+		{"darwin/386", "unknown target darwin/386"},
+		{"linux/amd64/msvc", "unknown target linux/amd64/msvc: msvc only targets windows"},
+		{"linux/amd64/tcc", `unknown compiler "tcc"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			target, err := Parse(test.Input)
+			got := ""
+			if err != nil {
+				got = err.Error()
+			} else {
+				got = target.String()
+			}
+			if got != test.Value {
+				t.Errorf("got %s, want %s", got, test.Value)
+			}
+		})
+	}
+}
+
+func TestTarget_Macros(t *testing.T) {
+	src := `#if defined(_MSC_VER)
+msvc
+#elif defined(__clang__)
+clang
+#elif __GNUC__ >= 4
+gcc
+#endif
+#if defined(__linux__) && defined(__x86_64__)
+linux_x86_64
+#elif defined(__APPLE__) && defined(__aarch64__)
+darwin_aarch64
+#elif defined(_WIN64)
+win64
+#elif defined(_WIN32)
+win32
+#endif
+#if __SIZEOF_LONG__ == 8 && __SIZEOF_POINTER__ == 8
+lp64
+#elif __SIZEOF_LONG__ == 4 && __SIZEOF_POINTER__ == 4
+ilp32
+#elif defined(_WIN64)
+llp64
+#endif
+#ifdef __CHAR_UNSIGNED__
+unsigned_char
+#endif
+#if defined(__BYTE_ORDER__) && __BYTE_ORDER__ == __ORDER_BIG_ENDIAN__
+big_endian
+#endif
+`
+	tests := []struct {
+		Input string
+		Value string
+	}{
+		{"linux/amd64", "gcc linux_x86_64 lp64"},
+		{"linux/arm64", "gcc lp64 unsigned_char"},
+		{"linux/386", "gcc ilp32"},
+		{"linux/riscv64", "gcc lp64 unsigned_char"},
+		{"linux/ppc64le", "gcc lp64 unsigned_char"},
+		{"linux/s390x", "gcc lp64 unsigned_char big_endian"},
+		{"darwin/arm64", "clang darwin_aarch64 lp64"},
+		{"windows/amd64", "msvc win64 llp64"},
+		{"windows/amd64/gcc", "gcc win64 llp64"},
+		{"windows/386", "msvc win32"},
+		{"windows/386/clang", "clang win32 ilp32"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			target, err := Parse(test.Input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pp := preprocessor.New(lexer.NewLexer("test.h", src))
+			for _, def := range target.Macros() {
+				if err := pp.Predefine(def); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var words []string
+			for item := pp.NextItem(); item.Tok != token.EOF; item = pp.NextItem() {
+				if item.Tok == token.ILLEGAL {
+					t.Fatal(item.Val)
+				}
+				if item.Tok == token.IDENT {
+					words = append(words, item.Val)
+				}
+			}
+			if got := strings.Join(words, " "); got != test.Value {
+				t.Errorf("got %s, want %s", got, test.Value)
+			}
+		})
+	}
+}
+
+func TestTarget_Model(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value constant.Model
+	}{
+		{"linux/amd64", constant.Model{Long: 64}},
+		{"linux/arm", constant.Model{Long: 32, CharUnsigned: true}},
+		{"linux/ppc64", constant.Model{Long: 64, CharUnsigned: true}},
+		{"darwin/arm64", constant.Model{Long: 64}},
+		{"windows/amd64", constant.Model{Long: 32}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			target, err := Parse(test.Input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := target.Model(); got != test.Value {
+				t.Errorf("got %+v, want %+v", got, test.Value)
+			}
+		})
+	}
+}
@@ -1,3 +1,236 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Pos is a compact encoding of a source position within a file set. It
+// can be converted into a Position for a more convenient, but much
+// larger, representation.
+//
+// Positions within a File created by NewFile are plain byte offsets.
 type Pos int
+
+// NoPos is the zero value for Pos within a file set.
+const NoPos Pos = 0
+
+// IsValid reports whether the position is valid within a file set.
+func (p Pos) IsValid() bool {
+	return p != NoPos
+}
+
+// A Position describes a source position including the file, line and
+// column location. A Position is valid if the line number is > 0.
+type Position struct {
+	Filename string
+	Offset   int // offset, starting at 0
+	Line     int // line number, starting at 1
+	Column   int // column number, starting at 1 (byte count)
+}
+
+// IsValid reports whether the position is valid.
+func (pos *Position) IsValid() bool { return pos.Line > 0 }
+
+// String returns a string in one of several forms:
+//
+//	file:line:column    valid position with file name
+//	line:column         valid position without file name
+//	file                invalid position with file name
+//	-                   invalid position without file name
+func (pos Position) String() string {
+	s := pos.Filename
+	if pos.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
+// A File is a handle for a file belonging to a FileSet. A File has a
+// name, size and line offset table.
+type File struct {
+	name string
+	base int
+	size int
+
+	mutex sync.Mutex
+	lines []int // offsets of the first character of each line
+}
+
+// Name returns the file name of f as registered with AddFile.
+func (f *File) Name() string { return f.name }
+
+// Base returns the base offset of f as registered with AddFile.
+func (f *File) Base() int { return f.base }
+
+// Size returns the size of f as registered with AddFile.
+func (f *File) Size() int { return f.size }
+
+// LineCount returns the number of lines in f.
+func (f *File) LineCount() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.lines)
+}
+
+// Lines returns the offsets of the first character of each line.
+func (f *File) Lines() []int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]int(nil), f.lines...)
+}
+
+// AddLine adds the line offset for a new line. The line offset must be
+// larger than the offset of the previous line and smaller than the file
+// size; otherwise it is ignored.
+func (f *File) AddLine(offset int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if i := len(f.lines); (i == 0 || f.lines[i-1] < offset) && offset < f.size {
+		f.lines = append(f.lines, offset)
+	}
+}
+
+// SetLinesForContent sets the line offsets for the given file content.
+func (f *File) SetLinesForContent(content []byte) {
+	var lines []int
+	line := 0
+	for offset, b := range content {
+		if line >= 0 {
+			lines = append(lines, line)
+		}
+		line = -1
+		if b == '\n' {
+			line = offset + 1
+		}
+	}
+
+	f.mutex.Lock()
+	f.lines = lines
+	f.mutex.Unlock()
+}
+
+// Pos returns the Pos value for the given file offset.
+func (f *File) Pos(offset int) Pos {
+	if offset > f.size {
+		panic("illegal file offset")
+	}
+	return Pos(f.base + offset)
+}
+
+// Offset returns the offset for the given file position p.
+func (f *File) Offset(p Pos) int {
+	if int(p) < f.base || int(p) > f.base+f.size {
+		panic("illegal Pos value")
+	}
+	return int(p) - f.base
+}
+
+// Line returns the line number for the given file position p.
+func (f *File) Line(p Pos) int {
+	return f.Position(p).Line
+}
+
+// Position returns the Position value for the given file position p.
+func (f *File) Position(p Pos) (pos Position) {
+	offset := f.Offset(p)
+	pos.Filename = f.name
+	pos.Offset = offset
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if i := sort.SearchInts(f.lines, offset+1) - 1; i >= 0 {
+		pos.Line, pos.Column = i+1, offset-f.lines[i]+1
+	}
+	return
+}
+
+// NewFile returns a File that doesn't belong to a file set. Its base is
+// 0, so the positions within it are plain byte offsets.
+func NewFile(filename string, size int) *File {
+	return &File{
+		name:  filename,
+		size:  size,
+		lines: []int{0},
+	}
+}
+
+// A FileSet represents a set of source files. The position ranges of
+// the files don't overlap, so a Pos identifies both a file and an offset
+// within it.
+type FileSet struct {
+	mutex sync.RWMutex
+	base  int
+	files []*File
+}
+
+// NewFileSet creates a new file set.
+func NewFileSet() *FileSet {
+	return &FileSet{
+		base: 1, // 0 == NoPos
+	}
+}
+
+// Base returns the minimum base offset that must be provided to AddFile
+// when adding the next file.
+func (s *FileSet) Base() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.base
+}
+
+// AddFile adds a new file with a given filename, base offset, and file
+// size to the file set s and returns the file. If base is negative, the
+// current value of Base is used instead.
+func (s *FileSet) AddFile(filename string, base, size int) *File {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if base < 0 {
+		base = s.base
+	}
+	if base < s.base || size < 0 {
+		panic("illegal base or size")
+	}
+	f := &File{
+		name:  filename,
+		base:  base,
+		size:  size,
+		lines: []int{0},
+	}
+	// +1 because EOF also has a position
+	s.base = base + size + 1
+	s.files = append(s.files, f)
+	return f
+}
+
+// File returns the file that contains the position p. If no such file
+// is found, File returns nil.
+func (s *FileSet) File(p Pos) *File {
+	if p == NoPos {
+		return nil
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	i := sort.Search(len(s.files), func(i int) bool {
+		return s.files[i].base > int(p)
+	}) - 1
+	if i >= 0 && int(p) <= s.files[i].base+s.files[i].size {
+		return s.files[i]
+	}
+	return nil
+}
+
+// Position converts a Pos p in the file set into a Position value.
+func (s *FileSet) Position(p Pos) Position {
+	if f := s.File(p); f != nil {
+		return f.Position(p)
+	}
+	return Position{}
+}
@@ -0,0 +1,30 @@
+package token
+
+import "testing"
+
+func TestFileSet_Position(t *testing.T) {
+	fset := NewFileSet()
+	a := fset.AddFile("a.h", -1, 6)
+	a.SetLinesForContent([]byte("ab\ncd\n"))
+	b := fset.AddFile("b.h", -1, 3)
+	b.SetLinesForContent([]byte("x\ny"))
+
+	tests := []struct {
+		Pos   Pos
+		Value string
+	}{
+		{NoPos, "-"},
+		{a.Pos(0), "a.h:1:1"},
+		{a.Pos(4), "a.h:2:2"},
+		{a.Pos(6), "a.h:2:4"},
+		{b.Pos(0), "b.h:1:1"},
+		{b.Pos(2), "b.h:2:1"},
+		{b.Pos(3), "b.h:2:2"},
+	}
+
+	for _, test := range tests {
+		if actual := fset.Position(test.Pos).String(); actual != test.Value {
+			t.Errorf("Position(%d) = %s, want %s", test.Pos, actual, test.Value)
+		}
+	}
+}
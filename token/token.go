@@ -15,6 +15,8 @@ const (
 	literal_beg
 	IDENT
 	INT
+	FLOAT
+	CHAR
 	STRING
 	literal_end
 
@@ -32,30 +34,79 @@ const (
 	SHL // <<
 	SHR // >>
 
+	ADD_ASSIGN // +=
+	SUB_ASSIGN // -=
+	MUL_ASSIGN // *=
+	QUO_ASSIGN // /=
+	REM_ASSIGN // %=
+
+	AND_ASSIGN // &=
+	OR_ASSIGN  // |=
+	XOR_ASSIGN // ^=
+	SHL_ASSIGN // <<=
+	SHR_ASSIGN // >>=
+
 	LAND // &&
 	LOR  // ||
 	INC  // ++
 	DEC  // --
 
+	EQL    // ==
+	LSS    // <
+	GTR    // >
 	ASSIGN // =
+	NOT    // !
+	TILDE  // ~
+
+	NEQ // !=
+	LEQ // <=
+	GEQ // >=
 
 	LPAREN // (
+	LBRACK // [
 	LBRACE // {
 	COMMA  // ,
+	PERIOD // .
+	ARROW  // ->
+
+	ELLIPSIS // ...
+
+	HASH     // #
+	HASHHASH // ##
 
 	RPAREN    // )
+	RBRACK    // ]
 	RBRACE    // }
 	SEMICOLON // ;
+	COLON     // :
+	QUESTION  // ?
 	operator_end
 
 	keyword_beg
 	DEFINE  // #define
+	UNDEF   // #undef
+	IF      // #if
+	ELIF    // #elif
 	ELSE    // #else
 	ENDIF   // #endif
 	IFDEF   // #ifdef
 	IFNDEF  // #ifndef
 	INCLUDE // #include
+	PRAGMA  // #pragma
 	EXTERN  // extern
+	TYPEDEF // typedef
+	STRUCT  // struct
+	UNION   // union
+	ENUM    // enum
+	STATIC  // static
+	INLINE  // inline
+	SIZEOF  // sizeof
+
+	ATTRIBUTE // __attribute__
+
+	CONST    // const
+	VOLATILE // volatile
+	RESTRICT // restrict
 	keyword_end
 )
 
@@ -69,6 +120,8 @@ var tokens = [...]string{
 
 	IDENT:  "IDENT",
 	INT:    "INT",
+	FLOAT:  "FLOAT",
+	CHAR:   "CHAR",
 	STRING: "STRING",
 
 	ADD: "+",
@@ -83,28 +136,100 @@ var tokens = [...]string{
 	SHL: "<<",
 	SHR: ">>",
 
+	ADD_ASSIGN: "+=",
+	SUB_ASSIGN: "-=",
+	MUL_ASSIGN: "*=",
+	QUO_ASSIGN: "/=",
+	REM_ASSIGN: "%=",
+
+	AND_ASSIGN: "&=",
+	OR_ASSIGN:  "|=",
+	XOR_ASSIGN: "^=",
+	SHL_ASSIGN: "<<=",
+	SHR_ASSIGN: ">>=",
+
 	LAND: "&&",
 	LOR:  "||",
 	INC:  "++",
 	DEC:  "--",
 
+	EQL:    "==",
+	LSS:    "<",
+	GTR:    ">",
 	ASSIGN: "=",
+	NOT:    "!",
+	TILDE:  "~",
+
+	NEQ: "!=",
+	LEQ: "<=",
+	GEQ: ">=",
 
 	LPAREN: "(",
+	LBRACK: "[",
 	LBRACE: "{",
 	COMMA:  ",",
+	PERIOD: ".",
+	ARROW:  "->",
+
+	ELLIPSIS: "...",
+
+	HASH:     "#",
+	HASHHASH: "##",
 
 	RPAREN:    ")",
+	RBRACK:    "]",
 	RBRACE:    "}",
 	SEMICOLON: ";",
+	COLON:     ":",
+	QUESTION:  "?",
 
 	DEFINE:  "#define",
+	UNDEF:   "#undef",
+	IF:      "#if",
+	ELIF:    "#elif",
 	ELSE:    "#else",
 	ENDIF:   "#endif",
 	IFDEF:   "#ifdef",
 	IFNDEF:  "#ifndef",
 	INCLUDE: "#include",
+	PRAGMA:  "#pragma",
 	EXTERN:  "extern",
+	TYPEDEF: "typedef",
+	STRUCT:  "struct",
+	UNION:   "union",
+	ENUM:    "enum",
+	STATIC:  "static",
+	INLINE:  "inline",
+	SIZEOF:  "sizeof",
+
+	ATTRIBUTE: "__attribute__",
+
+	CONST:    "const",
+	VOLATILE: "volatile",
+	RESTRICT: "restrict",
+}
+
+var keywords map[string]Token
+
+func init() {
+	keywords = make(map[string]Token)
+	for i := EXTERN; i < keyword_end; i++ {
+		keywords[tokens[i]] = i
+	}
+	// alternate spellings of keywords accepted by GCC and Clang
+	for _, tok := range []Token{INLINE, CONST, VOLATILE, RESTRICT} {
+		keywords["__"+tokens[tok]] = tok
+		keywords["__"+tokens[tok]+"__"] = tok
+	}
+	keywords["__attribute"] = ATTRIBUTE
+}
+
+// Lookup maps an identifier to its keyword token or IDENT (if not a keyword).
+func Lookup(ident string) Token {
+	if tok, ok := keywords[ident]; ok {
+		return tok
+	}
+	return IDENT
 }
 
 func (t Token) String() string {
@@ -118,16 +243,48 @@ func (t Token) String() string {
 	return s
 }
 
+// A set of constants for precedence-based expression parsing.
+// Non-operators have lowest precedence, followed by operators
+// starting with precedence 1 up to unary operators. The highest
+// precedence serves as "catch-all" precedence for selector,
+// indexing, and other operator and delimiter tokens.
+const (
+	LowestPrec  = 0 // non-operators
+	UnaryPrec   = 11
+	HighestPrec = 12
+)
+
+// Precedence returns the operator precedence of the binary operator t.
+// If t is not a binary operator, the result is LowestPrec. The comma,
+// conditional and assignment operators are parsed separately, as they
+// bind less tightly than any of these.
 func (t Token) Precedence() int {
 	switch t {
 	case LOR:
 		return 1
 	case LAND:
 		return 2
-	case ADD, SUB, OR, XOR:
+	case OR:
+		return 3
+	case XOR:
 		return 4
-	case MUL, QUO, REM, SHL, SHR, AND:
+	case AND:
 		return 5
+	case EQL, NEQ:
+		return 6
+	case LSS, LEQ, GTR, GEQ:
+		return 7
+	case SHL, SHR:
+		return 8
+	case ADD, SUB:
+		return 9
+	case MUL, QUO, REM:
+		return 10
 	}
-	return 0
+	return LowestPrec
+}
+
+// IsAssign reports whether t is one of the assignment operators.
+func (t Token) IsAssign() bool {
+	return t == ASSIGN || ADD_ASSIGN <= t && t <= SHR_ASSIGN
 }
@@ -0,0 +1,423 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+// A Config configures a Checker.
+type Config struct {
+	// Sizes is the layout of the target; or nil, for LP64. It selects
+	// the types of the typedefs of the standard headers, like size_t,
+	// that are known without parsing them, and the alignment of the
+	// aligned attribute without an argument.
+	Sizes Sizes
+
+	// Eval returns the value of an integer constant expression, such as
+	// the length of an array or the width of a bit-field. If it is nil,
+	// only integer literals are accepted.
+	Eval func(x ast.Expr) (int64, error)
+}
+
+// A Checker computes the types of type expressions. Identifiers linked
+// to their declarations by the parser in ResolveObjects mode are
+// resolved through their objects, other typedef names and struct tags
+// through the declarations passed to Collect.
+type Checker struct {
+	conf  *Config
+	sizes Sizes
+
+	typedefs map[string]ast.Expr         // types of typedef names
+	tags     map[string]*ast.StructType  // struct and union definitions by tag
+	packs    map[*ast.StructType]int64   // #pragma pack in effect for struct definitions
+	structs  map[*ast.StructType]*Struct // types of the struct definitions computed so far
+	pack     int64                       // current #pragma pack; or 0
+	stack    []packEntry                 // #pragma pack(push)
+}
+
+// A packEntry is an entry of the stack of #pragma pack.
+type packEntry struct {
+	label string
+	pack  int64
+}
+
+// NewChecker returns a new Checker for the configuration conf.
+func NewChecker(conf *Config) *Checker {
+	sizes := conf.Sizes
+	if sizes == nil {
+		sizes = LP64
+	}
+	return &Checker{
+		conf:     conf,
+		sizes:    sizes,
+		typedefs: make(map[string]ast.Expr),
+		tags:     make(map[string]*ast.StructType),
+		packs:    make(map[*ast.StructType]int64),
+		structs:  make(map[*ast.StructType]*Struct),
+	}
+}
+
+// Collect records the typedefs and the struct and union definitions of
+// node, and the #pragma pack directives. The nodes of a file must be
+// collected in order, so that each struct definition is packed by the
+// #pragma pack preceding it.
+func (c *Checker) Collect(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.PragmaDir:
+		c.pragma(n.Text)
+		return
+	case *ast.TypeDecl:
+		c.typedefs[n.Name.Name] = n.Type
+	}
+	ast.Inspect(node, func(n ast.Node) bool {
+		if st, ok := n.(*ast.StructType); ok && st.Fields != nil {
+			if st.Name != nil {
+				c.tags[st.Name.Name] = st
+			}
+			if c.pack > 0 {
+				c.packs[st] = c.pack
+			}
+		}
+		return true
+	})
+}
+
+// pragma interprets the #pragma directive with the arguments text. Only
+// #pragma pack is interpreted, in the forms accepted by GCC and MSVC:
+//
+//	pack(n)  pack()  pack(push[, label][, n])  pack(pop[, label][, n])
+func (c *Checker) pragma(text string) {
+	args, ok := pragmaArgs(text, "pack")
+	if !ok {
+		return
+	}
+	if len(args) == 0 {
+		c.pack = 0
+		return
+	}
+	n, isNumber := packValue(args[len(args)-1])
+	switch args[0] {
+	case "push":
+		label := ""
+		if len(args) > 1 && !isNumber {
+			label = args[1]
+		}
+		c.stack = append(c.stack, packEntry{label, c.pack})
+	case "pop":
+		label := ""
+		if len(args) > 1 && !isNumber {
+			label = args[1]
+		}
+		for len(c.stack) > 0 {
+			e := c.stack[len(c.stack)-1]
+			c.stack = c.stack[:len(c.stack)-1]
+			c.pack = e.pack
+			if label == "" || e.label == label {
+				break
+			}
+		}
+	}
+	if isNumber {
+		c.pack = n
+	}
+}
+
+// pragmaArgs splits the text of the #pragma name(args) into the
+// arguments.
+func pragmaArgs(text, name string) ([]string, bool) {
+	if !strings.HasPrefix(text, name) {
+		return nil, false
+	}
+	text = strings.TrimSpace(text[len(name):])
+	if !strings.HasPrefix(text, "(") || !strings.HasSuffix(text, ")") {
+		return nil, false
+	}
+	text = strings.TrimSpace(text[1 : len(text)-1])
+	if text == "" {
+		return nil, true
+	}
+	args := strings.Split(text, ",")
+	for i, arg := range args {
+		args[i] = strings.TrimSpace(arg)
+	}
+	return args, true
+}
+
+// packValue returns the alignment of the argument s of #pragma pack.
+func packValue(s string) (int64, bool) {
+	n, err := strconv.ParseInt(s, 0, 64)
+	if err != nil || n <= 0 || n&(n-1) != 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// TypeOf returns the type of the type expression x. Pointers and
+// functions may refer to incomplete and undeclared types, but the
+// members of structs and the elements of arrays must be complete.
+func (c *Checker) TypeOf(x ast.Expr) (Type, error) {
+	return c.typeOf(x, false)
+}
+
+// typeOf returns the type of x. If incomplete is set, x may be an
+// incomplete type.
+func (c *Checker) typeOf(x ast.Expr, incomplete bool) (Type, error) {
+	switch x := x.(type) {
+	case *ast.BasicType:
+		return &Basic{Name: x.Name}, nil
+	case *ast.Ident:
+		return c.typedef(x, incomplete)
+	case *ast.QualType:
+		return c.typeOf(x.Type, incomplete)
+	case *ast.PointerType:
+		elem, err := c.typeOf(x.Elem, true)
+		if err != nil {
+			return nil, err
+		}
+		return &Pointer{Elem: elem}, nil
+	case *ast.ArrayType:
+		elem, err := c.typeOf(x.Elem, false)
+		if err != nil {
+			return nil, err
+		}
+		if !IsComplete(elem) {
+			return nil, fmt.Errorf("array of incomplete type %s", elem)
+		}
+		if x.Len == nil {
+			return &Array{Elem: elem, Len: -1}, nil
+		}
+		n, err := c.eval(x.Len)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, fmt.Errorf("invalid array length %d", n)
+		}
+		return &Array{Elem: elem, Len: n}, nil
+	case *ast.StructType:
+		return c.structType(x)
+	case *ast.EnumDecl:
+		t := &Enum{}
+		if x.Name != nil {
+			t.Tag = x.Name.Name
+		}
+		return t, nil
+	case *ast.FuncType:
+		return c.funcType(x)
+	}
+	return nil, fmt.Errorf("%T is not a type", x)
+}
+
+// typedef returns the type of the typedef name x. Undeclared names are
+// an error, unless incomplete is set.
+func (c *Checker) typedef(x *ast.Ident, incomplete bool) (Type, error) {
+	var u ast.Expr
+	if x.Obj != nil && x.Obj.Kind == ast.Typ {
+		if d, ok := x.Obj.Decl.(*ast.TypeDecl); ok {
+			u = d.Type
+		}
+	}
+	if u == nil {
+		u = c.typedefs[x.Name]
+	}
+	if u == nil {
+		if name := c.stdType(x.Name); name != "" {
+			return &Named{Name: x.Name, Type: &Basic{Name: name}}, nil
+		}
+		if incomplete {
+			return &Named{Name: x.Name}, nil
+		}
+		return nil, fmt.Errorf("undefined: %s", x.Name)
+	}
+	t, err := c.typeOf(u, incomplete)
+	if err != nil {
+		return nil, err
+	}
+	return &Named{Name: x.Name, Type: t}, nil
+}
+
+// stdType returns the builtin type of the standard typedef name on the
+// target, or "" if name is not one.
+func (c *Checker) stdType(name string) string {
+	// word is the type of the integers the size of a pointer.
+	word := "long"
+	for _, t := range []string{"int", "long", "long long"} {
+		if c.sizes.Sizeof(&Basic{Name: t}) == c.sizes.Sizeof(&Pointer{}) {
+			word = t
+			break
+		}
+	}
+	int64Type := "long long"
+	if c.sizes.Sizeof(&Basic{Name: "long"}) == 8 {
+		int64Type = "long"
+	}
+	switch name {
+	case "bool":
+		return "_Bool"
+	case "int8_t":
+		return "signed char"
+	case "int16_t":
+		return "short"
+	case "int32_t", "wchar_t":
+		return "int"
+	case "int64_t", "intmax_t":
+		return int64Type
+	case "uint8_t":
+		return "unsigned char"
+	case "uint16_t":
+		return "unsigned short"
+	case "uint32_t":
+		return "unsigned int"
+	case "uint64_t", "uintmax_t":
+		return "unsigned " + int64Type
+	case "intptr_t", "ssize_t", "ptrdiff_t":
+		return word
+	case "uintptr_t", "size_t":
+		return "unsigned " + word
+	}
+	return ""
+}
+
+// structType returns the type of the struct or union type x. A reference
+// to a tag is resolved to its definition, if there is one.
+func (c *Checker) structType(x *ast.StructType) (*Struct, error) {
+	if x.Fields == nil && x.Name != nil {
+		if def := c.definition(x.Name); def != nil {
+			x = def
+		}
+	}
+	if t, ok := c.structs[x]; ok {
+		return t, nil
+	}
+	t := &Struct{Union: x.Kind == token.UNION}
+	if x.Name != nil {
+		t.Tag = x.Name.Name
+	}
+	if x.Fields == nil {
+		return t, nil
+	}
+
+	// The struct is complete once its members are known; until then,
+	// pointers to the struct refer to the incomplete type.
+	c.structs[x] = t
+	t.Pack = c.packs[x]
+	t.Packed, t.Align = c.attrs(x.Attrs)
+	fields := make([]*Field, 0, len(x.Fields.List))
+	for i, f := range x.Fields.List {
+		ft, err := c.typeOf(f.Type, false)
+		if err == nil && !IsComplete(ft) && !isFlexible(ft, i == len(x.Fields.List)-1 && !t.Union) {
+			err = fmt.Errorf("incomplete type %s", ft)
+		}
+		if err != nil {
+			delete(c.structs, x)
+			return nil, err
+		}
+		field := &Field{Type: ft}
+		if f.Name != nil {
+			field.Name = f.Name.Name
+		}
+		if f.Bits != nil {
+			n, err := c.eval(f.Bits)
+			if err == nil && (n < 0 || n > 8*c.sizes.Sizeof(ft) || !IsInteger(ft) || n == 0 && f.Name != nil) {
+				err = fmt.Errorf("invalid bit-field %s", field.Name)
+			}
+			if err != nil {
+				delete(c.structs, x)
+				return nil, err
+			}
+			field.BitField, field.Bits = true, n
+		}
+		field.Packed, field.Align = c.attrs(f.Attrs)
+		fields = append(fields, field)
+	}
+	t.Fields = fields
+	return t, nil
+}
+
+// definition returns the definition of the struct or union tag name, or
+// nil if it is not defined.
+func (c *Checker) definition(name *ast.Ident) *ast.StructType {
+	if name.Obj != nil {
+		if st, ok := name.Obj.Decl.(*ast.StructType); ok && st.Fields != nil {
+			return st
+		}
+	}
+	return c.tags[name.Name]
+}
+
+// isFlexible reports whether t is the type of a flexible array member,
+// if last is set for the last member of a struct.
+func isFlexible(t Type, last bool) bool {
+	a, ok := t.(*Array)
+	return ok && a.Len < 0 && last
+}
+
+// funcType returns the type of the function type x. The result and the
+// parameters may be incomplete.
+func (c *Checker) funcType(x *ast.FuncType) (*Func, error) {
+	result, err := c.typeOf(x.Result, true)
+	if err != nil {
+		return nil, err
+	}
+	t := &Func{Result: result}
+	for _, f := range x.Params.List {
+		if _, ok := f.Type.(*ast.Ellipsis); ok {
+			t.Variadic = true
+			continue
+		}
+		param, err := c.typeOf(f.Type, true)
+		if err != nil {
+			return nil, err
+		}
+		t.Params = append(t.Params, param)
+	}
+	return t, nil
+}
+
+// attrs returns the layout given by the attributes list: whether it
+// contains packed, and the largest alignment of aligned, or 0.
+func (c *Checker) attrs(list []*ast.Attribute) (packed bool, align int64) {
+	for _, a := range list {
+		for _, x := range a.List {
+			var args []ast.Expr
+			if call, ok := x.(*ast.CallExpr); ok {
+				x, args = call.Fun, call.Args
+			}
+			id, ok := x.(*ast.Ident)
+			if !ok {
+				continue
+			}
+			switch strings.Trim(id.Name, "_") {
+			case "packed":
+				packed = true
+			case "aligned":
+				// the largest alignment of a builtin type
+				n := c.sizes.Alignof(&Basic{Name: "long double"})
+				if len(args) == 1 {
+					if v, err := c.eval(args[0]); err == nil {
+						n = v
+					}
+				}
+				align = max(align, n)
+			}
+		}
+	}
+	return packed, align
+}
+
+// eval returns the value of the integer constant expression x.
+func (c *Checker) eval(x ast.Expr) (int64, error) {
+	if c.conf.Eval != nil {
+		return c.conf.Eval(x)
+	}
+	if lit, ok := x.(*ast.BasicLit); ok && lit.Kind == token.INT {
+		if n, err := strconv.ParseInt(strings.TrimRight(lit.Value, "uUlL"), 0, 64); err == nil {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("%T is not an integer literal", x)
+}
@@ -0,0 +1,125 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/parser"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+func TestChecker_TypeOf(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value string
+	}{
+		{"typedef unsigned long T;", "unsigned long"},
+		{"typedef const int *T[4];", "int *[4]"},
+		{"typedef int (*T)(const char *, ...);", "int (char *, ...) *"},
+		{"typedef size_t T;", "size_t"},
+		{"typedef struct node { struct node *next; } T;", "struct node"},
+		{"typedef struct { char c; int i : 3; } T;", "struct { char c; int i : 3; }"},
+		{"typedef struct undefined *T;", "struct undefined *"},
+		{"typedef undefined_t *T;", "undefined_t *"},
+		{"struct s;\ntypedef struct s T[2];\nstruct s { int i; };", "struct s[2]"},
+		{"typedef int A[2];\ntypedef A T[3];", "A[3]"},
+		{"enum e { E };\ntypedef enum e T;", "enum e"},
+		{"typedef struct undefined T[2];", "array of incomplete type struct undefined"},
+		{"typedef struct { struct undefined u; } T;", "incomplete type struct undefined"},
+		{"typedef struct { char data[]; int n; } T;", "incomplete type char[]"},
+		{"typedef struct { int i : 33; } T;", "invalid bit-field i"},
+		{"typedef struct { double d : 1; } T;", "invalid bit-field d"},
+		{"typedef struct { int i : 0; } T;", "invalid bit-field i"},
+		{"typedef undefined_t T;", "undefined: undefined_t"},
+		{"#define N 4\ntypedef int T[N];", "*ast.Ident is not an integer literal"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			for _, mode := range []parser.Mode{0, parser.ResolveObjects} {
+				f, err := parser.ParseFile(token.NewFileSet(), "test.h", test.Input, mode)
+				if err != nil {
+					t.Fatal(err)
+				}
+				check := NewChecker(&Config{})
+				for _, node := range f.Nodes {
+					check.Collect(node)
+				}
+				var x ast.Expr
+				for _, node := range f.Nodes {
+					if d, ok := node.(*ast.TypeDecl); ok {
+						x = d.Type
+					}
+				}
+				typ, err := check.TypeOf(x)
+				got := ""
+				if err != nil {
+					got = err.Error()
+				} else {
+					got = typ.String()
+				}
+				if got != test.Value {
+					t.Errorf("got %s, want %s", got, test.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestChecker_StdTypes(t *testing.T) {
+	tests := []struct {
+		Input string
+		Sizes Sizes
+		Value string
+	}{
+		{"size_t", LP64, "unsigned long"},
+		{"size_t", ILP32, "unsigned int"},
+		{"size_t", LLP64, "unsigned long long"},
+		{"int64_t", LP64, "long"},
+		{"int64_t", ILP32, "long long"},
+		{"intptr_t", LLP64, "long long"},
+		{"wchar_t", LLP64, "int"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			check := NewChecker(&Config{Sizes: test.Sizes})
+			typ, err := check.TypeOf(&ast.Ident{Name: test.Input})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := Underlying(typ).String(); got != test.Value {
+				t.Errorf("got %s, want %s", got, test.Value)
+			}
+		})
+	}
+}
+
+func TestChecker_Pragma(t *testing.T) {
+	tests := []struct {
+		Input []string
+		Value int64
+	}{
+		{[]string{"pack(4)"}, 4},
+		{[]string{"pack(4)", "pack()"}, 0},
+		{[]string{"pack(push, 2)"}, 2},
+		{[]string{"pack(push, 2)", "pack(pop)"}, 0},
+		{[]string{"pack(2)", "pack(push)", "pack(8)", "pack(pop)"}, 2},
+		{[]string{"pack(push, a, 1)", "pack(push, 4)", "pack(pop, a)"}, 0},
+		{[]string{"pack(push, 1)", "pack(pop, 8)"}, 8},
+		{[]string{"pack(3)"}, 0},
+		{[]string{"once"}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input[len(test.Input)-1], func(t *testing.T) {
+			check := NewChecker(&Config{})
+			for _, text := range test.Input {
+				check.Collect(&ast.PragmaDir{Text: text})
+			}
+			if check.pack != test.Value {
+				t.Errorf("got %d, want %d", check.pack, test.Value)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package types
+
+// Sizes defines the layout of types on a target ABI.
+type Sizes interface {
+	// Alignof returns the alignment of a variable of type T in bytes.
+	Alignof(T Type) int64
+
+	// Sizeof returns the size of a variable of type T in bytes. The
+	// size of an incomplete type is 0.
+	Sizeof(T Type) int64
+
+	// Offsetsof returns the offsets of the members of the struct or
+	// union type T in bits.
+	Offsetsof(T *Struct) []int64
+}
+
+// StdSizes is a Sizes implementation for the common data models of C.
+//
+// The alignment of a basic type is its size, or the size of the
+// components of a complex type, but at most MaxAlign. Members of a
+// struct are aligned to their type, at most to the value of #pragma
+// pack and to 1 if they are packed, unless the aligned attribute
+// increases the alignment.
+//
+// By default, a bit-field is placed at the next bit that doesn't make
+// it cross a boundary of the alignment of its type, and unnamed
+// bit-fields don't affect the alignment of the struct, as in the SysV
+// ABIs. With MSBitFields, consecutive bit-fields share a storage unit
+// the size of their type, if the types have the same size, as with the
+// Microsoft C compiler.
+type StdSizes struct {
+	LongSize       int64 // size of long
+	PtrSize        int64 // size of pointers
+	LongDoubleSize int64 // size of long double
+	MaxAlign       int64 // maximum alignment of a basic type
+	MSBitFields    bool  // lay out bit-fields like the Microsoft C compiler
+}
+
+// The common data models.
+var (
+	// LP64 is the data model of 64-bit Unix systems, e.g. linux/amd64
+	// and linux/arm64.
+	LP64 = &StdSizes{LongSize: 8, PtrSize: 8, LongDoubleSize: 16, MaxAlign: 16}
+
+	// ILP32 is the data model of 32-bit Unix systems on 386, where
+	// 8-byte types are aligned to 4 bytes.
+	ILP32 = &StdSizes{LongSize: 4, PtrSize: 4, LongDoubleSize: 12, MaxAlign: 4}
+
+	// LLP64 is the data model of windows/amd64.
+	LLP64 = &StdSizes{LongSize: 4, PtrSize: 8, LongDoubleSize: 8, MaxAlign: 8, MSBitFields: true}
+)
+
+var stdSizes = map[string]*StdSizes{
+	"386":      ILP32,
+	"amd64":    LP64,
+	"arm":      {LongSize: 4, PtrSize: 4, LongDoubleSize: 8, MaxAlign: 8},
+	"arm64":    LP64,
+	"mips64":   LP64,
+	"mips64le": LP64,
+	"ppc64":    LP64,
+	"ppc64le":  LP64,
+	"riscv64":  LP64,
+	"s390x":    LP64,
+}
+
+var windowsSizes = map[string]*StdSizes{
+	"386":   {LongSize: 4, PtrSize: 4, LongDoubleSize: 8, MaxAlign: 8, MSBitFields: true},
+	"amd64": LLP64,
+	"arm64": LLP64,
+}
+
+// SizesFor returns the Sizes of the C ABI of the Go target goos/goarch,
+// or nil if the target is unknown.
+func SizesFor(goos, goarch string) Sizes {
+	sizes := stdSizes
+	if goos == "windows" {
+		sizes = windowsSizes
+	}
+	s, ok := sizes[goarch]
+	if !ok {
+		return nil
+	}
+	if goos == "darwin" && goarch == "arm64" {
+		// long double is double on Apple silicon.
+		return &StdSizes{LongSize: 8, PtrSize: 8, LongDoubleSize: 8, MaxAlign: 8}
+	}
+	return s
+}
+
+// basic returns the size and the alignment of the builtin type name.
+func (s *StdSizes) basic(name string) (size, align int64) {
+	switch name {
+	case "void", "_Bool", "char", "signed char", "unsigned char":
+		size = 1
+	case "short", "unsigned short":
+		size = 2
+	case "int", "unsigned int", "float":
+		size = 4
+	case "long", "unsigned long":
+		size = s.LongSize
+	case "long long", "unsigned long long", "double":
+		size = 8
+	case "long double":
+		size = s.LongDoubleSize
+	case "float _Complex", "double _Complex", "long double _Complex":
+		size, align = s.basic(name[:len(name)-len(" _Complex")])
+		return 2 * size, align
+	}
+	return size, min(size, s.MaxAlign)
+}
+
+// Alignof implements Sizes.
+func (s *StdSizes) Alignof(T Type) int64 {
+	switch t := Underlying(T).(type) {
+	case *Basic:
+		_, align := s.basic(t.Name)
+		return align
+	case *Pointer, *Func:
+		return s.PtrSize
+	case *Array:
+		return s.Alignof(t.Elem)
+	case *Struct:
+		return s.layout(t).align
+	case *Enum:
+		return s.Alignof(&Basic{Name: "int"})
+	}
+	return 1
+}
+
+// Sizeof implements Sizes.
+func (s *StdSizes) Sizeof(T Type) int64 {
+	if !IsComplete(T) {
+		return 0
+	}
+	switch t := Underlying(T).(type) {
+	case *Basic:
+		size, _ := s.basic(t.Name)
+		return size
+	case *Pointer:
+		return s.PtrSize
+	case *Array:
+		return t.Len * s.Sizeof(t.Elem)
+	case *Struct:
+		return s.layout(t).size
+	case *Enum:
+		return s.Sizeof(&Basic{Name: "int"})
+	}
+	return 0
+}
+
+// Offsetsof implements Sizes.
+func (s *StdSizes) Offsetsof(T *Struct) []int64 {
+	return s.layout(T).offsets
+}
+
+// A structLayout is the layout of a struct or union type.
+type structLayout struct {
+	size, align int64
+	offsets     []int64 // in bits
+}
+
+// fieldAlign returns the alignment of the member f of the struct t.
+func (s *StdSizes) fieldAlign(t *Struct, f *Field) int64 {
+	align := s.Alignof(f.Type)
+	if t.Packed || f.Packed {
+		align = 1
+	}
+	align = max(align, f.Align)
+	if t.Pack > 0 {
+		align = min(align, t.Pack)
+	}
+	return align
+}
+
+func (s *StdSizes) layout(t *Struct) structLayout {
+	l := structLayout{align: 1, offsets: make([]int64, len(t.Fields))}
+	var bits, size int64    // end of the members placed so far, in bits
+	var unit, unitEnd int64 // size and end in bits of the storage unit of MS bit-fields
+	for i, f := range t.Fields {
+		fsize, align := s.Sizeof(f.Type), s.fieldAlign(t, f)
+		if t.Union {
+			bits, unitEnd = 0, 0
+		}
+		switch {
+		case !f.BitField:
+			if unitEnd > bits {
+				bits = unitEnd
+			}
+			unitEnd = 0
+			bits = alignTo(bits, 8*align)
+			l.align = max(l.align, align)
+			l.offsets[i] = bits
+			bits += 8 * fsize
+		case s.MSBitFields:
+			if f.Bits == 0 {
+				// only ends the storage unit of a bit-field
+				if unitEnd > 0 {
+					bits, unitEnd = unitEnd, 0
+				}
+				l.offsets[i] = bits
+				continue
+			}
+			if unitEnd == 0 || unit != fsize || bits+f.Bits > unitEnd {
+				bits = alignTo(max(bits, unitEnd), 8*align)
+				unit, unitEnd = fsize, bits+8*fsize
+			}
+			l.align = max(l.align, align)
+			l.offsets[i] = bits
+			bits += f.Bits
+			size = max(size, unitEnd)
+		default:
+			switch {
+			case f.Bits == 0:
+				bits = alignTo(bits, 8*align)
+			case align > 1 && bits/(8*align) != (bits+f.Bits-1)/(8*align):
+				bits = alignTo(bits, 8*align)
+			}
+			if f.Name != "" {
+				l.align = max(l.align, align)
+			}
+			l.offsets[i] = bits
+			bits += f.Bits
+		}
+		size = max(size, bits)
+	}
+	l.align = max(l.align, t.Align)
+	l.size = alignTo((size+7)/8, l.align)
+	return l
+}
+
+func alignTo(n, a int64) int64 {
+	return (n + a - 1) / a * a
+}
+
+// Offsetof returns the offset in bytes of the member name of the struct
+// or union type T, like the offsetof macro of C. Members of anonymous
+// struct and union members are found as well. The result is false if T
+// has no member name, or if it is a bit-field.
+func Offsetof(sizes Sizes, T *Struct, name string) (int64, bool) {
+	offsets := sizes.Offsetsof(T)
+	for i, f := range T.Fields {
+		if f.Name == name && name != "" {
+			return offsets[i] / 8, !f.BitField
+		}
+	}
+	for i, f := range T.Fields {
+		if st, ok := Underlying(f.Type).(*Struct); ok && f.Name == "" && !f.BitField {
+			if offset, ok := Offsetof(sizes, st, name); ok {
+				return offsets[i]/8 + offset, true
+			}
+		}
+	}
+	return 0, false
+}
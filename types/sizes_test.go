@@ -0,0 +1,123 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/SHyx0rmZ/cgen/ast"
+	"github.com/SHyx0rmZ/cgen/parser"
+	"github.com/SHyx0rmZ/cgen/token"
+)
+
+// lastStruct returns the type of the struct or union declared last in
+// src, checked for sizes.
+func lastStruct(t *testing.T, sizes Sizes, src string) *Struct {
+	f, err := parser.ParseFile(token.NewFileSet(), "test.h", src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := NewChecker(&Config{Sizes: sizes})
+	for _, node := range f.Nodes {
+		check.Collect(node)
+	}
+	var x ast.Expr
+	for _, node := range f.Nodes {
+		if d, ok := node.(*ast.StructDecl); ok {
+			x = d.Type
+		}
+	}
+	typ, err := check.TypeOf(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return typ.(*Struct)
+}
+
+func TestStdSizes(t *testing.T) {
+	tests := []struct {
+		Input string
+		Sizes Sizes
+		Value string
+	}{
+		{"struct s { char c; int i; double d; };", LP64, "16 8 [0 32 64]"},
+		{"struct s { char c; long l; };", LP64, "16 8 [0 64]"},
+		{"struct s { char c; long l; };", ILP32, "8 4 [0 32]"},
+		{"struct s { char c; long l; };", LLP64, "8 4 [0 32]"},
+		{"struct s { char c; double d; };", ILP32, "12 4 [0 32]"},
+		{"struct s { char c; long double d; };", LP64, "32 16 [0 128]"},
+		{"struct s { char c; void *p; };", ILP32, "8 4 [0 32]"},
+		{"union u { char c[5]; int i; };", LP64, "8 4 [0 0]"},
+		{"struct s { char a; int b : 4; int c : 30; };", LP64, "8 4 [0 8 32]"},
+		{"struct s { char a; int b : 4; int c : 30; };", LLP64, "12 4 [0 32 64]"},
+		{"struct s { char a : 4; int : 0; char b; };", LP64, "5 1 [0 32 32]"},
+		{"struct s { char a : 4; short b : 4; };", LLP64, "4 2 [0 16]"},
+		{"#pragma pack(push, 2)\nstruct s { char c; int i; };\n#pragma pack(pop)", LP64, "6 2 [0 16]"},
+		{"#pragma pack(push, 2)\n#pragma pack(pop)\nstruct s { char c; int i; };", LP64, "8 4 [0 32]"},
+		{"struct __attribute__((packed)) s { char c; int i; };", LP64, "5 1 [0 8]"},
+		{"struct s { char c; int i __attribute__((packed)); };", LP64, "5 1 [0 8]"},
+		{"struct s { char c __attribute__((aligned(8))); } __attribute__((aligned(16)));", LP64, "16 16 [0]"},
+		{"struct s { char c __attribute__((aligned)); };", LLP64, "8 8 [0]"},
+		{"struct s { int n; char data[]; };", LP64, "4 4 [0 32]"},
+		{"struct s { struct t { char c; } t; short n; };", LP64, "4 2 [0 16]"},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%s/%v", test.Input, test.Sizes), func(t *testing.T) {
+			st := lastStruct(t, test.Sizes, test.Input)
+			got := fmt.Sprintf("%d %d %v", test.Sizes.Sizeof(st), test.Sizes.Alignof(st), test.Sizes.Offsetsof(st))
+			if got != test.Value {
+				t.Errorf("got %s, want %s", got, test.Value)
+			}
+		})
+	}
+}
+
+func TestOffsetof(t *testing.T) {
+	src := "struct s { int a; union { char b; struct { short c; int d; }; }; int e : 3; };"
+	st := lastStruct(t, LP64, src)
+
+	tests := []struct {
+		Input string
+		Value int64
+		OK    bool
+	}{
+		{"a", 0, true},
+		{"b", 4, true},
+		{"c", 4, true},
+		{"d", 8, true},
+		{"e", 12, false},
+		{"f", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			v, ok := Offsetof(LP64, st, test.Input)
+			if v != test.Value || ok != test.OK {
+				t.Errorf("got %d, %t, want %d, %t", v, ok, test.Value, test.OK)
+			}
+		})
+	}
+}
+
+func TestSizesFor(t *testing.T) {
+	tests := []struct {
+		Input string
+		Value Sizes
+	}{
+		{"linux/amd64", LP64},
+		{"linux/arm64", LP64},
+		{"linux/386", ILP32},
+		{"windows/amd64", LLP64},
+		{"plan9/mips", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Input, func(t *testing.T) {
+			goos, goarch, _ := strings.Cut(test.Input, "/")
+			if got := SizesFor(goos, goarch); got != test.Value {
+				t.Errorf("got %v, want %v", got, test.Value)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+// Package types computes the types of the type expressions of C syntax
+// trees, and their sizes, alignments and member offsets on a target
+// ABI.
+//
+// A Checker converts type expressions to Types, resolving typedef names
+// and struct tags and applying #pragma pack and the packed and aligned
+// attributes. A Sizes value, such as LP64, ILP32 or LLP64, lays the types
+// out:
+//
+//	check := types.NewChecker(&types.Config{Sizes: types.LP64})
+//	for _, node := range nodes {
+//		check.Collect(node)
+//	}
+//	t, err := check.TypeOf(x)
+//	size := types.LP64.Sizeof(t)
+package types
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Type represents a C type. Qualifiers don't affect the layout of a
+// type and are dropped.
+type Type interface {
+	// String returns the type in a notation close to C, e.g.
+	// "struct s *[4]" for an array of 4 pointers to struct s.
+	String() string
+}
+
+// A Basic represents a builtin type.
+type Basic struct {
+	Name string // canonical name, as in ast.BasicType, e.g. "unsigned long"
+}
+
+// A Pointer represents a pointer type.
+type Pointer struct {
+	Elem Type // element type
+}
+
+// An Array represents an array type.
+type Array struct {
+	Elem Type  // element type
+	Len  int64 // length; or -1, for an array of unknown size
+}
+
+// A Struct represents a struct or union type.
+type Struct struct {
+	Tag    string   // struct tag; or ""
+	Union  bool     // whether the type is a union
+	Fields []*Field // members; or nil, if the type is incomplete
+	Pack   int64    // maximum alignment of the members set by #pragma pack; or 0
+	Packed bool     // whether the type has the packed attribute
+	Align  int64    // alignment set by the aligned attribute; or 0
+}
+
+// A Field represents a member of a struct or union.
+type Field struct {
+	Name     string // or "", for anonymous members and unnamed bit-fields
+	Type     Type
+	BitField bool  // whether the member is a bit-field
+	Bits     int64 // width of a bit-field
+	Packed   bool  // whether the member has the packed attribute
+	Align    int64 // alignment set by the aligned attribute; or 0
+}
+
+// An Enum represents an enumeration type. Enumerations have the layout
+// of int.
+type Enum struct {
+	Tag string // enum tag; or ""
+}
+
+// A Func represents a function type. Function types have no size, only
+// pointers to functions do.
+type Func struct {
+	Result   Type
+	Params   []Type
+	Variadic bool
+}
+
+// A Named represents a typedef name.
+type Named struct {
+	Name string
+	Type Type // denoted type; or nil, if the typedef is not declared
+}
+
+func (t *Basic) String() string   { return t.Name }
+func (t *Pointer) String() string { return t.Elem.String() + " *" }
+func (t *Array) String() string {
+	if t.Len < 0 {
+		return t.Elem.String() + "[]"
+	}
+	return fmt.Sprintf("%s[%d]", t.Elem, t.Len)
+}
+
+func (t *Struct) String() string {
+	kind := "struct"
+	if t.Union {
+		kind = "union"
+	}
+	if t.Tag != "" {
+		return kind + " " + t.Tag
+	}
+	var b strings.Builder
+	b.WriteString(kind + " {")
+	for _, f := range t.Fields {
+		b.WriteString(" " + f.Type.String())
+		if f.Name != "" {
+			b.WriteString(" " + f.Name)
+		}
+		if f.BitField {
+			fmt.Fprintf(&b, " : %d", f.Bits)
+		}
+		b.WriteString(";")
+	}
+	b.WriteString(" }")
+	return b.String()
+}
+
+func (t *Enum) String() string {
+	if t.Tag != "" {
+		return "enum " + t.Tag
+	}
+	return "enum"
+}
+
+func (t *Func) String() string {
+	params := make([]string, len(t.Params), len(t.Params)+1)
+	for i, p := range t.Params {
+		params[i] = p.String()
+	}
+	if t.Variadic {
+		params = append(params, "...")
+	}
+	return fmt.Sprintf("%s (%s)", t.Result, strings.Join(params, ", "))
+}
+
+func (t *Named) String() string { return t.Name }
+
+// Underlying returns the type denoted by t, following typedef names. It
+// returns nil for an undeclared typedef name.
+func Underlying(t Type) Type {
+	for {
+		n, ok := t.(*Named)
+		if !ok {
+			return t
+		}
+		t = n.Type
+	}
+}
+
+// IsInteger reports whether t is an integer type, which can be the type
+// of a bit-field.
+func IsInteger(t Type) bool {
+	switch t := Underlying(t).(type) {
+	case *Basic:
+		return integers[t.Name]
+	case *Enum:
+		return true
+	}
+	return false
+}
+
+// IsComplete reports whether the size of t is known.
+func IsComplete(t Type) bool {
+	switch t := Underlying(t).(type) {
+	case nil, *Func:
+		return false
+	case *Basic:
+		return t.Name != "void"
+	case *Array:
+		return t.Len >= 0 && IsComplete(t.Elem)
+	case *Struct:
+		return t.Fields != nil
+	}
+	return true
+}
+
+var integers = map[string]bool{
+	"_Bool":              true,
+	"char":               true,
+	"signed char":        true,
+	"unsigned char":      true,
+	"short":              true,
+	"unsigned short":     true,
+	"int":                true,
+	"unsigned int":       true,
+	"long":               true,
+	"unsigned long":      true,
+	"long long":          true,
+	"unsigned long long": true,
+}